@@ -0,0 +1,320 @@
+// Package addrmgr implements an address book of Bitcoin nodes modeled after Bitcoin Core's addrman.
+// Addresses are held by new buckets until a connection to them succeeds, then they move into tried buckets.
+// https://github.com/bitcoin/bitcoin/blob/master/src/addrman.h
+package addrmgr
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+const (
+	// newBucketCount represents the number of buckets holding addresses not connected yet.
+	newBucketCount = 1024
+	// triedBucketCount represents the number of buckets holding addresses connected at least once.
+	triedBucketCount = 256
+	// bucketSize represents the number of addresses held by each bucket.
+	bucketSize = 64
+	// newBucketsPerSourceGroup represents the number of new buckets shared by addresses coming from the same group.
+	newBucketsPerSourceGroup = 64
+	// triedBucketsPerGroup represents the number of tried buckets shared by addresses from the same group.
+	triedBucketsPerGroup = 8
+)
+
+// AddrManager holds known node addresses in new and tried buckets.
+// Address positions depend on a secret key, so peers cannot choose which addresses are evicted,
+// and on the network group of the address and its source, so a single peer cannot fill the buckets.
+// It is safe for concurrent use.
+type AddrManager struct {
+	// mtx protects every field.
+	mtx sync.Mutex
+	// key represents the secret key used for placing addresses into buckets.
+	key [32]byte
+	// rand represents source of randomness used for selecting addresses.
+	rand *mrand.Rand
+	// index holds known addresses by AddrKey.
+	index map[string]*KnownAddress
+	// newBuckets holds addresses not connected yet.
+	newBuckets [newBucketCount][bucketSize]*KnownAddress
+	// triedBuckets holds addresses connected at least once.
+	triedBuckets [triedBucketCount][bucketSize]*KnownAddress
+	// nNew represents the number of addresses held by new buckets.
+	nNew int
+	// nTried represents the number of addresses held by tried buckets.
+	nTried int
+}
+
+// New returns empty AddrManager with random secret key.
+func New() *AddrManager {
+	am := &AddrManager{
+		index: map[string]*KnownAddress{},
+	}
+
+	rand.Read(am.key[:])
+
+	// Selection randomness is seeded independently, so it reveals nothing about the secret key
+	var seed [8]byte
+	rand.Read(seed[:])
+	am.rand = mrand.New(mrand.NewSource(int64(binary.LittleEndian.Uint64(seed[:]))))
+
+	return am
+}
+
+// Count returns the number of known addresses.
+func (am *AddrManager) Count() int {
+	am.mtx.Lock()
+	defer am.mtx.Unlock()
+
+	return am.nNew + am.nTried
+}
+
+// CountTried returns the number of known addresses connected at least once.
+func (am *AddrManager) CountTried() int {
+	am.mtx.Lock()
+	defer am.mtx.Unlock()
+
+	return am.nTried
+}
+
+// Add saves addrs sent by the node at src into new buckets.
+// Unroutable addresses are ignored and known addresses are updated.
+// Addresses whose bucket position is taken by a non terrible address are dropped.
+func (am *AddrManager) Add(addrs []*msg.NetAddrV2, src *msg.NetAddrV2) {
+	am.mtx.Lock()
+	defer am.mtx.Unlock()
+
+	now := time.Now()
+
+	for _, addr := range addrs {
+		if !routable(addr) {
+			continue
+		}
+
+		if ka, ok := am.index[AddrKey(addr)]; ok {
+			if addr.Timestamp.After(ka.Addr.Timestamp) && !addr.Timestamp.After(now.Add(10*time.Minute)) {
+				ka.Addr.Timestamp = addr.Timestamp
+			}
+			ka.Addr.Services |= addr.Services
+			continue
+		}
+
+		copied := *addr
+		am.addNew(&KnownAddress{Addr: &copied, Src: src}, now)
+	}
+}
+
+// Attempt records connection attempt to addr.
+func (am *AddrManager) Attempt(addr *msg.NetAddrV2) {
+	am.mtx.Lock()
+	defer am.mtx.Unlock()
+
+	ka, ok := am.index[AddrKey(addr)]
+	if !ok {
+		return
+	}
+
+	ka.Attempts++
+	ka.LastAttempt = time.Now()
+}
+
+// Good records successful connection to addr, moving it into tried buckets.
+// The address taking its tried bucket position is moved back into new buckets.
+func (am *AddrManager) Good(addr *msg.NetAddrV2) {
+	am.mtx.Lock()
+	defer am.mtx.Unlock()
+
+	ka, ok := am.index[AddrKey(addr)]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	ka.Attempts = 0
+	ka.LastAttempt = now
+	ka.LastSuccess = now
+	ka.Addr.Timestamp = now
+
+	if ka.tried {
+		return
+	}
+
+	am.newBuckets[ka.bucket][ka.pos] = nil
+	am.nNew--
+
+	bucket := am.triedBucket(ka.Addr)
+	pos := am.bucketPosition(true, bucket, ka.Addr)
+
+	evicted := am.triedBuckets[bucket][pos]
+	if evicted != nil {
+		am.triedBuckets[bucket][pos] = nil
+		am.nTried--
+		evicted.tried = false
+		delete(am.index, AddrKey(evicted.Addr))
+		am.addNew(evicted, now)
+	}
+
+	am.placeTried(ka, bucket, pos)
+}
+
+// Select returns random address to connect to, nil if there is none.
+// Tried and new addresses are selected with the same probability, so tried addresses
+// are preferred as there are less of them, and within each group recently attempted
+// or failing addresses are less likely to be selected.
+func (am *AddrManager) Select() *msg.NetAddrV2 {
+	am.mtx.Lock()
+	defer am.mtx.Unlock()
+
+	if am.nNew+am.nTried == 0 {
+		return nil
+	}
+
+	tried := am.nTried > 0 && (am.nNew == 0 || am.rand.Intn(2) == 0)
+	now := time.Now()
+	factor := 1.0
+
+	for {
+		var ka *KnownAddress
+		if tried {
+			ka = randomEntry(am.rand, am.triedBuckets[am.rand.Intn(triedBucketCount)][:])
+		} else {
+			ka = randomEntry(am.rand, am.newBuckets[am.rand.Intn(newBucketCount)][:])
+		}
+
+		if ka == nil {
+			continue
+		}
+
+		if am.rand.Float64() < factor*ka.chance(now) {
+			copied := *ka.Addr
+			return &copied
+		}
+
+		factor *= 1.2
+	}
+}
+
+// Addresses returns up to max random known addresses which are not terrible.
+func (am *AddrManager) Addresses(max int) []*msg.NetAddrV2 {
+	am.mtx.Lock()
+	defer am.mtx.Unlock()
+
+	now := time.Now()
+	addrs := make([]*msg.NetAddrV2, 0, len(am.index))
+	for _, ka := range am.index {
+		if ka.isTerrible(now) {
+			continue
+		}
+
+		copied := *ka.Addr
+		addrs = append(addrs, &copied)
+	}
+
+	am.rand.Shuffle(len(addrs), func(i, j int) {
+		addrs[i], addrs[j] = addrs[j], addrs[i]
+	})
+
+	if len(addrs) > max {
+		addrs = addrs[:max]
+	}
+
+	return addrs
+}
+
+// addNew places ka into its new bucket, returning false if its position is taken by a non terrible address.
+// It must be called holding am.mtx.
+func (am *AddrManager) addNew(ka *KnownAddress, now time.Time) bool {
+	bucket := am.newBucket(ka.Addr, ka.Src)
+	pos := am.bucketPosition(false, bucket, ka.Addr)
+
+	evicted := am.newBuckets[bucket][pos]
+	if evicted != nil {
+		if !evicted.isTerrible(now) {
+			return false
+		}
+
+		delete(am.index, AddrKey(evicted.Addr))
+		am.nNew--
+	}
+
+	ka.tried = false
+	ka.bucket = bucket
+	ka.pos = pos
+	am.newBuckets[bucket][pos] = ka
+	am.index[AddrKey(ka.Addr)] = ka
+	am.nNew++
+
+	return true
+}
+
+// placeTried places ka into tried bucket position, which must be free.
+// It must be called holding am.mtx.
+func (am *AddrManager) placeTried(ka *KnownAddress, bucket, pos int) {
+	ka.tried = true
+	ka.bucket = bucket
+	ka.pos = pos
+	am.triedBuckets[bucket][pos] = ka
+	am.index[AddrKey(ka.Addr)] = ka
+	am.nTried++
+}
+
+// newBucket returns the new bucket of addr sent by src.
+// Addresses sent by nodes of the same group are spread over newBucketsPerSourceGroup buckets.
+func (am *AddrManager) newBucket(addr, src *msg.NetAddrV2) int {
+	srcGroup := []byte(GroupKey(src))
+
+	h := am.hash([]byte(GroupKey(addr)), srcGroup) % newBucketsPerSourceGroup
+	return int(am.hash(srcGroup, uint64Bytes(h)) % newBucketCount)
+}
+
+// triedBucket returns the tried bucket of addr.
+// Addresses of the same group are spread over triedBucketsPerGroup buckets.
+func (am *AddrManager) triedBucket(addr *msg.NetAddrV2) int {
+	h := am.hash([]byte(AddrKey(addr))) % triedBucketsPerGroup
+	return int(am.hash([]byte(GroupKey(addr)), uint64Bytes(h)) % triedBucketCount)
+}
+
+// bucketPosition returns the position of addr within bucket.
+func (am *AddrManager) bucketPosition(tried bool, bucket int, addr *msg.NetAddrV2) int {
+	kind := []byte{'N'}
+	if tried {
+		kind = []byte{'K'}
+	}
+
+	return int(am.hash(kind, uint64Bytes(uint64(bucket)), []byte(AddrKey(addr))) % bucketSize)
+}
+
+// hash returns keyed hash of data.
+func (am *AddrManager) hash(data ...[]byte) uint64 {
+	b := append([]byte{}, am.key[:]...)
+	for _, d := range data {
+		b = append(b, d...)
+	}
+
+	h := protocol.DoubleHash(b)
+	return binary.LittleEndian.Uint64(h[:8])
+}
+
+// randomEntry returns the first address of bucket starting at random position, nil if bucket is empty.
+func randomEntry(r *mrand.Rand, bucket []*KnownAddress) *KnownAddress {
+	start := r.Intn(len(bucket))
+	for i := range bucket {
+		if ka := bucket[(start+i)%len(bucket)]; ka != nil {
+			return ka
+		}
+	}
+
+	return nil
+}
+
+// uint64Bytes returns little endian representation of n.
+func uint64Bytes(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, n)
+	return b
+}
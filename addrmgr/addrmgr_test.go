@@ -0,0 +1,203 @@
+package addrmgr
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+)
+
+// newAddr returns IPv4 address seen now.
+func newAddr(ip string) *msg.NetAddrV2 {
+	return &msg.NetAddrV2{
+		Timestamp: time.Now(),
+		Services:  0x01,
+		NetworkID: msg.NetIPv4,
+		Addr:      net.ParseIP(ip).To4(),
+		Port:      8333,
+	}
+}
+
+// terribleAddr returns known address which has never been connected after many attempts.
+func terribleAddr(ip string) *KnownAddress {
+	return &KnownAddress{
+		Addr:     newAddr(ip),
+		Src:      newAddr("1.1.1.1"),
+		Attempts: maxRetries,
+	}
+}
+
+func TestAddrManager(t *testing.T) {
+	src := newAddr("1.1.1.1")
+
+	t.Run("should add routable addresses once", func(t *testing.T) {
+		am := New()
+		am.Add([]*msg.NetAddrV2{
+			newAddr("11.0.0.1"),
+			newAddr("11.0.0.2"),
+			newAddr("11.0.0.1"),
+			{NetworkID: msg.NetIPv4, Addr: net.IPv4zero.To4(), Port: 8333},
+		}, src)
+
+		if am.Count() != 2 {
+			t.Errorf("Wrong number of addresses (%d)", am.Count())
+		}
+	})
+
+	t.Run("should limit buckets used by the same source group", func(t *testing.T) {
+		am := New()
+
+		addrs := []*msg.NetAddrV2{}
+		for i := 0; i < 250; i++ {
+			for j := 0; j < 4; j++ {
+				addrs = append(addrs, newAddr(net.IPv4(byte(i), byte(j), 1, 1).String()))
+			}
+		}
+		am.Add(addrs, src)
+
+		buckets := 0
+		for _, bucket := range am.newBuckets {
+			for _, ka := range bucket {
+				if ka != nil {
+					buckets++
+					break
+				}
+			}
+		}
+
+		if buckets > newBucketsPerSourceGroup {
+			t.Errorf("Wrong number of buckets (%d)", buckets)
+		}
+	})
+
+	t.Run("should move connected address into tried buckets", func(t *testing.T) {
+		am := New()
+		addr := newAddr("11.0.0.1")
+		am.Add([]*msg.NetAddrV2{addr}, src)
+		am.Attempt(addr)
+		am.Good(addr)
+
+		if am.Count() != 1 || am.CountTried() != 1 {
+			t.Errorf("Wrong number of addresses (%d) or tried addresses (%d)", am.Count(), am.CountTried())
+		}
+
+		ka := am.index[AddrKey(addr)]
+		if ka.Attempts != 0 || ka.LastSuccess.IsZero() {
+			t.Errorf("Wrong bookkeeping (attempts %d, last success %s)", ka.Attempts, ka.LastSuccess)
+		}
+	})
+
+	t.Run("should count attempts", func(t *testing.T) {
+		am := New()
+		addr := newAddr("11.0.0.1")
+		am.Add([]*msg.NetAddrV2{addr}, src)
+		am.Attempt(addr)
+		am.Attempt(addr)
+
+		ka := am.index[AddrKey(addr)]
+		if ka.Attempts != 2 || ka.LastAttempt.IsZero() {
+			t.Errorf("Wrong bookkeeping (attempts %d, last attempt %s)", ka.Attempts, ka.LastAttempt)
+		}
+	})
+
+	t.Run("should evict terrible address from new bucket", func(t *testing.T) {
+		am := New()
+		addr := newAddr("11.0.0.1")
+
+		bucket := am.newBucket(addr, src)
+		pos := am.bucketPosition(false, bucket, addr)
+
+		occupant := terribleAddr("11.0.0.2")
+		am.newBuckets[bucket][pos] = occupant
+		am.index[AddrKey(occupant.Addr)] = occupant
+		am.nNew++
+
+		am.Add([]*msg.NetAddrV2{addr}, src)
+
+		if _, ok := am.index[AddrKey(addr)]; !ok || am.Count() != 1 {
+			t.Error("Terrible address should have been evicted")
+		}
+	})
+
+	t.Run("should NOT evict good address from new bucket", func(t *testing.T) {
+		am := New()
+		addr := newAddr("11.0.0.1")
+
+		bucket := am.newBucket(addr, src)
+		pos := am.bucketPosition(false, bucket, addr)
+
+		occupant := &KnownAddress{Addr: newAddr("11.0.0.2"), Src: src}
+		am.newBuckets[bucket][pos] = occupant
+		am.index[AddrKey(occupant.Addr)] = occupant
+		am.nNew++
+
+		am.Add([]*msg.NetAddrV2{addr}, src)
+
+		if _, ok := am.index[AddrKey(addr)]; ok || am.Count() != 1 {
+			t.Error("Good address should NOT have been evicted")
+		}
+	})
+
+	t.Run("should move evicted tried address back into new buckets", func(t *testing.T) {
+		am := New()
+		addr := newAddr("11.0.0.1")
+		am.Add([]*msg.NetAddrV2{addr}, src)
+
+		bucket := am.triedBucket(addr)
+		pos := am.bucketPosition(true, bucket, addr)
+
+		occupant := &KnownAddress{Addr: newAddr("11.1.0.1"), Src: src}
+		am.placeTried(occupant, bucket, pos)
+
+		am.Good(addr)
+
+		if am.Count() != 2 || am.CountTried() != 1 {
+			t.Errorf("Wrong number of addresses (%d) or tried addresses (%d)", am.Count(), am.CountTried())
+		}
+
+		if ka := am.index[AddrKey(occupant.Addr)]; ka == nil || ka.tried {
+			t.Error("Evicted address should be held by new buckets")
+		}
+	})
+
+	t.Run("should select tried addresses more often", func(t *testing.T) {
+		am := New()
+		if am.Select() != nil {
+			t.Fatal("Empty address manager should NOT select address")
+		}
+
+		addrs := []*msg.NetAddrV2{}
+		for i := 0; i < 100; i++ {
+			addrs = append(addrs, newAddr(net.IPv4(11, byte(i), 0, 1).String()))
+		}
+		am.Add(addrs, src)
+
+		good := addrs[0]
+		am.Good(good)
+
+		selected := 0
+		for i := 0; i < 1000; i++ {
+			if AddrKey(am.Select()) == AddrKey(good) {
+				selected++
+			}
+		}
+
+		// Tried address is selected about half of the times
+		if selected < 300 {
+			t.Errorf("Tried address was selected (%d) times", selected)
+		}
+	})
+
+	t.Run("should NOT return terrible addresses", func(t *testing.T) {
+		am := New()
+		stale := newAddr("11.0.0.2")
+		stale.Timestamp = time.Now().Add(-2 * horizon)
+		am.Add([]*msg.NetAddrV2{newAddr("11.0.0.1"), stale}, src)
+
+		addrs := am.Addresses(msg.MaxAddrPerMsg)
+		if len(addrs) != 1 || AddrKey(addrs[0]) == AddrKey(stale) {
+			t.Errorf("Wrong addresses (%v)", addrs)
+		}
+	})
+}
@@ -0,0 +1,290 @@
+package addrmgr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+)
+
+// fileVersion represents the version of the on-disk format written by Encode.
+const fileVersion uint8 = 1
+
+// maxFileAddrs represents the maximum number of addresses held by the on-disk format.
+const maxFileAddrs = (newBucketCount + triedBucketCount) * bucketSize
+
+var (
+	ErrUnsupportedVersion = errors.New("Unsupported address file version")
+	ErrChecksum           = errors.New("Wrong address file checksum")
+)
+
+// Save writes known addresses into the file at path.
+// Addresses are written into a temporary file which then replaces the previous one,
+// so the file is never left partially written.
+func (am *AddrManager) Save(path string) error {
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	err = am.Encode(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Load reads known addresses from the file at path, written by Save.
+func (am *AddrManager) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return am.Decode(bufio.NewReader(f))
+}
+
+// Encode encodes known addresses into w:
+// version (uint8), secret key, number of addresses (uint32), addresses and checksum (uint32).
+// Every address is encoded as tried flag (uint8), address and source as addrv2 entries,
+// attempts (uint32), last attempt and last success as UNIX timestamps (uint64, zero for never).
+func (am *AddrManager) Encode(w io.Writer) error {
+	am.mtx.Lock()
+	defer am.mtx.Unlock()
+
+	b := bytes.NewBuffer([]byte{})
+
+	version := fileVersion
+	key := am.key[:]
+	count := uint32(len(am.index))
+
+	err := msg.EncodeBatch(b,
+		msg.EncodeVal{Order: binary.LittleEndian, Val: &version},
+		msg.EncodeVal{Order: binary.LittleEndian, Val: &key},
+		msg.EncodeVal{Order: binary.LittleEndian, Val: &count},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, ka := range am.index {
+		err = encodeKnownAddress(b, ka)
+		if err != nil {
+			return err
+		}
+	}
+
+	checksum := msg.Checksum(b.Bytes())
+	err = msg.Encode(b, binary.LittleEndian, &checksum)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// Decode decodes known addresses from r, replacing the current ones.
+// Addresses are placed again into buckets, dropping the ones whose position is taken.
+func (am *AddrManager) Decode(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < 4 {
+		return io.ErrUnexpectedEOF
+	}
+
+	body := data[:len(data)-4]
+	if msg.Checksum(body) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return ErrChecksum
+	}
+
+	b := bytes.NewBuffer(body)
+
+	var version uint8
+	err = msg.Decode(b, binary.LittleEndian, &version)
+	if err != nil {
+		return err
+	}
+
+	if version != fileVersion {
+		return fmt.Errorf("%w (%d)", ErrUnsupportedVersion, version)
+	}
+
+	key := make([]byte, len(am.key))
+	var count uint32
+	err = msg.DecodeBatch(b,
+		msg.DecodeVal{Order: binary.LittleEndian, Val: &key},
+		msg.DecodeVal{Order: binary.LittleEndian, Val: &count},
+	)
+	if err != nil {
+		return err
+	}
+
+	if count > maxFileAddrs {
+		return fmt.Errorf("Too many addresses (%d), max is (%d)", count, maxFileAddrs)
+	}
+
+	addrs := make([]*KnownAddress, 0, count)
+	for i := uint32(0); i < count; i++ {
+		ka, err := decodeKnownAddress(b)
+		if err != nil {
+			return err
+		}
+
+		addrs = append(addrs, ka)
+	}
+
+	am.mtx.Lock()
+	defer am.mtx.Unlock()
+
+	copy(am.key[:], key)
+	am.index = map[string]*KnownAddress{}
+	am.newBuckets = [newBucketCount][bucketSize]*KnownAddress{}
+	am.triedBuckets = [triedBucketCount][bucketSize]*KnownAddress{}
+	am.nNew = 0
+	am.nTried = 0
+
+	// Tried addresses first, so new ones cannot take their place
+	now := time.Now()
+	for _, ka := range addrs {
+		if !ka.tried {
+			continue
+		}
+
+		bucket := am.triedBucket(ka.Addr)
+		pos := am.bucketPosition(true, bucket, ka.Addr)
+		if am.triedBuckets[bucket][pos] == nil {
+			am.placeTried(ka, bucket, pos)
+		} else {
+			am.addNew(ka, now)
+		}
+	}
+
+	for _, ka := range addrs {
+		if !ka.tried {
+			am.addNew(ka, now)
+		}
+	}
+
+	return nil
+}
+
+// encodeKnownAddress encodes ka into w.
+func encodeKnownAddress(w io.Writer, ka *KnownAddress) error {
+	var tried uint8
+	if ka.tried {
+		tried = 1
+	}
+
+	err := msg.Encode(w, binary.LittleEndian, &tried)
+	if err != nil {
+		return err
+	}
+
+	err = ka.Addr.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	err = ka.Src.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	attempts := uint32(ka.Attempts)
+	lastAttempt := unixTime(ka.LastAttempt)
+	lastSuccess := unixTime(ka.LastSuccess)
+
+	return msg.EncodeBatch(w,
+		msg.EncodeVal{Order: binary.LittleEndian, Val: &attempts},
+		msg.EncodeVal{Order: binary.LittleEndian, Val: &lastAttempt},
+		msg.EncodeVal{Order: binary.LittleEndian, Val: &lastSuccess},
+	)
+}
+
+// decodeKnownAddress decodes KnownAddress from r.
+func decodeKnownAddress(r io.Reader) (*KnownAddress, error) {
+	var tried uint8
+	err := msg.Decode(r, binary.LittleEndian, &tried)
+	if err != nil {
+		return nil, err
+	}
+
+	ka := &KnownAddress{
+		Addr: &msg.NetAddrV2{},
+		Src:  &msg.NetAddrV2{},
+	}
+
+	err = ka.Addr.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ka.Src.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var attempts uint32
+	var lastAttempt, lastSuccess uint64
+	err = msg.DecodeBatch(r,
+		msg.DecodeVal{Order: binary.LittleEndian, Val: &attempts},
+		msg.DecodeVal{Order: binary.LittleEndian, Val: &lastAttempt},
+		msg.DecodeVal{Order: binary.LittleEndian, Val: &lastSuccess},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ka.tried = tried == 1
+	ka.Attempts = int(attempts)
+	ka.LastAttempt = fromUnixTime(lastAttempt)
+	ka.LastSuccess = fromUnixTime(lastSuccess)
+
+	return ka, nil
+}
+
+// unixTime returns UNIX timestamp of t, zero for zero time.
+func unixTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return uint64(t.Unix())
+}
+
+// fromUnixTime returns time of UNIX timestamp, zero time for zero timestamp.
+func fromUnixTime(unix uint64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(unix), 0)
+}
@@ -0,0 +1,74 @@
+package addrmgr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/elmarsan/havel/msg"
+)
+
+func TestFile(t *testing.T) {
+	src := newAddr("1.1.1.1")
+
+	am := New()
+	am.Add([]*msg.NetAddrV2{newAddr("11.0.0.1"), newAddr("11.1.0.1"), newAddr("11.2.0.1")}, src)
+	am.Good(newAddr("11.0.0.1"))
+	am.Attempt(newAddr("11.1.0.1"))
+
+	t.Run("should save and load addresses", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "peers.dat")
+
+		err := am.Save(path)
+		if err != nil {
+			t.Fatalf("Unable to save (%s)", err)
+		}
+
+		loaded := New()
+		err = loaded.Load(path)
+		if err != nil {
+			t.Fatalf("Unable to load (%s)", err)
+		}
+
+		if loaded.Count() != 3 || loaded.CountTried() != 1 {
+			t.Errorf("Wrong number of addresses (%d) or tried addresses (%d)", loaded.Count(), loaded.CountTried())
+		}
+
+		if loaded.key != am.key {
+			t.Error("Wrong secret key")
+		}
+
+		ka := loaded.index[AddrKey(newAddr("11.1.0.1"))]
+		if ka == nil || ka.Attempts != 1 || ka.LastAttempt.IsZero() {
+			t.Errorf("Wrong bookkeeping (%+v)", ka)
+		}
+	})
+
+	t.Run("should NOT load corrupted file", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+		err := am.Encode(b)
+		if err != nil {
+			t.Fatalf("Unable to encode (%s)", err)
+		}
+
+		data := b.Bytes()
+		data[10] ^= 0xff
+
+		err = New().Decode(bytes.NewBuffer(data))
+		if !errors.Is(err, ErrChecksum) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+
+	t.Run("should NOT load unknown version", func(t *testing.T) {
+		data := []byte{fileVersion + 1, 0x00, 0x00, 0x00, 0x00}
+		binary.LittleEndian.PutUint32(data[1:], msg.Checksum(data[:1]))
+
+		err := New().Decode(bytes.NewBuffer(data))
+		if !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package addrmgr
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/elmarsan/havel/msg"
+)
+
+// AddrKey returns unique representation of addr, built from its network, address and port.
+func AddrKey(addr *msg.NetAddrV2) string {
+	return fmt.Sprintf("%d/%x/%d", addr.NetworkID, addr.Addr, addr.Port)
+}
+
+// GroupKey returns the network group of addr, addresses from the same group are likely
+// controlled by the same operator:
+// /16 for IPv4, /32 for IPv6 and the first 4 bits for other networks.
+func GroupKey(addr *msg.NetAddrV2) string {
+	switch addr.NetworkID {
+	case msg.NetIPv4:
+		return net.IP(addr.Addr).Mask(net.CIDRMask(16, 32)).String() + "/16"
+	case msg.NetIPv6:
+		return net.IP(addr.Addr).Mask(net.CIDRMask(32, 128)).String() + "/32"
+	}
+
+	if len(addr.Addr) == 0 {
+		return fmt.Sprintf("%d", addr.NetworkID)
+	}
+
+	return fmt.Sprintf("%d/%x", addr.NetworkID, addr.Addr[0]>>4)
+}
+
+// unroutableNets holds IP ranges which cannot be reached over the public internet,
+// following Bitcoin Core's CNetAddr::IsRoutable.
+// https://github.com/bitcoin/bitcoin/blob/master/src/netaddress.cpp
+var unroutableNets = parseCIDRs(
+	"0.0.0.0/8",       // Local network, "this" network
+	"10.0.0.0/8",      // RFC1918, private network
+	"100.64.0.0/10",   // RFC6598, carrier-grade NAT
+	"127.0.0.0/8",     // Loopback
+	"169.254.0.0/16",  // RFC3927, link-local
+	"172.16.0.0/12",   // RFC1918, private network
+	"192.0.2.0/24",    // RFC5737, documentation
+	"192.168.0.0/16",  // RFC1918, private network
+	"198.18.0.0/15",   // RFC2544, benchmarking
+	"198.51.100.0/24", // RFC5737, documentation
+	"203.0.113.0/24",  // RFC5737, documentation
+	"224.0.0.0/4",     // Multicast
+	"240.0.0.0/4",     // Reserved, including broadcast
+	"::1/128",         // Loopback
+	"2001:10::/28",    // RFC4843, ORCHID
+	"2001:20::/28",    // RFC7343, ORCHIDv2
+	"2001:db8::/32",   // RFC3849, documentation
+	"fc00::/7",        // RFC4193, unique local
+	"fe80::/64",       // RFC4862, link-local
+	"ff00::/8",        // Multicast
+)
+
+// parseCIDRs returns the IP networks of cidrs, it panics on malformed ones.
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		nets = append(nets, ipNet)
+	}
+
+	return nets
+}
+
+// routable returns whether addr can be connected to over the public internet.
+// IP addresses from local, private, link-local, documentation and multicast ranges are not routable.
+func routable(addr *msg.NetAddrV2) bool {
+	if addr.Port == 0 || len(addr.Addr) == 0 {
+		return false
+	}
+
+	if addr.NetworkID != msg.NetIPv4 && addr.NetworkID != msg.NetIPv6 {
+		return true
+	}
+
+	ip := net.IP(addr.Addr)
+	if ip.To16() == nil || ip.IsUnspecified() {
+		return false
+	}
+
+	for _, ipNet := range unroutableNets {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,89 @@
+package addrmgr
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/elmarsan/havel/msg"
+)
+
+func TestGroupKey(t *testing.T) {
+	tests := []struct {
+		addr  *msg.NetAddrV2
+		group string
+	}{
+		{
+			addr:  &msg.NetAddrV2{NetworkID: msg.NetIPv4, Addr: net.ParseIP("10.1.2.3").To4()},
+			group: "10.1.0.0/16",
+		},
+		{
+			addr:  &msg.NetAddrV2{NetworkID: msg.NetIPv6, Addr: net.ParseIP("2001:db8:1:2::1")},
+			group: "2001:db8::/32",
+		},
+		{
+			addr:  &msg.NetAddrV2{NetworkID: msg.NetTorV3, Addr: bytes.Repeat([]byte{0xab}, 32)},
+			group: "4/a",
+		},
+	}
+
+	for _, test := range tests {
+		if group := GroupKey(test.addr); group != test.group {
+			t.Errorf("Wrong group (%s), expected (%s)", group, test.group)
+		}
+	}
+}
+
+func TestRoutable(t *testing.T) {
+	tests := []struct {
+		name     string
+		addr     *msg.NetAddrV2
+		routable bool
+	}{
+		{name: "public IPv4", addr: ipAddr("8.8.8.8"), routable: true},
+		{name: "public IPv6", addr: ipAddr("2a01:4f8::1"), routable: true},
+		{name: "IPv4-mapped public IPv6", addr: &msg.NetAddrV2{NetworkID: msg.NetIPv6, Addr: net.ParseIP("::ffff:8.8.8.8"), Port: 8333}, routable: true},
+		{name: "Tor v3", addr: &msg.NetAddrV2{NetworkID: msg.NetTorV3, Addr: bytes.Repeat([]byte{0xab}, 32), Port: 8333}, routable: true},
+		{name: "port 0", addr: &msg.NetAddrV2{NetworkID: msg.NetIPv4, Addr: net.ParseIP("8.8.8.8").To4()}},
+		{name: "empty address", addr: &msg.NetAddrV2{NetworkID: msg.NetTorV3, Port: 8333}},
+		{name: "unspecified IPv4", addr: ipAddr("0.0.0.0")},
+		{name: "unspecified IPv6", addr: ipAddr("::")},
+		{name: "local network", addr: ipAddr("0.1.2.3")},
+		{name: "RFC1918 10/8", addr: ipAddr("10.1.2.3")},
+		{name: "RFC1918 172.16/12", addr: ipAddr("172.31.2.3")},
+		{name: "RFC1918 192.168/16", addr: ipAddr("192.168.1.1")},
+		{name: "IPv4-mapped RFC1918", addr: &msg.NetAddrV2{NetworkID: msg.NetIPv6, Addr: net.ParseIP("::ffff:10.1.2.3"), Port: 8333}},
+		{name: "RFC2544 benchmarking", addr: ipAddr("198.19.1.1")},
+		{name: "RFC3927 link-local", addr: ipAddr("169.254.1.1")},
+		{name: "RFC6598 carrier-grade NAT", addr: ipAddr("100.100.1.1")},
+		{name: "RFC5737 192.0.2/24", addr: ipAddr("192.0.2.1")},
+		{name: "RFC5737 198.51.100/24", addr: ipAddr("198.51.100.1")},
+		{name: "RFC5737 203.0.113/24", addr: ipAddr("203.0.113.1")},
+		{name: "IPv4 loopback", addr: ipAddr("127.0.0.1")},
+		{name: "IPv4 multicast", addr: ipAddr("224.0.0.1")},
+		{name: "IPv4 broadcast", addr: ipAddr("255.255.255.255")},
+		{name: "IPv6 loopback", addr: ipAddr("::1")},
+		{name: "RFC3849 documentation", addr: ipAddr("2001:db8::1")},
+		{name: "RFC4193 unique local", addr: ipAddr("fd00::1")},
+		{name: "RFC4843 ORCHID", addr: ipAddr("2001:10::1")},
+		{name: "RFC7343 ORCHIDv2", addr: ipAddr("2001:20::1")},
+		{name: "RFC4862 link-local", addr: ipAddr("fe80::1")},
+		{name: "IPv6 multicast", addr: ipAddr("ff02::1")},
+	}
+
+	for _, test := range tests {
+		if routable(test.addr) != test.routable {
+			t.Errorf("Wrong routability of %s (%t), expected (%t)", test.name, !test.routable, test.routable)
+		}
+	}
+}
+
+// ipAddr returns address of ip listening on port 8333.
+func ipAddr(ip string) *msg.NetAddrV2 {
+	parsed := net.ParseIP(ip)
+	if v4 := parsed.To4(); v4 != nil {
+		return &msg.NetAddrV2{NetworkID: msg.NetIPv4, Addr: v4, Port: 8333}
+	}
+
+	return &msg.NetAddrV2{NetworkID: msg.NetIPv6, Addr: parsed, Port: 8333}
+}
@@ -0,0 +1,84 @@
+package addrmgr
+
+import (
+	"math"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+)
+
+const (
+	// retryInterval represents the time after an attempt during which the address is rarely selected.
+	retryInterval = 10 * time.Minute
+	// horizon represents how old an address can be before being considered terrible.
+	horizon = 30 * 24 * time.Hour
+	// maxRetries represents the attempts without any success after which an address is considered terrible.
+	maxRetries = 3
+	// maxFailures represents the attempts within minFailurePeriod after which an address is considered terrible.
+	maxFailures = 10
+	// minFailurePeriod represents the time without success after which maxFailures applies.
+	minFailurePeriod = 7 * 24 * time.Hour
+)
+
+// KnownAddress represents address tracked by AddrManager.
+type KnownAddress struct {
+	// Addr represents the network address.
+	Addr *msg.NetAddrV2
+	// Src represents the address of the node which sent Addr.
+	Src *msg.NetAddrV2
+	// Attempts represents the connection attempts since last success.
+	Attempts int
+	// LastAttempt represents the time of last connection attempt.
+	LastAttempt time.Time
+	// LastSuccess represents the time of last successful connection.
+	LastSuccess time.Time
+
+	// tried indicates whether the address is held by a tried bucket.
+	tried bool
+	// bucket and pos represent the address position in new or tried buckets.
+	bucket, pos int
+}
+
+// chance returns the relative chance of ka being selected.
+// Recently attempted and repeatedly failing addresses are less likely to be selected.
+func (ka *KnownAddress) chance(now time.Time) float64 {
+	c := 1.0
+
+	if now.Sub(ka.LastAttempt) < retryInterval {
+		c *= 0.01
+	}
+
+	attempts := ka.Attempts
+	if attempts > 8 {
+		attempts = 8
+	}
+
+	return c * math.Pow(0.66, float64(attempts))
+}
+
+// isTerrible returns whether ka is not worth keeping:
+// it comes from the future, it was not seen for a long time or it keeps failing.
+func (ka *KnownAddress) isTerrible(now time.Time) bool {
+	// Never remove addresses attempted in the last minute
+	if !ka.LastAttempt.IsZero() && now.Sub(ka.LastAttempt) < time.Minute {
+		return false
+	}
+
+	if ka.Addr.Timestamp.After(now.Add(10 * time.Minute)) {
+		return true
+	}
+
+	if now.Sub(ka.Addr.Timestamp) > horizon {
+		return true
+	}
+
+	if ka.LastSuccess.IsZero() && ka.Attempts >= maxRetries {
+		return true
+	}
+
+	if now.Sub(ka.LastSuccess) > minFailurePeriod && ka.Attempts >= maxFailures {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,76 @@
+package blockchain
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// CalcNextRequiredBits returns the difficulty required by params for a header with timestamp extending parent.
+// Difficulty only changes every RetargetInterval blocks, see CalcRetargetBits.
+// Networks with ReduceMinDifficulty allow minimum difficulty headers when more than MinDiffReductionTime
+// passed since parent, otherwise the difficulty of the last header not mined at minimum difficulty applies.
+// https://github.com/bitcoin/bitcoin/blob/master/src/pow.cpp
+func CalcNextRequiredBits(params *chaincfg.ChainParams, parent *HeaderNode, timestamp time.Time) uint32 {
+	interval := params.RetargetInterval()
+
+	if (parent.Height+1)%interval != 0 {
+		if !params.ReduceMinDifficulty {
+			return parent.Header.Bits
+		}
+
+		if timestamp.After(parent.Header.Timestamp.Add(params.MinDiffReductionTime)) {
+			return params.PowLimitBits
+		}
+
+		node := parent
+		for node.Parent != nil && node.Height%interval != 0 && node.Header.Bits == params.PowLimitBits {
+			node = node.Parent
+		}
+
+		return node.Header.Bits
+	}
+
+	first := parent.Ancestor(parent.Height - (interval - 1))
+
+	return CalcRetargetBits(params, first, parent)
+}
+
+// CalcRetargetBits returns the difficulty following the retarget interval from first to last header.
+// The target is scaled by the time spent mining the interval, relative to TargetTimespan,
+// which is clamped by RetargetAdjustmentFactor, and it never exceeds PowLimit.
+// With EnforceBIP94 the target of first header is scaled instead of last one,
+// so minimum difficulty headers at the end of the interval do not reset difficulty.
+// https://github.com/bitcoin/bips/blob/master/bip-0094.mediawiki
+func CalcRetargetBits(params *chaincfg.ChainParams, first, last *HeaderNode) uint32 {
+	if params.PowNoRetargeting {
+		return last.Header.Bits
+	}
+
+	timespan := last.Header.Timestamp.Sub(first.Header.Timestamp)
+	minTimespan := params.TargetTimespan / time.Duration(params.RetargetAdjustmentFactor)
+	maxTimespan := params.TargetTimespan * time.Duration(params.RetargetAdjustmentFactor)
+
+	if timespan < minTimespan {
+		timespan = minTimespan
+	} else if timespan > maxTimespan {
+		timespan = maxTimespan
+	}
+
+	bits := last.Header.Bits
+	if params.EnforceBIP94 {
+		bits = first.Header.Bits
+	}
+
+	target := protocol.CompactToBig(bits)
+	target.Mul(target, big.NewInt(int64(timespan/time.Second)))
+	target.Div(target, big.NewInt(int64(params.TargetTimespan/time.Second)))
+
+	if target.Cmp(params.PowLimit) > 0 {
+		target.Set(params.PowLimit)
+	}
+
+	return protocol.BigToCompact(target)
+}
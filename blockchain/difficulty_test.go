@@ -0,0 +1,149 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/msg"
+)
+
+// newNode returns HeaderNode at height with timestamp unix and bits, whose parent may skip intermediate heights.
+func newNode(height int32, unix int64, bits uint32, parent *HeaderNode) *HeaderNode {
+	return &HeaderNode{
+		Header: &msg.BlockHeader{
+			Timestamp: time.Unix(unix, 0),
+			Bits:      bits,
+		},
+		Height: height,
+		Parent: parent,
+	}
+}
+
+func TestCalcNextRequiredBits(t *testing.T) {
+	t.Run("should retarget at mainnet historical heights", func(t *testing.T) {
+		// https://github.com/bitcoin/bitcoin/blob/master/src/test/pow_tests.cpp
+		tests := []struct {
+			name        string
+			firstHeight int32
+			firstTime   int64
+			lastTime    int64
+			lastBits    uint32
+			expected    uint32
+		}{
+			{name: "first retarget keeps pow limit", firstHeight: 0, firstTime: 1231006505, lastTime: 1233061996, lastBits: 0x1d00ffff, expected: 0x1d00ffff},
+			{name: "first difficulty increase", firstHeight: 30240, firstTime: 1261130161, lastTime: 1262152739, lastBits: 0x1d00ffff, expected: 0x1d00d86a},
+			{name: "clamped to 1/4 of timespan", firstHeight: 66528, firstTime: 1279008237, lastTime: 1279297671, lastBits: 0x1c05a3f4, expected: 0x1c0168fd},
+			{name: "clamped to 4 times timespan", firstHeight: 44352, firstTime: 1263163443, lastTime: 1269211443, lastBits: 0x1c387f6f, expected: 0x1d00e1fd},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				first := newNode(test.firstHeight, test.firstTime, test.lastBits, nil)
+				last := newNode(test.firstHeight+2015, test.lastTime, test.lastBits, first)
+
+				bits := CalcNextRequiredBits(&chaincfg.MainNetParams, last, time.Unix(test.lastTime+600, 0))
+				if bits != test.expected {
+					t.Errorf("Wrong bits (0x%08x), expected (0x%08x)", bits, test.expected)
+				}
+			})
+		}
+	})
+
+	t.Run("should NOT retarget within interval", func(t *testing.T) {
+		parent := newNode(32254, 1262152139, 0x1d00ffff, nil)
+
+		bits := CalcNextRequiredBits(&chaincfg.MainNetParams, parent, time.Unix(1262152739+3600, 0))
+		if bits != 0x1d00ffff {
+			t.Errorf("Wrong bits (0x%08x)", bits)
+		}
+	})
+
+	t.Run("should allow minimum difficulty after 20 minutes on testnet", func(t *testing.T) {
+		params := &chaincfg.TestNet3Params
+
+		boundary := newNode(4032, 1300000000, 0x1c0fffff, nil)
+		minDiff := newNode(4033, 1300001500, params.PowLimitBits, boundary)
+		parent := newNode(4034, 1300001600, params.PowLimitBits, minDiff)
+
+		bits := CalcNextRequiredBits(params, parent, parent.Header.Timestamp.Add(20*time.Minute+time.Second))
+		if bits != params.PowLimitBits {
+			t.Errorf("Wrong bits (0x%08x), expected minimum difficulty", bits)
+		}
+
+		// Last header not mined at minimum difficulty sets difficulty
+		bits = CalcNextRequiredBits(params, parent, parent.Header.Timestamp.Add(20*time.Minute))
+		if bits != 0x1c0fffff {
+			t.Errorf("Wrong bits (0x%08x), expected (0x1c0fffff)", bits)
+		}
+
+		// Mainnet does not allow minimum difficulty
+		bits = CalcNextRequiredBits(&chaincfg.MainNetParams, boundary, boundary.Header.Timestamp.Add(time.Hour))
+		if bits != 0x1c0fffff {
+			t.Errorf("Wrong bits (0x%08x), expected (0x1c0fffff)", bits)
+		}
+	})
+
+	t.Run("should retarget from first header of interval on testnet4", func(t *testing.T) {
+		// Interval mined in exactly TargetTimespan, ending with a minimum difficulty header
+		first := newNode(2016, 1714800000, 0x1c0fffff, nil)
+		last := newNode(4031, 1714800000+14*24*3600, chaincfg.TestNet4Params.PowLimitBits, first)
+
+		bits := CalcRetargetBits(&chaincfg.TestNet4Params, first, last)
+		if bits != 0x1c0fffff {
+			t.Errorf("Wrong bits (0x%08x), expected (0x1c0fffff)", bits)
+		}
+
+		// Testnet3 retargets from the last header, resetting difficulty
+		bits = CalcRetargetBits(&chaincfg.TestNet3Params, first, last)
+		if bits != chaincfg.TestNet3Params.PowLimitBits {
+			t.Errorf("Wrong bits (0x%08x), expected minimum difficulty", bits)
+		}
+	})
+
+	t.Run("should NOT retarget on regtest", func(t *testing.T) {
+		first := newNode(0, 1296688602, 0x207fffff, nil)
+		last := newNode(2015, 1296688602+60, 0x207fffff, first)
+
+		bits := CalcNextRequiredBits(&chaincfg.RegTestParams, last, last.Header.Timestamp.Add(time.Second))
+		if bits != 0x207fffff {
+			t.Errorf("Wrong bits (0x%08x)", bits)
+		}
+	})
+}
+
+func TestTimeWarp(t *testing.T) {
+	// Regtest enforcing BIP94 with a retarget every 10 blocks
+	params := chaincfg.RegTestParams
+	params.EnforceBIP94 = true
+	params.TargetTimespan = 10 * params.TargetTimePerBlock
+
+	tree := NewHeaderTree(&params)
+	parent := extend(t, tree, tree.Best(), 9, 0)
+
+	header := newHeader(parent, parent.Header.Timestamp.Add(-10*time.Minute-time.Second), 0)
+
+	_, err := tree.AddHeader(header, time.Now())
+	if !errors.Is(err, ErrTimeWarp) {
+		t.Errorf("Wrong error (%v)", err)
+	}
+
+	header = newHeader(parent, parent.Header.Timestamp.Add(-10*time.Minute), 0)
+
+	_, err = tree.AddHeader(header, time.Now())
+	if err != nil {
+		t.Errorf("Header 10 minutes before parent should be accepted (%s)", err)
+	}
+
+	t.Run("should only apply to first header of interval", func(t *testing.T) {
+		parent := tree.Best()
+
+		header := newHeader(parent, parent.Header.Timestamp.Add(-10*time.Minute-time.Second), 1)
+
+		_, err := tree.AddHeader(header, time.Now())
+		if errors.Is(err, ErrTimeWarp) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package blockchain
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// medianTimeBlocks represents the number of previous blocks used for computing median time past.
+const medianTimeBlocks = 11
+
+// oneLsh256 represents 2^256, used for computing block work.
+var oneLsh256 = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// HeaderNode represents a validated block header within HeaderTree.
+type HeaderNode struct {
+	// Header represents the block header.
+	Header *msg.BlockHeader
+	// Hash represents the hash of Header.
+	Hash protocol.Hash
+	// Height represents the number of blocks between the header and genesis.
+	Height int32
+	// Work represents the cumulative proof of work of the chain ending at the header.
+	Work *big.Int
+	// Parent represents the node of the previous header, nil for genesis.
+	Parent *HeaderNode
+}
+
+// newHeaderNode returns HeaderNode of header extending parent, which is nil for genesis.
+func newHeaderNode(header *msg.BlockHeader, parent *HeaderNode) *HeaderNode {
+	node := &HeaderNode{
+		Header: header,
+		Hash:   header.BlockHash(),
+		Work:   CalcWork(header.Bits),
+		Parent: parent,
+	}
+
+	if parent != nil {
+		node.Height = parent.Height + 1
+		node.Work.Add(node.Work, parent.Work)
+	}
+
+	return node
+}
+
+// Ancestor returns the node at height within the chain ending at node, nil if height is out of such chain.
+func (node *HeaderNode) Ancestor(height int32) *HeaderNode {
+	if height < 0 || height > node.Height {
+		return nil
+	}
+
+	n := node
+	for n.Height > height {
+		n = n.Parent
+	}
+
+	return n
+}
+
+// MedianTimePast returns the median timestamp of the last 11 headers of the chain ending at node.
+// https://github.com/bitcoin/bips/blob/master/bip-0113.mediawiki
+func (node *HeaderNode) MedianTimePast() time.Time {
+	timestamps := make([]int64, 0, medianTimeBlocks)
+	for n := node; n != nil && len(timestamps) < medianTimeBlocks; n = n.Parent {
+		timestamps = append(timestamps, n.Header.Timestamp.Unix())
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	return time.Unix(timestamps[len(timestamps)/2], 0)
+}
+
+// CalcWork returns the expected number of hashes needed for mining a block with target bits: 2^256 / (target + 1).
+// Invalid targets have no work.
+func CalcWork(bits uint32) *big.Int {
+	target := protocol.CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+
+	return target.Div(oneLsh256, target.Add(target, big.NewInt(1)))
+}
@@ -0,0 +1,133 @@
+// Package blockchain implements the block header tree built during headers-first synchronization.
+// Every header is validated against its parent before joining the tree,
+// and the chain with most cumulative proof of work is selected as best chain.
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// HeaderTree holds every validated header, rooted at genesis.
+// It is safe for concurrent use.
+type HeaderTree struct {
+	// params represents the parameters of the network headers belong to.
+	params *chaincfg.ChainParams
+
+	// mtx protects index and best.
+	mtx sync.RWMutex
+	// index holds every header node by hash.
+	index map[protocol.Hash]*HeaderNode
+	// best holds the nodes of the best chain by height.
+	best []*HeaderNode
+}
+
+// NewHeaderTree returns HeaderTree holding the genesis header of params.
+func NewHeaderTree(params *chaincfg.ChainParams) *HeaderTree {
+	genesis := newHeaderNode(&params.GenesisBlock.Header, nil)
+
+	return &HeaderTree{
+		params: params,
+		index:  map[protocol.Hash]*HeaderNode{genesis.Hash: genesis},
+		best:   []*HeaderNode{genesis},
+	}
+}
+
+// AddHeader validates header at time now and adds it to the tree, returning its node.
+// Headers whose parent is unknown are rejected with ErrOrphanHeader.
+// Known headers are not validated again.
+// Best chain switches to the header chain when it has more work.
+func (tree *HeaderTree) AddHeader(header *msg.BlockHeader, now time.Time) (*HeaderNode, error) {
+	tree.mtx.Lock()
+	defer tree.mtx.Unlock()
+
+	hash := header.BlockHash()
+	if node, ok := tree.index[hash]; ok {
+		return node, nil
+	}
+
+	parent, ok := tree.index[header.PrevBlock]
+	if !ok {
+		return nil, fmt.Errorf("%w (%s), parent (%s)", ErrOrphanHeader, hash, header.PrevBlock)
+	}
+
+	err := tree.checkHeader(parent, header, now)
+	if err != nil {
+		return nil, err
+	}
+
+	node := newHeaderNode(header, parent)
+	tree.index[node.Hash] = node
+
+	if node.Work.Cmp(tree.tip().Work) > 0 {
+		tree.setTip(node)
+	}
+
+	return node, nil
+}
+
+// Node returns the node of the header with hash, nil if unknown.
+func (tree *HeaderTree) Node(hash protocol.Hash) *HeaderNode {
+	tree.mtx.RLock()
+	defer tree.mtx.RUnlock()
+
+	return tree.index[hash]
+}
+
+// NodeByHeight returns the node at height of the best chain, nil if height is out of it.
+func (tree *HeaderTree) NodeByHeight(height int32) *HeaderNode {
+	tree.mtx.RLock()
+	defer tree.mtx.RUnlock()
+
+	if height < 0 || int(height) >= len(tree.best) {
+		return nil
+	}
+
+	return tree.best[height]
+}
+
+// Best returns the last node of the best chain.
+func (tree *HeaderTree) Best() *HeaderNode {
+	tree.mtx.RLock()
+	defer tree.mtx.RUnlock()
+
+	return tree.tip()
+}
+
+// Locator returns block locator hashes of the best chain, built by msg.NewBlockLocator.
+func (tree *HeaderTree) Locator() []protocol.Hash {
+	tree.mtx.RLock()
+	defer tree.mtx.RUnlock()
+
+	chain := make([]protocol.Hash, len(tree.best))
+	for i, node := range tree.best {
+		chain[i] = node.Hash
+	}
+
+	return msg.NewBlockLocator(chain)
+}
+
+// tip returns the last node of the best chain.
+// It must be called with mtx held.
+func (tree *HeaderTree) tip() *HeaderNode {
+	return tree.best[len(tree.best)-1]
+}
+
+// setTip makes node the last node of the best chain, replacing nodes from the fork point.
+// It must be called with mtx held.
+func (tree *HeaderTree) setTip(node *HeaderNode) {
+	if int(node.Height) < len(tree.best) {
+		tree.best = tree.best[:node.Height+1]
+	} else {
+		tree.best = append(tree.best, make([]*HeaderNode, int(node.Height)+1-len(tree.best))...)
+	}
+
+	for n := node; n != nil && tree.best[n.Height] != n; n = n.Parent {
+		tree.best[n.Height] = n
+	}
+}
@@ -0,0 +1,236 @@
+package blockchain
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// newHeader returns header extending parent, mined against regtest proof of work limit.
+// Different salts produce different headers.
+func newHeader(parent *HeaderNode, timestamp time.Time, salt byte) *msg.BlockHeader {
+	header := &msg.BlockHeader{
+		Version:    4,
+		PrevBlock:  parent.Hash,
+		MerkleRoot: protocol.Hash{salt},
+		Timestamp:  timestamp,
+		Bits:       parent.Header.Bits,
+	}
+
+	for header.CheckProofOfWork(chaincfg.RegTestParams.PowLimit) != nil {
+		header.Nonce++
+	}
+
+	return header
+}
+
+// extend adds n headers to tree extending parent, 10 minutes apart, returning the last node.
+func extend(t *testing.T, tree *HeaderTree, parent *HeaderNode, n int, salt byte) *HeaderNode {
+	for i := 0; i < n; i++ {
+		header := newHeader(parent, parent.Header.Timestamp.Add(10*time.Minute), salt)
+
+		node, err := tree.AddHeader(header, time.Now())
+		if err != nil {
+			t.Fatalf("Unable to add header (%s)", err)
+		}
+
+		parent = node
+	}
+
+	return parent
+}
+
+func TestHeaderTree(t *testing.T) {
+	tree := NewHeaderTree(&chaincfg.RegTestParams)
+	genesis := tree.Best()
+
+	if genesis.Hash != chaincfg.RegTestParams.GenesisHash || genesis.Height != 0 {
+		t.Fatalf("Wrong genesis node (%s, %d)", genesis.Hash, genesis.Height)
+	}
+
+	tip := extend(t, tree, genesis, 20, 0)
+
+	t.Run("should select longest chain", func(t *testing.T) {
+		if tree.Best() != tip || tip.Height != 20 {
+			t.Errorf("Wrong best node (%d)", tree.Best().Height)
+		}
+
+		if tip.Work.Cmp(big.NewInt(21*2)) != 0 {
+			t.Errorf("Wrong chainwork (%s)", tip.Work)
+		}
+
+		if tree.NodeByHeight(10) != tip.Ancestor(10) || tree.NodeByHeight(21) != nil {
+			t.Error("Wrong nodes by height")
+		}
+	})
+
+	t.Run("should accept known header", func(t *testing.T) {
+		node, err := tree.AddHeader(tip.Header, time.Now())
+		if err != nil || node != tip {
+			t.Errorf("Known header should be accepted (%v)", err)
+		}
+	})
+
+	t.Run("should NOT accept orphan header", func(t *testing.T) {
+		header := *tip.Header
+		header.PrevBlock = protocol.Hash{0x01}
+
+		_, err := tree.AddHeader(&header, time.Now())
+		if !errors.Is(err, ErrOrphanHeader) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+
+	t.Run("should NOT switch to chain with equal work", func(t *testing.T) {
+		fork := extend(t, tree, tree.NodeByHeight(10), 10, 1)
+
+		if fork.Height != 20 || tree.Best() != tip {
+			t.Error("Best chain should NOT change")
+		}
+	})
+
+	t.Run("should switch to chain with more work", func(t *testing.T) {
+		fork := extend(t, tree, tree.NodeByHeight(10), 12, 2)
+
+		if tree.Best() != fork {
+			t.Fatalf("Wrong best node (%d)", tree.Best().Height)
+		}
+
+		for height := int32(0); height <= fork.Height; height++ {
+			if tree.NodeByHeight(height) != fork.Ancestor(height) {
+				t.Fatalf("Wrong best chain node at height (%d)", height)
+			}
+		}
+
+		if tree.Node(tip.Hash) != tip {
+			t.Error("Stale chain should remain known")
+		}
+	})
+
+	t.Run("Locator", func(t *testing.T) {
+		hashes := []protocol.Hash{}
+		for height := int32(0); height <= tree.Best().Height; height++ {
+			hashes = append(hashes, tree.NodeByHeight(height).Hash)
+		}
+
+		expected := msg.NewBlockLocator(hashes)
+		locator := tree.Locator()
+
+		if len(locator) != len(expected) {
+			t.Fatalf("Wrong locator size (%d), expected (%d)", len(locator), len(expected))
+		}
+
+		for i := range locator {
+			if locator[i] != expected[i] {
+				t.Errorf("Wrong locator hash at (%d)", i)
+			}
+		}
+	})
+}
+
+func TestCheckHeader(t *testing.T) {
+	// Regtest not allowing minimum difficulty blocks, so difficulty is checked
+	params := chaincfg.RegTestParams
+	params.ReduceMinDifficulty = false
+
+	tree := NewHeaderTree(&params)
+	tip := extend(t, tree, tree.Best(), 11, 0)
+
+	t.Run("should NOT accept timestamp not greater than median time past", func(t *testing.T) {
+		header := newHeader(tip, tip.MedianTimePast(), 0)
+
+		_, err := tree.AddHeader(header, time.Now())
+		if !errors.Is(err, ErrTimeTooOld) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+
+		header = newHeader(tip, tip.MedianTimePast().Add(time.Second), 0)
+
+		_, err = tree.AddHeader(header, time.Now())
+		if err != nil {
+			t.Errorf("Header after median time past should be accepted (%s)", err)
+		}
+	})
+
+	t.Run("should NOT accept timestamp more than 2 hours in the future", func(t *testing.T) {
+		now := tip.Header.Timestamp
+
+		header := newHeader(tip, now.Add(2*time.Hour+time.Second), 1)
+
+		_, err := tree.AddHeader(header, now)
+		if !errors.Is(err, ErrTimeTooNew) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+
+		header = newHeader(tip, now.Add(2*time.Hour), 1)
+
+		_, err = tree.AddHeader(header, now)
+		if err != nil {
+			t.Errorf("Header 2 hours in the future should be accepted (%s)", err)
+		}
+	})
+
+	t.Run("should NOT accept unexpected difficulty", func(t *testing.T) {
+		header := newHeader(tip, tip.Header.Timestamp.Add(10*time.Minute), 2)
+		header.Bits = 0x207ffffe
+
+		for header.CheckProofOfWork(params.PowLimit) != nil {
+			header.Nonce++
+		}
+
+		_, err := tree.AddHeader(header, time.Now())
+		if !errors.Is(err, ErrUnexpectedBits) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+
+	t.Run("should NOT accept invalid proof of work", func(t *testing.T) {
+		header := newHeader(tip, tip.Header.Timestamp.Add(10*time.Minute), 3)
+		for header.CheckProofOfWork(params.PowLimit) == nil {
+			header.Nonce++
+		}
+
+		_, err := tree.AddHeader(header, time.Now())
+		if !errors.Is(err, ErrInvalidProofWork) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+}
+
+func TestMedianTimePast(t *testing.T) {
+	var node *HeaderNode
+	for _, unix := range []int64{10, 30, 20, 50, 40, 70, 60, 90, 80, 110, 100, 5} {
+		header := &msg.BlockHeader{Timestamp: time.Unix(unix, 0)}
+		node = newHeaderNode(header, node)
+	}
+
+	// Median of the last 11 timestamps: 30, 20, 50, 40, 70, 60, 90, 80, 110, 100, 5
+	if node.MedianTimePast().Unix() != 60 {
+		t.Errorf("Wrong median time past (%d)", node.MedianTimePast().Unix())
+	}
+
+	if node.Ancestor(0).MedianTimePast().Unix() != 10 {
+		t.Errorf("Wrong genesis median time past (%d)", node.Ancestor(0).MedianTimePast().Unix())
+	}
+}
+
+func TestCalcWork(t *testing.T) {
+	samples := map[uint32]int64{
+		0x1d00ffff: 0x100010001,
+		0x207fffff: 2,
+		0x00000000: 0,
+		0x1d80ffff: 0,
+	}
+
+	for bits, expected := range samples {
+		work := CalcWork(bits)
+		if work.Cmp(big.NewInt(expected)) != 0 {
+			t.Errorf("Wrong work of (0x%08x): actual (%s), expected (%d)", bits, work, expected)
+		}
+	}
+}
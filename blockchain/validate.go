@@ -0,0 +1,69 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+)
+
+// maxFutureBlockTime represents how far into the future header timestamps are accepted.
+const maxFutureBlockTime = 2 * time.Hour
+
+// maxTimeWarp represents how far before its parent the first header of a retarget interval may be timestamped,
+// when BIP94 is enforced.
+const maxTimeWarp = 10 * time.Minute
+
+var (
+	ErrOrphanHeader     = errors.New("Header does not extend any known header")
+	ErrTimeTooOld       = errors.New("Header timestamp is not greater than median time past")
+	ErrTimeTooNew       = errors.New("Header timestamp is too far in the future")
+	ErrTimeWarp         = errors.New("Header timestamp is too far before its parent")
+	ErrUnexpectedBits   = errors.New("Header difficulty does not match expected difficulty")
+	ErrInvalidProofWork = errors.New("Header proof of work is invalid")
+)
+
+// checkHeader checks that header can extend parent at time now:
+// its difficulty is the one required after parent, its hash meets such difficulty,
+// and its timestamp is greater than parent median time past and not more than 2 hours ahead of now.
+// With BIP94, headers starting a retarget interval cannot be timestamped more than 10 minutes before parent.
+func (tree *HeaderTree) checkHeader(parent *HeaderNode, header *msg.BlockHeader, now time.Time) error {
+	err := header.CheckProofOfWork(tree.params.PowLimit)
+	if err != nil {
+		return fmt.Errorf("%w (%s)", ErrInvalidProofWork, err)
+	}
+
+	err = tree.checkDifficulty(parent, header)
+	if err != nil {
+		return err
+	}
+
+	mtp := parent.MedianTimePast()
+	if !header.Timestamp.After(mtp) {
+		return fmt.Errorf("%w (%s), median time past (%s)", ErrTimeTooOld, header.Timestamp, mtp)
+	}
+
+	if header.Timestamp.After(now.Add(maxFutureBlockTime)) {
+		return fmt.Errorf("%w (%s)", ErrTimeTooNew, header.Timestamp)
+	}
+
+	// Prevent the time warp attack
+	// https://github.com/bitcoin/bips/blob/master/bip-0094.mediawiki
+	retarget := (parent.Height+1)%tree.params.RetargetInterval() == 0
+	if tree.params.EnforceBIP94 && retarget && header.Timestamp.Before(parent.Header.Timestamp.Add(-maxTimeWarp)) {
+		return fmt.Errorf("%w (%s), parent (%s)", ErrTimeWarp, header.Timestamp, parent.Header.Timestamp)
+	}
+
+	return nil
+}
+
+// checkDifficulty checks that header bits match the difficulty required after parent.
+func (tree *HeaderTree) checkDifficulty(parent *HeaderNode, header *msg.BlockHeader) error {
+	bits := CalcNextRequiredBits(tree.params, parent, header.Timestamp)
+	if header.Bits != bits {
+		return fmt.Errorf("%w (0x%08x), expected (0x%08x)", ErrUnexpectedBits, header.Bits, bits)
+	}
+
+	return nil
+}
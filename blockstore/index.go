@@ -0,0 +1,218 @@
+package blockstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// indexVersion represents the version of the on-disk format written by encodeIndex.
+const indexVersion uint8 = 1
+
+// indexEntrySize represents the size of encoded IndexEntry.
+const indexEntrySize = protocol.HashSize + 4 + 4 + 4 + 4 + 1
+
+var (
+	ErrUnsupportedVersion = errors.New("Unsupported block index version")
+	ErrChecksum           = errors.New("Wrong block index checksum")
+)
+
+// BlockStatus represents the state of a stored block.
+type BlockStatus uint8
+
+const (
+	// StatusHaveData represents block whose data is written into a block file.
+	StatusHaveData BlockStatus = 1 << iota
+	// StatusValid represents block which passed validation.
+	StatusValid
+	// StatusInvalid represents block which failed validation.
+	StatusInvalid
+	// StatusStale represents block disconnected from the best chain, which is not stored at any height.
+	StatusStale
+)
+
+// Has returns whether status includes every flag of other.
+func (status BlockStatus) Has(other BlockStatus) bool {
+	return status&other == other
+}
+
+// IndexEntry represents the location of a block within block files.
+type IndexEntry struct {
+	// Hash represents the hash of the block.
+	Hash protocol.Hash
+	// File represents the number of the block file holding the block.
+	File uint32
+	// Offset represents the position of the serialized block within the block file, after its prefix.
+	Offset uint32
+	// Size represents the size of the serialized block.
+	Size uint32
+	// Height represents the height of the block.
+	Height int32
+	// Status represents the state of the block.
+	Status BlockStatus
+}
+
+// saveIndex writes the block index into the file at path.
+// Index is written into a temporary file which then replaces the previous one,
+// so the file is never left partially written.
+func saveIndex(path string, file, fileSize uint32, entries []*IndexEntry) error {
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	err = encodeIndex(w, file, fileSize, entries)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// loadIndex reads the block index from the file at path, written by saveIndex.
+func loadIndex(path string) (file, fileSize uint32, entries []*IndexEntry, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	defer f.Close()
+
+	return decodeIndex(bufio.NewReader(f))
+}
+
+// encodeIndex encodes the block index into w: version (uint8), current block file number and size (uint32),
+// number of entries (uint32), entries and checksum (uint32).
+// Every entry is encoded as hash, file, offset, size, height (uint32) and status (uint8).
+func encodeIndex(w io.Writer, file, fileSize uint32, entries []*IndexEntry) error {
+	b := bytes.NewBuffer(make([]byte, 0, 13+len(entries)*indexEntrySize+4))
+
+	version := indexVersion
+	count := uint32(len(entries))
+
+	err := msg.EncodeBatch(b,
+		msg.EncodeVal{Order: binary.LittleEndian, Val: &version},
+		msg.EncodeVal{Order: binary.LittleEndian, Val: &file},
+		msg.EncodeVal{Order: binary.LittleEndian, Val: &fileSize},
+		msg.EncodeVal{Order: binary.LittleEndian, Val: &count},
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		height := uint32(entry.Height)
+		status := uint8(entry.Status)
+
+		err = msg.EncodeBatch(b,
+			msg.EncodeVal{Order: binary.LittleEndian, Val: &entry.Hash},
+			msg.EncodeVal{Order: binary.LittleEndian, Val: &entry.File},
+			msg.EncodeVal{Order: binary.LittleEndian, Val: &entry.Offset},
+			msg.EncodeVal{Order: binary.LittleEndian, Val: &entry.Size},
+			msg.EncodeVal{Order: binary.LittleEndian, Val: &height},
+			msg.EncodeVal{Order: binary.LittleEndian, Val: &status},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	checksum := msg.Checksum(b.Bytes())
+	err = msg.Encode(b, binary.LittleEndian, &checksum)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// decodeIndex decodes the block index from r.
+func decodeIndex(r io.Reader) (file, fileSize uint32, entries []*IndexEntry, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	if len(data) < 4 {
+		return 0, 0, nil, io.ErrUnexpectedEOF
+	}
+
+	body := data[:len(data)-4]
+	if msg.Checksum(body) != binary.LittleEndian.Uint32(data[len(data)-4:]) {
+		return 0, 0, nil, ErrChecksum
+	}
+
+	b := bytes.NewBuffer(body)
+
+	var version uint8
+	err = msg.Decode(b, binary.LittleEndian, &version)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	if version != indexVersion {
+		return 0, 0, nil, fmt.Errorf("%w (%d)", ErrUnsupportedVersion, version)
+	}
+
+	var count uint32
+	err = msg.DecodeBatch(b,
+		msg.DecodeVal{Order: binary.LittleEndian, Val: &file},
+		msg.DecodeVal{Order: binary.LittleEndian, Val: &fileSize},
+		msg.DecodeVal{Order: binary.LittleEndian, Val: &count},
+	)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	if int(count) > b.Len()/indexEntrySize {
+		return 0, 0, nil, fmt.Errorf("Too many index entries (%d)", count)
+	}
+
+	entries = make([]*IndexEntry, 0, count)
+	for i := uint32(0); i < count; i++ {
+		entry := &IndexEntry{}
+		var height uint32
+		var status uint8
+
+		err = msg.DecodeBatch(b,
+			msg.DecodeVal{Order: binary.LittleEndian, Val: &entry.Hash},
+			msg.DecodeVal{Order: binary.LittleEndian, Val: &entry.File},
+			msg.DecodeVal{Order: binary.LittleEndian, Val: &entry.Offset},
+			msg.DecodeVal{Order: binary.LittleEndian, Val: &entry.Size},
+			msg.DecodeVal{Order: binary.LittleEndian, Val: &height},
+			msg.DecodeVal{Order: binary.LittleEndian, Val: &status},
+		)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+
+		entry.Height = int32(height)
+		entry.Status = BlockStatus(status)
+		entries = append(entries, entry)
+	}
+
+	return file, fileSize, entries, nil
+}
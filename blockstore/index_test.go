@@ -0,0 +1,67 @@
+package blockstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestIndex(t *testing.T) {
+	entries := []*IndexEntry{
+		{Hash: protocol.Hash{0x01}, File: 0, Offset: 8, Size: 285, Height: 1, Status: StatusHaveData | StatusValid},
+		{Hash: protocol.Hash{0x02}, File: 1, Offset: 8, Size: 300, Height: 2, Status: StatusHaveData | StatusInvalid},
+	}
+
+	b := bytes.NewBuffer([]byte{})
+	err := encodeIndex(b, 1, 308, entries)
+	if err != nil {
+		t.Fatalf("Unable to encode index (%s)", err)
+	}
+
+	data := b.Bytes()
+
+	t.Run("should decode encoded index", func(t *testing.T) {
+		file, fileSize, decoded, err := decodeIndex(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Unable to decode index (%s)", err)
+		}
+
+		if file != 1 || fileSize != 308 {
+			t.Errorf("Wrong current block file (%d, %d)", file, fileSize)
+		}
+
+		if len(decoded) != len(entries) {
+			t.Fatalf("Wrong number of entries (%d)", len(decoded))
+		}
+
+		for i := range entries {
+			if *decoded[i] != *entries[i] {
+				t.Errorf("Wrong entry (%+v), expected (%+v)", decoded[i], entries[i])
+			}
+		}
+	})
+
+	t.Run("should NOT decode corrupted index", func(t *testing.T) {
+		corrupted := append([]byte{}, data...)
+		corrupted[20] ^= 0xff
+
+		_, _, _, err := decodeIndex(bytes.NewReader(corrupted))
+		if !errors.Is(err, ErrChecksum) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+
+	t.Run("should NOT decode unsupported version", func(t *testing.T) {
+		data := []byte{indexVersion + 1, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(data[1:], msg.Checksum(data[:1]))
+
+		_, _, _, err := decodeIndex(bytes.NewReader(data))
+		if !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+}
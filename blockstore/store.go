@@ -0,0 +1,383 @@
+// Package blockstore implements persistent block storage.
+// Blocks are appended to rotating block files named blk00000.dat, blk00001.dat, etc...
+// every one prefixed by network magic and size, the same layout used by Bitcoin Core.
+// A block index maps block hashes to their location, height and status.
+package blockstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// maxBlockFileSize represents the maximum size of a block file, unless Store sets other.
+const maxBlockFileSize = 128 * 1024 * 1024
+
+// blockPrefixSize represents the size of the prefix of every stored block: network magic and block size.
+const blockPrefixSize = 8
+
+// indexFile represents the name of the file holding the block index.
+const indexFile = "index.dat"
+
+var (
+	ErrBlockNotFound = errors.New("Block not found")
+	ErrWrongMagic    = errors.New("Wrong block file magic")
+	ErrWrongSize     = errors.New("Wrong stored block size")
+)
+
+// Store holds blocks of a network in block files within a directory.
+// Stored blocks are only guaranteed to survive a crash once flushed,
+// unflushed data found on open is discarded.
+// It is safe for concurrent use.
+type Store struct {
+	// dir represents the directory holding block files and block index.
+	dir string
+	// net represents the network blocks belong to, whose magic prefixes every block.
+	net protocol.BitcoinNet
+	// maxFileSize represents the maximum size of a block file.
+	maxFileSize uint32
+
+	// mtx protects every field below.
+	mtx sync.RWMutex
+	// index holds the location of every stored block by hash.
+	index map[protocol.Hash]*IndexEntry
+	// heights holds the hash of the last block stored at every height.
+	heights map[int32]protocol.Hash
+	// best represents the greatest height of stored blocks.
+	best int32
+	// file represents the number of the block file blocks are appended to.
+	file uint32
+	// fileSize represents the size of the block file blocks are appended to.
+	fileSize uint32
+	// f represents the block file blocks are appended to.
+	f *os.File
+	// dirty represents whether index changed since last flush.
+	dirty bool
+}
+
+// Open returns Store holding blocks of net within dir, which is created if needed.
+// Block data appended after the last flush, e.g. before a crash, is discarded.
+func Open(dir string, net protocol.BitcoinNet) (*Store, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	file, fileSize, entries, err := loadIndex(filepath.Join(dir, indexFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	s := &Store{
+		dir:         dir,
+		net:         net,
+		maxFileSize: maxBlockFileSize,
+		index:       map[protocol.Hash]*IndexEntry{},
+		heights:     map[int32]protocol.Hash{},
+		file:        file,
+		fileSize:    fileSize,
+	}
+
+	for _, entry := range entries {
+		s.index[entry.Hash] = entry
+		if !entry.Status.Has(StatusStale) {
+			s.setHeight(entry)
+		}
+	}
+
+	f, err := os.OpenFile(s.filePath(file), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop data not covered by the index
+	err = f.Truncate(int64(fileSize))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s.f = f
+
+	return s, nil
+}
+
+// Put appends block at height with status to the current block file,
+// moving to a new block file when it does not fit.
+// Blocks already stored are ignored, unless they are stale, which are stored at height again.
+func (s *Store) Put(block *msg.Block, height int32, status BlockStatus) error {
+	hash := block.BlockHash()
+
+	b := bytes.NewBuffer(make([]byte, blockPrefixSize))
+	err := block.Encode(b, protocol.ProtocolVersion)
+	if err != nil {
+		return err
+	}
+
+	data := b.Bytes()
+	size := uint32(len(data) - blockPrefixSize)
+	binary.LittleEndian.PutUint32(data[0:4], uint32(s.net))
+	binary.LittleEndian.PutUint32(data[4:8], size)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if entry, ok := s.index[hash]; ok {
+		if entry.Status.Has(StatusStale) {
+			entry.Height = height
+			entry.Status &^= StatusStale
+			s.setHeight(entry)
+			s.dirty = true
+		}
+
+		return nil
+	}
+
+	if s.fileSize > 0 && uint64(s.fileSize)+uint64(len(data)) > uint64(s.maxFileSize) {
+		err = s.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = s.f.WriteAt(data, int64(s.fileSize))
+	if err != nil {
+		return err
+	}
+
+	entry := &IndexEntry{
+		Hash:   hash,
+		File:   s.file,
+		Offset: s.fileSize + blockPrefixSize,
+		Size:   size,
+		Height: height,
+		Status: status | StatusHaveData,
+	}
+
+	s.fileSize += uint32(len(data))
+	s.index[hash] = entry
+	s.setHeight(entry)
+	s.dirty = true
+
+	return nil
+}
+
+// SetStatus replaces the status of block with hash.
+func (s *Store) SetStatus(hash protocol.Hash, status BlockStatus) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	entry, ok := s.index[hash]
+	if !ok {
+		return fmt.Errorf("%w (%s)", ErrBlockNotFound, hash)
+	}
+
+	entry.Status = status | StatusHaveData
+	s.dirty = true
+
+	return nil
+}
+
+// Disconnect marks blocks stored above height as stale, so height becomes the best height.
+// Stale blocks are kept, but they are no longer returned by height.
+func (s *Store) Disconnect(height int32) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for h := height + 1; h <= s.best; h++ {
+		hash, ok := s.heights[h]
+		if !ok {
+			continue
+		}
+
+		s.index[hash].Status |= StatusStale
+		delete(s.heights, h)
+		s.dirty = true
+	}
+
+	if height < s.best {
+		s.best = height
+	}
+}
+
+// Entry returns the index entry of block with hash.
+func (s *Store) Entry(hash protocol.Hash) (IndexEntry, bool) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	entry, ok := s.index[hash]
+	if !ok {
+		return IndexEntry{}, false
+	}
+
+	return *entry, true
+}
+
+// Block returns stored block with hash.
+func (s *Store) Block(hash protocol.Hash) (*msg.Block, error) {
+	entry, ok := s.Entry(hash)
+	if !ok {
+		return nil, fmt.Errorf("%w (%s)", ErrBlockNotFound, hash)
+	}
+
+	return s.readBlock(&entry)
+}
+
+// BlockByHeight returns the last block stored at height.
+func (s *Store) BlockByHeight(height int32) (*msg.Block, error) {
+	s.mtx.RLock()
+	hash, ok := s.heights[height]
+	s.mtx.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w (height %d)", ErrBlockNotFound, height)
+	}
+
+	return s.Block(hash)
+}
+
+// BestHeight returns the greatest height of stored blocks, zero when none is stored.
+func (s *Store) BestHeight() int32 {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	return s.best
+}
+
+// Flush syncs block files to disk, then replaces the block index,
+// so the index never refers to block data lost in a crash.
+func (s *Store) Flush() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.flush()
+}
+
+// Close flushes the store and closes the current block file.
+func (s *Store) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	err := s.flush()
+	closeErr := s.f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// flush syncs the current block file and writes the block index, must be called holding mtx.
+func (s *Store) flush() error {
+	if !s.dirty {
+		return nil
+	}
+
+	err := s.f.Sync()
+	if err != nil {
+		return err
+	}
+
+	entries := make([]*IndexEntry, 0, len(s.index))
+	for _, entry := range s.index {
+		entries = append(entries, entry)
+	}
+
+	// Keep storing order, so heights are restored the same way
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].File != entries[j].File {
+			return entries[i].File < entries[j].File
+		}
+
+		return entries[i].Offset < entries[j].Offset
+	})
+
+	err = saveIndex(filepath.Join(s.dir, indexFile), s.file, s.fileSize, entries)
+	if err != nil {
+		return err
+	}
+
+	s.dirty = false
+
+	return nil
+}
+
+// rotate syncs and closes the current block file, then appends to a new one, must be called holding mtx.
+// A new block file holding data from before a crash is truncated.
+func (s *Store) rotate() error {
+	err := s.f.Sync()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.filePath(s.file+1), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	s.f.Close()
+	s.f = f
+	s.file++
+	s.fileSize = 0
+
+	return nil
+}
+
+// readBlock reads the block located by entry, checking its prefix.
+func (s *Store) readBlock(entry *IndexEntry) (*msg.Block, error) {
+	f, err := os.Open(s.filePath(entry.File))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, blockPrefixSize+entry.Size)
+	_, err = f.ReadAt(data, int64(entry.Offset)-blockPrefixSize)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	magic := binary.LittleEndian.Uint32(data[0:4])
+	if magic != uint32(s.net) {
+		return nil, fmt.Errorf("%w (0x%08x)", ErrWrongMagic, magic)
+	}
+
+	size := binary.LittleEndian.Uint32(data[4:8])
+	if size != entry.Size {
+		return nil, fmt.Errorf("%w (%d), expected (%d)", ErrWrongSize, size, entry.Size)
+	}
+
+	block := &msg.Block{}
+	err = block.Decode(bytes.NewReader(data[blockPrefixSize:]), protocol.ProtocolVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// setHeight records entry as the block at its height, must be called holding mtx.
+func (s *Store) setHeight(entry *IndexEntry) {
+	s.heights[entry.Height] = entry.Hash
+	if entry.Height > s.best {
+		s.best = entry.Height
+	}
+}
+
+// filePath returns the path of block file with number n.
+func (s *Store) filePath(n uint32) string {
+	return filepath.Join(s.dir, fmt.Sprintf("blk%05d.dat", n))
+}
@@ -0,0 +1,279 @@
+package blockstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// newBlock returns block with single coinbase transaction, different salts produce different blocks.
+func newBlock(salt byte) *msg.Block {
+	coinbase := &msg.Tx{
+		Version: 1,
+		TxIn: []*msg.TxIn{
+			{
+				PreviousOutPoint: msg.OutPoint{Index: 0xffffffff},
+				SignatureScript:  []byte{0x01, salt},
+				Sequence:         0xffffffff,
+			},
+		},
+		TxOut: []*msg.TxOut{
+			{
+				Value:    5000000000,
+				PkScript: []byte{0x51},
+			},
+		},
+	}
+
+	return &msg.Block{
+		Header: msg.BlockHeader{
+			Version:    1,
+			MerkleRoot: coinbase.TxHash(),
+			Bits:       0x207fffff,
+		},
+		Transactions: []*msg.Tx{coinbase},
+	}
+}
+
+// putBlocks stores n blocks from height 1, returning them.
+func putBlocks(t *testing.T, s *Store, n int) []*msg.Block {
+	blocks := []*msg.Block{}
+	for i := 0; i < n; i++ {
+		block := newBlock(byte(s.BestHeight() + 1))
+
+		err := s.Put(block, s.BestHeight()+1, StatusValid)
+		if err != nil {
+			t.Fatalf("Unable to put block (%s)", err)
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+// checkBlocks checks s returns blocks by hash and by height, from height 1.
+func checkBlocks(t *testing.T, s *Store, blocks []*msg.Block) {
+	for i, block := range blocks {
+		stored, err := s.Block(block.BlockHash())
+		if err != nil {
+			t.Fatalf("Unable to get block (%s)", err)
+		}
+
+		if stored.BlockHash() != block.BlockHash() || stored.Transactions[0].TxHash() != block.Transactions[0].TxHash() {
+			t.Errorf("Wrong block (%s)", stored.BlockHash())
+		}
+
+		stored, err = s.BlockByHeight(int32(i + 1))
+		if err != nil {
+			t.Fatalf("Unable to get block by height (%s)", err)
+		}
+
+		if stored.BlockHash() != block.BlockHash() {
+			t.Errorf("Wrong block at height (%d)", i+1)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir, protocol.RegTest)
+	if err != nil {
+		t.Fatalf("Unable to open store (%s)", err)
+	}
+
+	// Fit 3 blocks per file
+	size := msg.BlockHeaderSize + 1 + newBlock(0).Transactions[0].SerializeSize()
+	s.maxFileSize = uint32(3 * (blockPrefixSize + size))
+
+	blocks := putBlocks(t, s, 10)
+
+	t.Run("should read blocks by hash and height", func(t *testing.T) {
+		checkBlocks(t, s, blocks)
+
+		if s.BestHeight() != 10 {
+			t.Errorf("Wrong best height (%d)", s.BestHeight())
+		}
+
+		_, err := s.Block(protocol.Hash{0x01})
+		if !errors.Is(err, ErrBlockNotFound) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+
+		_, err = s.BlockByHeight(11)
+		if !errors.Is(err, ErrBlockNotFound) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+
+	t.Run("should index block location and status", func(t *testing.T) {
+		entry, ok := s.Entry(blocks[4].BlockHash())
+		if !ok {
+			t.Fatal("Block should be indexed")
+		}
+
+		if entry.File != 1 || entry.Offset != uint32(blockPrefixSize+size+blockPrefixSize) || entry.Height != 5 {
+			t.Errorf("Wrong entry (%+v)", entry)
+		}
+
+		if !entry.Status.Has(StatusHaveData | StatusValid) {
+			t.Errorf("Wrong status (%d)", entry.Status)
+		}
+
+		err := s.SetStatus(blocks[4].BlockHash(), StatusInvalid)
+		if err != nil {
+			t.Fatalf("Unable to set status (%s)", err)
+		}
+
+		entry, _ = s.Entry(blocks[4].BlockHash())
+		if entry.Status != StatusHaveData|StatusInvalid {
+			t.Errorf("Wrong status (%d)", entry.Status)
+		}
+	})
+
+	t.Run("should rotate block files", func(t *testing.T) {
+		for _, name := range []string{"blk00000.dat", "blk00001.dat", "blk00002.dat", "blk00003.dat"} {
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatalf("Block file should exist (%s)", err)
+			}
+
+			if info.Size() > int64(s.maxFileSize) {
+				t.Errorf("Block file (%s) too big (%d)", name, info.Size())
+			}
+		}
+	})
+
+	t.Run("should reopen stored blocks", func(t *testing.T) {
+		err := s.Close()
+		if err != nil {
+			t.Fatalf("Unable to close store (%s)", err)
+		}
+
+		s, err = Open(dir, protocol.RegTest)
+		if err != nil {
+			t.Fatalf("Unable to open store (%s)", err)
+		}
+
+		checkBlocks(t, s, blocks)
+
+		entry, _ := s.Entry(blocks[4].BlockHash())
+		if entry.Status != StatusHaveData|StatusInvalid {
+			t.Errorf("Wrong status (%d)", entry.Status)
+		}
+	})
+
+	t.Run("should discard unflushed blocks after crash", func(t *testing.T) {
+		unflushed := putBlocks(t, s, 2)
+
+		// Crash without flushing
+		s.f.Close()
+
+		s, err = Open(dir, protocol.RegTest)
+		if err != nil {
+			t.Fatalf("Unable to open store (%s)", err)
+		}
+		defer s.Close()
+
+		if s.BestHeight() != 10 {
+			t.Errorf("Wrong best height (%d)", s.BestHeight())
+		}
+
+		for _, block := range unflushed {
+			if _, ok := s.Entry(block.BlockHash()); ok {
+				t.Error("Unflushed block should NOT be indexed")
+			}
+		}
+
+		// Appending resumes after the last flushed block
+		blocks = append(blocks, putBlocks(t, s, 2)...)
+		checkBlocks(t, s, blocks)
+	})
+
+	t.Run("should disconnect blocks above height", func(t *testing.T) {
+		s, err := Open(t.TempDir(), protocol.RegTest)
+		if err != nil {
+			t.Fatalf("Unable to open store (%s)", err)
+		}
+		defer s.Close()
+
+		blocks := putBlocks(t, s, 3)
+
+		s.Disconnect(1)
+
+		if s.BestHeight() != 1 {
+			t.Errorf("Wrong best height (%d)", s.BestHeight())
+		}
+
+		_, err = s.BlockByHeight(2)
+		if !errors.Is(err, ErrBlockNotFound) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+
+		entry, _ := s.Entry(blocks[2].BlockHash())
+		if !entry.Status.Has(StatusStale) {
+			t.Errorf("Wrong status (%d)", entry.Status)
+		}
+
+		// Blocks of the new best chain replace the stale ones
+		fork := newBlock(0xf0)
+		err = s.Put(fork, 2, StatusValid)
+		if err != nil {
+			t.Fatalf("Unable to put block (%s)", err)
+		}
+		checkBlocks(t, s, []*msg.Block{blocks[0], fork})
+
+		// Stale blocks are connected again when stored again
+		s.Disconnect(1)
+		for i, block := range blocks[1:] {
+			err = s.Put(block, int32(i+2), StatusValid)
+			if err != nil {
+				t.Fatalf("Unable to put block (%s)", err)
+			}
+		}
+		checkBlocks(t, s, blocks)
+
+		entry, _ = s.Entry(blocks[2].BlockHash())
+		if entry.Status.Has(StatusStale) {
+			t.Errorf("Wrong status (%d)", entry.Status)
+		}
+
+		// Stale blocks have no height after reopening
+		dir := t.TempDir()
+		reopened, err := Open(dir, protocol.RegTest)
+		if err != nil {
+			t.Fatalf("Unable to open store (%s)", err)
+		}
+		putBlocks(t, reopened, 3)
+		reopened.Disconnect(2)
+		reopened.Close()
+
+		reopened, err = Open(dir, protocol.RegTest)
+		if err != nil {
+			t.Fatalf("Unable to open store (%s)", err)
+		}
+		defer reopened.Close()
+
+		if reopened.BestHeight() != 2 {
+			t.Errorf("Wrong best height (%d)", reopened.BestHeight())
+		}
+	})
+
+	t.Run("should NOT read blocks of other network", func(t *testing.T) {
+		other, err := Open(dir, protocol.MainNet)
+		if err != nil {
+			t.Fatalf("Unable to open store (%s)", err)
+		}
+		defer other.Close()
+
+		_, err = other.Block(blocks[0].BlockHash())
+		if !errors.Is(err, ErrWrongMagic) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/elmarsan/havel/addrmgr"
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// seedTimeout represents the maximum time allowed for resolving a DNS seed.
+const seedTimeout = 30 * time.Second
+
+// seedServices represents the services assumed for nodes returned by DNS seeds.
+const seedServices = protocol.NODE_NETWORK | protocol.NODE_WITNESS
+
+// internalPrefix represents the IPv6 prefix of addresses standing for DNS seeds,
+// which are used as source of the resolved addresses.
+var internalPrefix = []byte{0xfd, 0x6b, 0x88, 0xc0, 0x87, 0x24}
+
+// Resolver resolves host names into IP addresses, it is implemented by *net.Resolver.
+type Resolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// Bootstrap resolves the DNS seeds of client network, saving resolved node addresses into address manager.
+// It fails when no DNS seed returns any address.
+func (c *Client) Bootstrap(ctx context.Context) error {
+	resolver := c.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	params, err := chaincfg.ParamsForNet(c.net)
+	if err != nil {
+		return err
+	}
+
+	seeds := params.DNSSeeds
+	if len(seeds) == 0 {
+		return fmt.Errorf("Network has no DNS seeds")
+	}
+
+	var wg sync.WaitGroup
+	resolved := make(chan int, len(seeds))
+
+	for _, seed := range seeds {
+		wg.Add(1)
+		go func(seed string) {
+			defer wg.Done()
+
+			n, err := c.resolveSeed(ctx, resolver, seed, params.DefaultPort)
+			if err != nil {
+				log.Printf("Unable to resolve DNS seed %s (%s)", seed, err)
+				return
+			}
+
+			resolved <- n
+		}(seed)
+	}
+
+	wg.Wait()
+	close(resolved)
+
+	total := 0
+	for n := range resolved {
+		total += n
+	}
+
+	if total == 0 {
+		return fmt.Errorf("DNS seeds returned no addresses")
+	}
+
+	return nil
+}
+
+// resolveSeed resolves seed using resolver, saving the resolved node addresses listening on port
+// into address manager.
+// It returns the number of resolved addresses.
+func (c *Client) resolveSeed(ctx context.Context, resolver Resolver, seed string, port uint16) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, seedTimeout)
+	defer cancel()
+
+	ips, err := resolver.LookupIP(ctx, "ip", seed)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	addrs := make([]*msg.NetAddrV2, 0, len(ips))
+	for _, ip := range ips {
+		// Seeds return recently seen nodes, pretend they were seen some days ago
+		// so addresses learnt from peers are preferred.
+		ago := 3*24*time.Hour + time.Duration(rand.Int63n(int64(4*24*time.Hour)))
+
+		addr, err := msg.NewNetAddrV2(&msg.NetAddr{
+			Timestamp: now.Add(-ago),
+			Services:  seedServices,
+			Ip:        ip,
+			Port:      port,
+		})
+		if err != nil {
+			continue
+		}
+
+		addrs = append(addrs, addr)
+	}
+
+	c.addrManager().Add(addrs, seedSource(seed))
+
+	return len(addrs), nil
+}
+
+// connectPeers connects to addresses selected by address manager until client has n outbound peers,
+// giving up after maxAttempts connection attempts or when client shuts down.
+// Addresses known not to offer client required services are skipped.
+func (c *Client) connectPeers(n, maxAttempts int) {
+	pm := c.peerManager()
+
+	for i := 0; i < maxAttempts && pm.count(false) < n && pm.ctx.Err() == nil; i++ {
+		addr := c.addrManager().Select()
+		if addr == nil {
+			return
+		}
+
+		if addr.NetworkID != msg.NetIPv4 && addr.NetworkID != msg.NetIPv6 || c.connected(addr) {
+			continue
+		}
+
+		if !addr.Services.Has(c.requiredServices) {
+			continue
+		}
+
+		host := net.JoinHostPort(net.IP(addr.Addr).String(), strconv.Itoa(int(addr.Port)))
+		err := c.AddPeer(host)
+		if err != nil {
+			log.Printf("Unable to add peer %s (%s)", host, err)
+		}
+	}
+}
+
+// connected returns whether client is connected to addr.
+func (c *Client) connected(addr *msg.NetAddrV2) bool {
+	for _, peer := range c.Peers() {
+		peerAddr, err := msg.NewNetAddrV2(peer.addr)
+		if err == nil && addrmgr.AddrKey(peerAddr) == addrmgr.AddrKey(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// seedSource returns the internal address standing for seed, built from the hash of its name.
+// Addresses resolved from the same seed share network group.
+func seedSource(seed string) *msg.NetAddrV2 {
+	hash := sha256.Sum256([]byte(seed))
+
+	return &msg.NetAddrV2{
+		NetworkID: msg.NetIPv6,
+		Addr:      append(append([]byte{}, internalPrefix...), hash[:net.IPv6len-len(internalPrefix)]...),
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// fakeResolver resolves host names from a fixed table.
+type fakeResolver map[string][]net.IP
+
+// LookupIP returns the IP addresses of host, failing for unknown hosts.
+func (r fakeResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return ips, nil
+}
+
+// dnsServer answers A and AAAA queries over UDP from a fixed table, failing with NXDOMAIN for unknown hosts.
+// It listens until the test ends and returns its address.
+func dnsServer(t *testing.T, table map[string][]net.IP) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen (%s)", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			reply := dnsReply(buf[:n], table)
+			if reply != nil {
+				conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// dnsReply returns the reply to DNS query answered from table, nil for malformed queries.
+func dnsReply(query []byte, table map[string][]net.IP) []byte {
+	// Header is followed by a single question: name labels, type and class
+	if len(query) < 12 || binary.BigEndian.Uint16(query[4:6]) != 1 {
+		return nil
+	}
+
+	labels := []string{}
+	offset := 12
+	for {
+		if offset >= len(query) {
+			return nil
+		}
+
+		size := int(query[offset])
+		offset++
+		if size == 0 {
+			break
+		}
+
+		if offset+size > len(query) {
+			return nil
+		}
+
+		labels = append(labels, string(query[offset:offset+size]))
+		offset += size
+	}
+
+	if offset+4 > len(query) {
+		return nil
+	}
+
+	qtype := binary.BigEndian.Uint16(query[offset : offset+2])
+	question := query[12 : offset+4]
+
+	ips, ok := table[strings.ToLower(strings.Join(labels, "."))]
+
+	answers := [][]byte{}
+	for _, ip := range ips {
+		rdata := ip.To4()
+		rtype := uint16(1) // A
+		if rdata == nil {
+			rdata = ip.To16()
+			rtype = 28 // AAAA
+		}
+
+		if rtype != qtype {
+			continue
+		}
+
+		// Name points to the question, class IN and one minute TTL
+		answer := []byte{0xc0, 0x0c, 0, 0, 0, 1, 0, 0, 0, 60, 0, 0}
+		binary.BigEndian.PutUint16(answer[2:4], rtype)
+		binary.BigEndian.PutUint16(answer[10:12], uint16(len(rdata)))
+		answers = append(answers, append(answer, rdata...))
+	}
+
+	// Response flags, recursion desired and available, NXDOMAIN for unknown hosts
+	flags := uint16(0x8180)
+	if !ok {
+		flags |= 3
+	}
+
+	reply := make([]byte, 12)
+	copy(reply[0:2], query[0:2])
+	binary.BigEndian.PutUint16(reply[2:4], flags)
+	binary.BigEndian.PutUint16(reply[4:6], 1)
+	binary.BigEndian.PutUint16(reply[6:8], uint16(len(answers)))
+
+	reply = append(reply, question...)
+	for _, answer := range answers {
+		reply = append(reply, answer...)
+	}
+
+	return reply
+}
+
+// redirectConn represents connection reporting the address it was dialed to as remote address.
+type redirectConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+// RemoteAddr returns the dialed address.
+func (conn *redirectConn) RemoteAddr() net.Addr {
+	return conn.remote
+}
+
+// redirectDial returns dial func connecting every address to target.
+func redirectDial(target string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		remote, err := net.ResolveTCPAddr(network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		dialer := net.Dialer{}
+		conn, err := dialer.DialContext(ctx, network, target)
+		if err != nil {
+			return nil, err
+		}
+
+		return &redirectConn{Conn: conn, remote: remote}, nil
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	seeds := chaincfg.MainNetParams.DNSSeeds
+
+	t.Run("should save addresses resolved from DNS seeds", func(t *testing.T) {
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+			resolver: fakeResolver{
+				seeds[0]: {net.ParseIP("11.0.0.1"), net.ParseIP("11.1.0.1")},
+				seeds[1]: {net.ParseIP("2a01:4f8::1")},
+			},
+		}
+
+		err := client.Bootstrap(context.Background())
+		if err != nil {
+			t.Fatalf("Unable to bootstrap (%s)", err)
+		}
+
+		if client.addrManager().Count() != 3 {
+			t.Errorf("Wrong number of known addresses (%d)", client.addrManager().Count())
+		}
+
+		addr := client.addrManager().Select()
+		if addr.Port != 8333 || !addr.Services.Has(protocol.NODE_WITNESS) {
+			t.Errorf("Wrong address (%+v)", addr)
+		}
+	})
+
+	t.Run("should resolve DNS seeds over DNS", func(t *testing.T) {
+		server := dnsServer(t, map[string][]net.IP{
+			seeds[0]: {net.ParseIP("11.0.0.1"), net.ParseIP("2a01:4f8::1")},
+			seeds[1]: {net.ParseIP("11.1.0.1")},
+		})
+
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+			resolver: &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+					dialer := net.Dialer{}
+					return dialer.DialContext(ctx, "udp", server)
+				},
+			},
+		}
+
+		err := client.Bootstrap(context.Background())
+		if err != nil {
+			t.Fatalf("Unable to bootstrap (%s)", err)
+		}
+
+		if client.addrManager().Count() != 3 {
+			t.Errorf("Wrong number of known addresses (%d)", client.addrManager().Count())
+		}
+	})
+
+	t.Run("should fail when DNS seeds return no addresses", func(t *testing.T) {
+		client := &Client{
+			version:  protocol.AddrV2Version,
+			net:      protocol.MainNet,
+			resolver: fakeResolver{},
+		}
+
+		err := client.Bootstrap(context.Background())
+		if err == nil {
+			t.Error("Bootstrap should have failed")
+		}
+	})
+
+	t.Run("should connect to selected addresses", func(t *testing.T) {
+		remote := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		addr, err := remote.Listen("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Unable to listen (%s)", err)
+		}
+
+		// Loopback addresses are not routable, so a public one is redirected to remote
+		netAddr, err := parseNetAddr("11.0.0.1:8333")
+		if err != nil {
+			t.Fatalf("Unable to parse address (%s)", err)
+		}
+		netAddr.Timestamp = time.Now()
+
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+			dial:    redirectDial(addr.String()),
+		}
+		client.addrManager().Add([]*msg.NetAddrV2{netAddr}, netAddr)
+
+		client.connectPeers(2, 10)
+
+		// The only known address is connected once
+		if len(client.Peers()) != 1 {
+			t.Errorf("Wrong number of peers (%d)", len(client.Peers()))
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package chaincfg
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// genesisCoinbaseTx represents the coinbase transaction of the genesis block of every network but testnet4.
+var genesisCoinbaseTx = newCoinbaseTx(
+	"04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73",
+	"4104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac",
+)
+
+// testNet4GenesisCoinbaseTx represents the coinbase transaction of testnet4 genesis block.
+var testNet4GenesisCoinbaseTx = newCoinbaseTx(
+	"04ffff001d01044c4c30332f4d61792f323032342030303030303030303030303030303030303030303165626435386332343439373062336161396437383362623030313031316662653865613865393865303065",
+	"21000000000000000000000000000000000000000000000000000000000000000000ac",
+)
+
+// mainNetGenesisBlock represents the first block of mainnet.
+var mainNetGenesisBlock = newGenesisBlock(genesisCoinbaseTx, 1231006505, 0x1d00ffff, 2083236893)
+
+// testNet3GenesisBlock represents the first block of testnet3.
+var testNet3GenesisBlock = newGenesisBlock(genesisCoinbaseTx, 1296688602, 0x1d00ffff, 414098458)
+
+// testNet4GenesisBlock represents the first block of testnet4.
+var testNet4GenesisBlock = newGenesisBlock(testNet4GenesisCoinbaseTx, 1714777860, 0x1d00ffff, 393743547)
+
+// sigNetGenesisBlock represents the first block of every signet.
+var sigNetGenesisBlock = newGenesisBlock(genesisCoinbaseTx, 1598918400, 0x1e0377ae, 52613770)
+
+// regTestGenesisBlock represents the first block of regtest.
+var regTestGenesisBlock = newGenesisBlock(genesisCoinbaseTx, 1296688602, 0x207fffff, 2)
+
+// simNetGenesisBlock represents the first block of simnet.
+var simNetGenesisBlock = newGenesisBlock(genesisCoinbaseTx, 1401292357, 0x207fffff, 2)
+
+// newCoinbaseTx returns genesis coinbase transaction paying 50 BTC, from hex encoded scripts.
+func newCoinbaseTx(signatureScript, pkScript string) *msg.Tx {
+	return &msg.Tx{
+		Version: 1,
+		TxIn: []*msg.TxIn{
+			{
+				PreviousOutPoint: msg.OutPoint{
+					Hash:  protocol.Hash{},
+					Index: 0xffffffff,
+				},
+				SignatureScript: mustDecodeHex(signatureScript),
+				Sequence:        0xffffffff,
+			},
+		},
+		TxOut: []*msg.TxOut{
+			{
+				Value:    5000000000,
+				PkScript: mustDecodeHex(pkScript),
+			},
+		},
+		LockTime: 0,
+	}
+}
+
+// newGenesisBlock returns version 1 block holding coinbase as its only transaction.
+func newGenesisBlock(coinbase *msg.Tx, timestamp int64, bits, nonce uint32) *msg.Block {
+	return &msg.Block{
+		Header: msg.BlockHeader{
+			Version:    1,
+			PrevBlock:  protocol.Hash{},
+			MerkleRoot: coinbase.TxHash(),
+			Timestamp:  time.Unix(timestamp, 0),
+			Bits:       bits,
+			Nonce:      nonce,
+		},
+		Transactions: []*msg.Tx{coinbase},
+	}
+}
+
+// mustDecodeHex returns the bytes of hex encoded s, panicking if it is not valid hex.
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
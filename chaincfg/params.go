@@ -0,0 +1,383 @@
+// Package chaincfg defines the parameters of every Bitcoin network:
+// genesis block, default port, DNS seeds, proof of work rules, BIP activation heights and address prefixes.
+package chaincfg
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+var (
+	ErrUnknownNet   = errors.New("Unknown Bitcoin network")
+	ErrDuplicateNet = errors.New("Duplicated Bitcoin network")
+)
+
+// ChainParams represents the parameters of a Bitcoin network.
+// https://github.com/bitcoin/bitcoin/blob/master/src/kernel/chainparams.cpp
+type ChainParams struct {
+	// Name represents human-readable network name.
+	Name string
+	// Net represents the network magic.
+	Net protocol.BitcoinNet
+	// DefaultPort represents the port nodes listen on by default.
+	DefaultPort uint16
+	// DNSSeeds holds the host names of DNS servers returning node addresses.
+	DNSSeeds []string
+
+	// GenesisBlock represents the first block of the chain.
+	GenesisBlock *msg.Block
+	// GenesisHash represents the hash of GenesisBlock.
+	GenesisHash protocol.Hash
+
+	// PowLimit represents the highest target allowed.
+	PowLimit *big.Int
+	// PowLimitBits represents PowLimit in compact form.
+	PowLimitBits uint32
+	// TargetTimespan represents the expected time between difficulty retargets.
+	TargetTimespan time.Duration
+	// TargetTimePerBlock represents the expected time between blocks.
+	TargetTimePerBlock time.Duration
+	// RetargetAdjustmentFactor represents the maximum factor by which difficulty changes in a retarget.
+	RetargetAdjustmentFactor int64
+	// ReduceMinDifficulty indicates whether blocks can use the minimum difficulty when
+	// no block was found within MinDiffReductionTime.
+	ReduceMinDifficulty bool
+	// MinDiffReductionTime represents the time after which minimum difficulty blocks are allowed.
+	MinDiffReductionTime time.Duration
+	// EnforceBIP94 indicates whether the time warp attack fix of testnet4 applies.
+	// https://github.com/bitcoin/bips/blob/master/bip-0094.mediawiki
+	EnforceBIP94 bool
+	// PowNoRetargeting indicates whether difficulty never changes.
+	PowNoRetargeting bool
+	// SigNetChallenge represents the script blocks must satisfy on signet, nil on any other network.
+	// https://github.com/bitcoin/bips/blob/master/bip-0325.mediawiki
+	SigNetChallenge []byte
+
+	// BIP0034Height represents the height from which coinbase must include block height.
+	BIP0034Height int32
+	// BIP0065Height represents the height from which OP_CHECKLOCKTIMEVERIFY is enforced.
+	BIP0065Height int32
+	// BIP0066Height represents the height from which strict DER signatures are enforced.
+	BIP0066Height int32
+	// CSVHeight represents the height from which relative lock times (BIP68, BIP112, BIP113) are enforced.
+	CSVHeight int32
+	// SegwitHeight represents the height from which segregated witness (BIP141, BIP143, BIP147) is enforced.
+	SegwitHeight int32
+
+	// PubKeyHashAddrID represents the version byte of P2PKH addresses.
+	PubKeyHashAddrID byte
+	// ScriptHashAddrID represents the version byte of P2SH addresses.
+	ScriptHashAddrID byte
+	// PrivateKeyID represents the version byte of WIF private keys.
+	PrivateKeyID byte
+	// Bech32HRPSegwit represents the human-readable part of segwit addresses.
+	Bech32HRPSegwit string
+	// HDPrivateKeyID represents the version bytes of BIP32 extended private keys.
+	HDPrivateKeyID [4]byte
+	// HDPublicKeyID represents the version bytes of BIP32 extended public keys.
+	HDPublicKeyID [4]byte
+}
+
+// RetargetInterval returns the number of blocks between difficulty retargets.
+func (params *ChainParams) RetargetInterval() int32 {
+	return int32(params.TargetTimespan / params.TargetTimePerBlock)
+}
+
+// mainPowLimit represents the highest target of mainnet, testnet3 and testnet4: 2^224 - 1.
+var mainPowLimit = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 224), big.NewInt(1))
+
+// regTestPowLimit represents the highest target of regtest and simnet: 2^255 - 1.
+var regTestPowLimit = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(1))
+
+// MainNetParams represents the parameters of mainnet.
+var MainNetParams = ChainParams{
+	Name:        "mainnet",
+	Net:         protocol.MainNet,
+	DefaultPort: 8333,
+	DNSSeeds: []string{
+		"seed.bitcoin.sipa.be",
+		"dnsseed.bluematt.me",
+		"dnsseed.bitcoin.dashjr-list-of-p2p-nodes.us",
+		"seed.bitcoinstats.com",
+		"seed.bitcoin.jonasschnelli.ch",
+		"seed.btc.petertodd.net",
+		"seed.bitcoin.sprovoost.nl",
+		"dnsseed.emzy.de",
+		"seed.bitcoin.wiz.biz",
+		"seed.mainnet.achownodes.xyz",
+	},
+
+	GenesisBlock: mainNetGenesisBlock,
+	GenesisHash:  mainNetGenesisBlock.BlockHash(),
+
+	PowLimit:                 mainPowLimit,
+	PowLimitBits:             0x1d00ffff,
+	TargetTimespan:           14 * 24 * time.Hour,
+	TargetTimePerBlock:       10 * time.Minute,
+	RetargetAdjustmentFactor: 4,
+
+	BIP0034Height: 227931,
+	BIP0065Height: 388381,
+	BIP0066Height: 363725,
+	CSVHeight:     419328,
+	SegwitHeight:  481824,
+
+	PubKeyHashAddrID: 0x00,
+	ScriptHashAddrID: 0x05,
+	PrivateKeyID:     0x80,
+	Bech32HRPSegwit:  "bc",
+	HDPrivateKeyID:   [4]byte{0x04, 0x88, 0xad, 0xe4},
+	HDPublicKeyID:    [4]byte{0x04, 0x88, 0xb2, 0x1e},
+}
+
+// TestNet3Params represents the parameters of testnet3.
+var TestNet3Params = ChainParams{
+	Name:        "testnet3",
+	Net:         protocol.TestNet3,
+	DefaultPort: 18333,
+	DNSSeeds: []string{
+		"testnet-seed.bitcoin.jonasschnelli.ch",
+		"seed.tbtc.petertodd.net",
+		"seed.testnet.bitcoin.sprovoost.nl",
+		"testnet-seed.bluematt.me",
+		"seed.testnet.achownodes.xyz",
+	},
+
+	GenesisBlock: testNet3GenesisBlock,
+	GenesisHash:  testNet3GenesisBlock.BlockHash(),
+
+	PowLimit:                 mainPowLimit,
+	PowLimitBits:             0x1d00ffff,
+	TargetTimespan:           14 * 24 * time.Hour,
+	TargetTimePerBlock:       10 * time.Minute,
+	RetargetAdjustmentFactor: 4,
+	ReduceMinDifficulty:      true,
+	MinDiffReductionTime:     20 * time.Minute,
+
+	BIP0034Height: 21111,
+	BIP0065Height: 581885,
+	BIP0066Height: 330776,
+	CSVHeight:     770112,
+	SegwitHeight:  834624,
+
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	PrivateKeyID:     0xef,
+	Bech32HRPSegwit:  "tb",
+	HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+	HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+}
+
+// TestNet4Params represents the parameters of testnet4.
+// https://github.com/bitcoin/bips/blob/master/bip-0094.mediawiki
+var TestNet4Params = ChainParams{
+	Name:        "testnet4",
+	Net:         protocol.TestNet4,
+	DefaultPort: 48333,
+	DNSSeeds: []string{
+		"seed.testnet4.bitcoin.sprovoost.nl",
+		"seed.testnet4.wiz.biz",
+	},
+
+	GenesisBlock: testNet4GenesisBlock,
+	GenesisHash:  testNet4GenesisBlock.BlockHash(),
+
+	PowLimit:                 mainPowLimit,
+	PowLimitBits:             0x1d00ffff,
+	TargetTimespan:           14 * 24 * time.Hour,
+	TargetTimePerBlock:       10 * time.Minute,
+	RetargetAdjustmentFactor: 4,
+	ReduceMinDifficulty:      true,
+	MinDiffReductionTime:     20 * time.Minute,
+	EnforceBIP94:             true,
+
+	BIP0034Height: 1,
+	BIP0065Height: 1,
+	BIP0066Height: 1,
+	CSVHeight:     1,
+	SegwitHeight:  1,
+
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	PrivateKeyID:     0xef,
+	Bech32HRPSegwit:  "tb",
+	HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+	HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+}
+
+// SigNetParams represents the parameters of the default signet.
+// https://github.com/bitcoin/bips/blob/master/bip-0325.mediawiki
+var SigNetParams = ChainParams{
+	Name:        "signet",
+	Net:         protocol.SigNet,
+	DefaultPort: 38333,
+	DNSSeeds: []string{
+		"seed.signet.bitcoin.sprovoost.nl",
+		"seed.signet.achownodes.xyz",
+	},
+
+	GenesisBlock: sigNetGenesisBlock,
+	GenesisHash:  sigNetGenesisBlock.BlockHash(),
+
+	PowLimit:                 protocol.CompactToBig(0x1e0377ae),
+	PowLimitBits:             0x1e0377ae,
+	TargetTimespan:           14 * 24 * time.Hour,
+	TargetTimePerBlock:       10 * time.Minute,
+	RetargetAdjustmentFactor: 4,
+	SigNetChallenge:          sigNetChallenge,
+
+	BIP0034Height: 1,
+	BIP0065Height: 1,
+	BIP0066Height: 1,
+	CSVHeight:     1,
+	SegwitHeight:  1,
+
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	PrivateKeyID:     0xef,
+	Bech32HRPSegwit:  "tb",
+	HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+	HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+}
+
+// RegTestParams represents the parameters of regtest, the local network of bitcoind -regtest.
+// Blocks are mined at minimum difficulty, which never retargets.
+var RegTestParams = ChainParams{
+	Name:        "regtest",
+	Net:         protocol.RegTest,
+	DefaultPort: 18444,
+
+	GenesisBlock: regTestGenesisBlock,
+	GenesisHash:  regTestGenesisBlock.BlockHash(),
+
+	PowLimit:                 regTestPowLimit,
+	PowLimitBits:             0x207fffff,
+	TargetTimespan:           14 * 24 * time.Hour,
+	TargetTimePerBlock:       10 * time.Minute,
+	RetargetAdjustmentFactor: 4,
+	ReduceMinDifficulty:      true,
+	MinDiffReductionTime:     20 * time.Minute,
+	PowNoRetargeting:         true,
+
+	BIP0034Height: 1,
+	BIP0065Height: 1,
+	BIP0066Height: 1,
+	CSVHeight:     1,
+	SegwitHeight:  0,
+
+	PubKeyHashAddrID: 0x6f,
+	ScriptHashAddrID: 0xc4,
+	PrivateKeyID:     0xef,
+	Bech32HRPSegwit:  "bcrt",
+	HDPrivateKeyID:   [4]byte{0x04, 0x35, 0x83, 0x94},
+	HDPublicKeyID:    [4]byte{0x04, 0x35, 0x87, 0xcf},
+}
+
+// SimNetParams represents the parameters of simnet, the private network of btcd.
+var SimNetParams = ChainParams{
+	Name:        "simnet",
+	Net:         protocol.SimNet,
+	DefaultPort: 18555,
+
+	GenesisBlock: simNetGenesisBlock,
+	GenesisHash:  simNetGenesisBlock.BlockHash(),
+
+	PowLimit:                 regTestPowLimit,
+	PowLimitBits:             0x207fffff,
+	TargetTimespan:           14 * 24 * time.Hour,
+	TargetTimePerBlock:       10 * time.Minute,
+	RetargetAdjustmentFactor: 4,
+	ReduceMinDifficulty:      true,
+	MinDiffReductionTime:     20 * time.Minute,
+
+	BIP0034Height: 0,
+	BIP0065Height: 0,
+	BIP0066Height: 0,
+	CSVHeight:     0,
+	SegwitHeight:  0,
+
+	PubKeyHashAddrID: 0x3f,
+	ScriptHashAddrID: 0x7b,
+	PrivateKeyID:     0x64,
+	Bech32HRPSegwit:  "sb",
+	HDPrivateKeyID:   [4]byte{0x04, 0x20, 0xb9, 0x00},
+	HDPublicKeyID:    [4]byte{0x04, 0x20, 0xbd, 0x3a},
+}
+
+var (
+	// registeredMtx protects registered.
+	registeredMtx sync.RWMutex
+	// registered holds registered network parameters by network magic.
+	registered = map[protocol.BitcoinNet]*ChainParams{}
+)
+
+func init() {
+	for _, params := range []*ChainParams{
+		&MainNetParams,
+		&TestNet3Params,
+		&TestNet4Params,
+		&SigNetParams,
+		&RegTestParams,
+		&SimNetParams,
+	} {
+		err := Register(params)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register registers params, so they can be looked up by network magic or name,
+// and messages carrying their network magic are decoded.
+// Registering identical parameters again does nothing,
+// while registering other parameters of an already registered network fails.
+func Register(params *ChainParams) error {
+	registeredMtx.Lock()
+	defer registeredMtx.Unlock()
+
+	if existing, ok := registered[params.Net]; ok {
+		if reflect.DeepEqual(existing, params) {
+			return nil
+		}
+
+		return fmt.Errorf("%w (0x%08x)", ErrDuplicateNet, uint32(params.Net))
+	}
+
+	registered[params.Net] = params
+	protocol.RegisterBitcoinNet(params.Net)
+
+	return nil
+}
+
+// ParamsForNet returns the registered parameters of net.
+func ParamsForNet(net protocol.BitcoinNet) (*ChainParams, error) {
+	registeredMtx.RLock()
+	defer registeredMtx.RUnlock()
+
+	if params, ok := registered[net]; ok {
+		return params, nil
+	}
+
+	return nil, fmt.Errorf("%w (0x%08x)", ErrUnknownNet, uint32(net))
+}
+
+// ParamsForName returns the registered parameters of the network called name.
+func ParamsForName(name string) (*ChainParams, error) {
+	registeredMtx.RLock()
+	defer registeredMtx.RUnlock()
+
+	for _, params := range registered {
+		if params.Name == name {
+			return params, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w (%s)", ErrUnknownNet, name)
+}
@@ -0,0 +1,77 @@
+package chaincfg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestParams(t *testing.T) {
+	tests := []struct {
+		params  *ChainParams
+		genesis string
+	}{
+		{params: &MainNetParams, genesis: "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"},
+		{params: &TestNet3Params, genesis: "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943"},
+		{params: &TestNet4Params, genesis: "00000000da84f2bafbbc53dee25a72ae507ff4914b867c565be350b0da8bf043"},
+		{params: &SigNetParams, genesis: "00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6"},
+		{params: &RegTestParams, genesis: "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206"},
+		{params: &SimNetParams, genesis: "683e86bd5c6d110d91b94b97137ba6bfe02dbbdb8e3dff722a669b5d69d77af6"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.params.Name, func(t *testing.T) {
+			if test.params.GenesisHash.String() != test.genesis {
+				t.Errorf("Wrong genesis hash (%s)", test.params.GenesisHash)
+			}
+
+			err := test.params.GenesisBlock.CheckMerkleRoot()
+			if err != nil {
+				t.Errorf("Wrong genesis merkle root (%s)", err)
+			}
+
+			err = test.params.GenesisBlock.Header.CheckProofOfWork(test.params.PowLimit)
+			if err != nil {
+				t.Errorf("Wrong genesis proof of work (%s)", err)
+			}
+
+			if protocol.BigToCompact(test.params.PowLimit) != test.params.PowLimitBits {
+				t.Errorf("Wrong pow limit bits (0x%08x)", test.params.PowLimitBits)
+			}
+
+			if test.params.RetargetInterval() != 2016 {
+				t.Errorf("Wrong retarget interval (%d)", test.params.RetargetInterval())
+			}
+
+			params, err := ParamsForNet(test.params.Net)
+			if err != nil || params != test.params {
+				t.Errorf("Wrong params for net (%v)", err)
+			}
+
+			params, err = ParamsForName(test.params.Name)
+			if err != nil || params != test.params {
+				t.Errorf("Wrong params for name (%v)", err)
+			}
+		})
+	}
+
+	t.Run("should NOT find unknown network", func(t *testing.T) {
+		_, err := ParamsForNet(0xbbb5bff1)
+		if !errors.Is(err, ErrUnknownNet) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+
+		_, err = ParamsForName("unknown")
+		if !errors.Is(err, ErrUnknownNet) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+
+	t.Run("should NOT register network twice", func(t *testing.T) {
+		err := Register(&ChainParams{Net: protocol.MainNet})
+		if !errors.Is(err, ErrDuplicateNet) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+}
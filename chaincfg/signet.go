@@ -0,0 +1,44 @@
+package chaincfg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// sigNetChallenge represents the challenge script of the default signet, a 1-of-2 multisig.
+var sigNetChallenge = mustDecodeHex("512103ad5e0edad18cb1f0fc0d28a3d4f1f3e445640337489abb10404f2d1e086be430210359ef5021964fe22d6f8e05b2463c9540ce96883fe3b278760f048f5189f2e6c452ae")
+
+// SigNetMagic returns the network magic of the signet defined by challenge,
+// the first 4 bytes of sha256(sha256(challenge)), being challenge prefixed by its length.
+// https://github.com/bitcoin/bips/blob/master/bip-0325.mediawiki#message-start
+func SigNetMagic(challenge []byte) protocol.BitcoinNet {
+	b := bytes.NewBuffer([]byte{})
+
+	// Encoding into a buffer never fails
+	vi := msg.VarInt{Length: uint(len(challenge))}
+	_ = vi.Encode(b)
+	b.Write(challenge)
+
+	hash := protocol.DoubleHash(b.Bytes())
+
+	return protocol.BitcoinNet(binary.LittleEndian.Uint32(hash[:4]))
+}
+
+// CustomSigNetParams returns the parameters of the signet defined by challenge.
+// Custom signets share genesis block and rules with the default one,
+// only network magic, name and DNS seeds differ.
+// The returned parameters still must be registered with Register.
+func CustomSigNetParams(challenge []byte, seeds []string) *ChainParams {
+	params := SigNetParams
+
+	params.Net = SigNetMagic(challenge)
+	params.Name = fmt.Sprintf("signet-%08x", uint32(params.Net))
+	params.DNSSeeds = seeds
+	params.SigNetChallenge = append([]byte{}, challenge...)
+
+	return &params
+}
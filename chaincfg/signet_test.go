@@ -0,0 +1,119 @@
+package chaincfg
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestSigNetMagic(t *testing.T) {
+	t.Run("should derive default signet magic", func(t *testing.T) {
+		net := SigNetMagic(SigNetParams.SigNetChallenge)
+		if net != protocol.SigNet {
+			t.Errorf("Wrong signet magic (0x%08x)", uint32(net))
+		}
+	})
+
+	t.Run("should derive custom signet magic", func(t *testing.T) {
+		// 1-of-1 multisig
+		challenge := mustDecodeHex("5121020000000000000000000000000000000000000000000000000000000000000001" + "51ae")
+
+		net := SigNetMagic(challenge)
+		if net == protocol.SigNet {
+			t.Error("Custom signet magic should differ from default signet magic")
+		}
+
+		if SigNetMagic(challenge) != net {
+			t.Error("Signet magic should be deterministic")
+		}
+	})
+}
+
+// registerScoped registers params until test t finishes.
+func registerScoped(t *testing.T, params *ChainParams) error {
+	err := Register(params)
+	if err != nil {
+		return err
+	}
+
+	t.Cleanup(func() {
+		registeredMtx.Lock()
+		delete(registered, params.Net)
+		registeredMtx.Unlock()
+
+		protocol.UnregisterBitcoinNet(params.Net)
+	})
+
+	return nil
+}
+
+func TestCustomSigNetParams(t *testing.T) {
+	challenge := mustDecodeHex("51")
+	seeds := []string{"seed.signet.example.com"}
+
+	params := CustomSigNetParams(challenge, seeds)
+
+	if params.Net != SigNetMagic(challenge) {
+		t.Errorf("Wrong network magic (0x%08x)", uint32(params.Net))
+	}
+
+	if params.GenesisHash != SigNetParams.GenesisHash {
+		t.Errorf("Wrong genesis hash (%s)", params.GenesisHash)
+	}
+
+	if !bytes.Equal(params.SigNetChallenge, challenge) || len(params.DNSSeeds) != 1 {
+		t.Error("Wrong challenge or DNS seeds")
+	}
+
+	if SigNetParams.Net != protocol.SigNet || len(SigNetParams.DNSSeeds) != 2 {
+		t.Error("Default signet params should NOT be modified")
+	}
+
+	t.Run("should NOT decode messages before registering", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+		msg.WriteMessage(b, &msg.Ping{Nonce: 1}, 70016, params.Net)
+
+		_, err := msg.ReadMessage(b, 70016, params.Net)
+		if err == nil {
+			t.Error("Message of unregistered network should NOT be decoded")
+		}
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		err := registerScoped(t, params)
+		if err != nil {
+			t.Fatalf("Unable to register (%s)", err)
+		}
+
+		// Identical params are registered again
+		err = Register(CustomSigNetParams(challenge, seeds))
+		if err != nil {
+			t.Errorf("Identical params should be registered again (%s)", err)
+		}
+
+		err = Register(CustomSigNetParams(challenge, nil))
+		if !errors.Is(err, ErrDuplicateNet) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+
+		registered, err := ParamsForName(params.Name)
+		if err != nil || registered != params {
+			t.Errorf("Wrong params for name (%v)", err)
+		}
+
+		b := bytes.NewBuffer([]byte{})
+		msg.WriteMessage(b, &msg.Ping{Nonce: 1}, 70016, params.Net)
+
+		m, err := msg.ReadMessage(b, 70016, params.Net)
+		if err != nil {
+			t.Fatalf("Unable to read message (%s)", err)
+		}
+
+		if ping, ok := m.(*msg.Ping); !ok || ping.Nonce != 1 {
+			t.Errorf("Wrong message (%v)", m)
+		}
+	})
+}
@@ -1,102 +1,534 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/elmarsan/havel/addrmgr"
+	"github.com/elmarsan/havel/blockchain"
+	"github.com/elmarsan/havel/blockstore"
 	"github.com/elmarsan/havel/msg"
 	"github.com/elmarsan/havel/protocol"
 )
 
+// handshakeTimeout represents the maximum time allowed to complete version handshake.
+const handshakeTimeout = 30 * time.Second
+
+// defaultPingInterval represents the time between pings sent to every peer, unless Client sets other.
+const defaultPingInterval = 2 * time.Minute
+
+// defaultPingTimeout represents the maximum time waiting for pong before disconnecting peer,
+// unless Client sets other.
+const defaultPingTimeout = 20 * time.Minute
+
+// syncServices represents the services of peers blocks are synced from.
+const syncServices = protocol.NODE_NETWORK | protocol.NODE_WITNESS
+
+// ErrObsoleteVersion is returned when a peer protocol version is lower than the minimum accepted by Client.
+var ErrObsoleteVersion = errors.New("Peer protocol version is obsolete")
+
+// ErrMissingServices is returned when an outbound peer does not offer the services required by Client.
+var ErrMissingServices = errors.New("Peer does not offer required services")
+
 // Client represents Bitcoin network client
 type Client struct {
 	// version represents the protocol version used by the node.
 	version uint32
 	// net represents Bitcoin network (mainnet, testnet, etc...)
 	net protocol.BitcoinNet
+	// minVersion represents the minimum protocol version of accepted peers, protocol.MinPeerVersion if zero.
+	minVersion uint32
+	// services represents the services announced to peers.
+	services protocol.ServiceFlag
+	// requiredServices represents the services outbound peers must offer, those lacking any are disconnected.
+	requiredServices protocol.ServiceFlag
+	// pingInterval represents the time between pings sent to every peer.
+	pingInterval time.Duration
+	// pingTimeout represents the maximum time waiting for pong before disconnecting peer.
+	pingTimeout time.Duration
+	// stallTimeout represents the maximum time a peer may hold requested blocks without delivering any
+	// before disconnecting it, defaultStallTimeout if zero.
+	stallTimeout time.Duration
+	// headersTimeout represents the maximum time the sync peer may take to answer getheaders
+	// before disconnecting it, defaultHeadersTimeout if zero.
+	headersTimeout time.Duration
+	// maxOutbound represents the maximum number of outbound peers.
+	maxOutbound int
+	// maxInbound represents the maximum number of inbound peers.
+	maxInbound int
+	// maxInboundPerGroup represents the maximum number of inbound peers from the same network group.
+	maxInboundPerGroup int
+	// resolver resolves DNS seeds, net.DefaultResolver if nil.
+	resolver Resolver
+	// dial connects to peer addresses, net.Dialer with handshakeTimeout if nil.
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// store holds downloaded blocks, block download resumes after its best height when set.
+	store *blockstore.Store
+
+	// mtx protects peers, addrs, headers, syncPeer, syncRequested, download, requested, rejects and nonces.
+	mtx sync.Mutex
+	// peers manages client connected peers.
+	peers *peerManager
+	// addrs holds known node addresses of every network type, learnt from addr and addrv2 msgs.
+	addrs *addrmgr.AddrManager
+	// headers holds the validated block headers of client network.
+	headers *blockchain.HeaderTree
+	// syncPeer represents the peer headers are being synced from, nil when not syncing.
+	syncPeer *Peer
+	// syncRequested represents when headers were last requested to syncPeer.
+	syncRequested time.Time
+	// download schedules block downloads over connected peers.
+	download *downloader
+	// requested holds inventory vectors requested to peers and not received yet, with the peer they were requested to.
+	requested map[msg.InvVec]*Peer
+	// rejects receives reject msgs sent by connected peers.
+	rejects chan *RejectEvent
+	// nonces holds the nonces of version msgs sent in ongoing handshakes,
+	// used for detecting connections to ourselves.
+	nonces map[uint64]struct{}
+}
+
+// AddPeer connects to the node listening on addr, completes version handshake
+// and saves it as connected peer.
+// Reject msgs received during handshake are returned as *msg.RejectError.
+func (c *Client) AddPeer(addr string) error {
+	pm := c.peerManager()
+
+	err := pm.available(false, "")
+	if err != nil {
+		return err
+	}
+
+	netAddr, err := parseNetAddr(addr)
+	if err == nil {
+		c.addrManager().Attempt(netAddr)
+	}
+
+	dial := c.dial
+	if dial == nil {
+		dialer := net.Dialer{Timeout: handshakeTimeout}
+		dial = dialer.DialContext
+	}
+
+	conn, err := dial(pm.ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("Unable to connect peer (%s)", err)
+	}
+
+	peer, err := c.handshake(conn, false)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	// Remember peer address as connectable
+	peerAddr, err := msg.NewNetAddrV2(peer.addr)
+	if err == nil {
+		c.addrManager().Add([]*msg.NetAddrV2{peerAddr}, peerAddr)
+		c.addrManager().Good(peerAddr)
+	}
+
+	err = c.startPeer(peer)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	// Ask the new peer for other nodes addresses
+	err = c.SendTo(peer, &msg.GetAddr{})
+	if err != nil {
+		return err
+	}
+
+	err = c.startSync(peer)
+	if err != nil {
+		return err
+	}
+
+	c.scheduleBlocks()
+
+	return nil
+}
+
+// RemovePeer disconnects peer and removes it from connected peers.
+func (c *Client) RemovePeer(peer *Peer) {
+	if !c.peerManager().remove(peer) {
+		return
+	}
 
-	// peers represents client connected peers.
-	peers []Peer
+	peer.conn.Close()
+	close(peer.quit)
+
+	c.stopSync(peer)
+	c.forgetRequests(peer)
+	c.releaseBlocks(peer)
 }
 
-// Peer represents Bitcoin network node.
-type Peer struct {
-	// conn holds the connection to the peer.
-	conn net.Conn
+// Peers returns connected peers.
+func (c *Client) Peers() []*Peer {
+	return c.peerManager().all()
 }
 
-// TODO: Implement
-// 1 - Connected to peer
-// 2 - Send version msg
-// 3 - Send verack msg
-// 4 - Save peer and keep dial openned
-func (c *Client) AddPeer(addr string) error {
-	fields := strings.Split(addr, ":")
-	ip := net.ParseIP(addr)
-	port, err := strconv.Atoi(fields[1])
+// PeersWithServices returns connected peers offering every service of required,
+// e.g. syncServices for peers able to serve witness blocks of the whole chain.
+func (c *Client) PeersWithServices(required protocol.ServiceFlag) []*Peer {
+	peers := []*Peer{}
+	for _, peer := range c.Peers() {
+		if peer.services.Has(required) {
+			peers = append(peers, peer)
+		}
+	}
+
+	return peers
+}
+
+// Run blocks until ctx is cancelled, then disconnects every peer and waits for their goroutines.
+// Peers cannot be added once Run returns.
+func (c *Client) Run(ctx context.Context) {
+	<-ctx.Done()
+
+	c.peerManager().shutdown(c.RemovePeer)
+}
+
+// peerManager returns client peer manager, creating it if needed.
+func (c *Client) peerManager() *peerManager {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.peers == nil {
+		maxOutbound := c.maxOutbound
+		if maxOutbound == 0 {
+			maxOutbound = defaultMaxOutbound
+		}
+
+		maxInbound := c.maxInbound
+		if maxInbound == 0 {
+			maxInbound = defaultMaxInbound
+		}
+
+		maxInboundPerGroup := c.maxInboundPerGroup
+		if maxInboundPerGroup == 0 {
+			maxInboundPerGroup = defaultMaxInboundPerGroup
+		}
+
+		c.peers = newPeerManager(maxOutbound, maxInbound, maxInboundPerGroup)
+	}
+
+	return c.peers
+}
+
+// handshake performs version handshake over conn:
+// 1 - Send version msg
+// 2 - Receive peer's version msg and answer it with verack msg
+// 3 - Receive peer's verack msg
+// Peer's version and verack msgs are accepted in any order.
+// On inbound connections, version msg is sent once peer's version msg is received.
+func (c *Client) handshake(conn net.Conn, inbound bool) (*Peer, error) {
+	err := conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := c.newNonce()
+	if err != nil {
+		return nil, err
+	}
+	defer c.deleteNonce(nonce)
+
+	if !inbound {
+		err = c.sendVersion(conn, nonce)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to send version (%s)", err)
+		}
+	}
+
+	peer := &Peer{
+		conn:     conn,
+		inbound:  inbound,
+		outbound: make(chan msg.Message, outboundQueueSize),
+		quit:     make(chan struct{}),
+	}
+
+	var versionRecv, verackRecv bool
+	for !versionRecv || !verackRecv {
+		m, err := msg.ReadMessage(conn, c.version, c.net)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read msg (%s)", err)
+		}
+
+		switch m := m.(type) {
+		case *msg.Version:
+			if versionRecv {
+				return nil, fmt.Errorf("Duplicated version msg")
+			}
+
+			if c.ownNonce(m.Nonce) {
+				return nil, fmt.Errorf("Connected to self")
+			}
+
+			if m.Version < c.minPeerVersion() {
+				reject := &msg.Reject{
+					Cmd:    protocol.VersionCmd,
+					Code:   msg.RejectObsolete,
+					Reason: fmt.Sprintf("Version must be %d or greater", c.minPeerVersion()),
+				}
+
+				// Best effort, peer is disconnected anyway
+				_ = msg.WriteMessage(conn, reject, m.Version, c.net)
+
+				return nil, fmt.Errorf("%w (%d), minimum (%d)", ErrObsoleteVersion, m.Version, c.minPeerVersion())
+			}
+
+			if !inbound && !m.Services.Has(c.requiredServices) {
+				return nil, fmt.Errorf("%w (%s), required (%s)", ErrMissingServices, m.Services, c.requiredServices)
+			}
+
+			peer.addr, err = newNetAddr(conn.RemoteAddr())
+			if err != nil {
+				return nil, err
+			}
+			peer.addr.Services = m.Services
+			peer.addr.Timestamp = time.Now()
+
+			peer.version = m.Version
+			if c.version < peer.version {
+				peer.version = c.version
+			}
+			peer.services = m.Services
+			peer.userAgent = m.UserAgent.Val
+			peer.startHeight = m.StartHeight
+
+			if inbound {
+				err = c.sendVersion(conn, nonce)
+				if err != nil {
+					return nil, fmt.Errorf("Unable to send version (%s)", err)
+				}
+			}
+
+			// Signal addrv2 support before verack
+			if peer.version >= protocol.AddrV2Version {
+				err = msg.WriteMessage(conn, &msg.SendAddrV2{}, c.version, c.net)
+				if err != nil {
+					return nil, fmt.Errorf("Unable to send sendaddrv2 (%s)", err)
+				}
+			}
+
+			err = msg.WriteMessage(conn, &msg.Verack{}, c.version, c.net)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to send verack (%s)", err)
+			}
+
+			versionRecv = true
+		case *msg.Verack:
+			if verackRecv {
+				return nil, fmt.Errorf("Duplicated verack msg")
+			}
+
+			verackRecv = true
+		case *msg.SendAddrV2:
+			// sendaddrv2 is only meaningful before verack
+			if !verackRecv {
+				peer.addrV2 = true
+			}
+		case *msg.Reject:
+			return nil, &msg.RejectError{Reject: m}
+		default:
+			// Msgs different from version and verack are not expected
+			// before handshake completion, ignore them.
+		}
+	}
+
+	// Remove handshake deadline
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	return peer, nil
+}
+
+// minPeerVersion returns the minimum protocol version of accepted peers.
+func (c *Client) minPeerVersion() uint32 {
+	if c.minVersion == 0 {
+		return protocol.MinPeerVersion
+	}
+
+	return c.minVersion
+}
+
+// sendVersion sends version msg with nonce into conn.
+func (c *Client) sendVersion(conn net.Conn, nonce uint64) error {
+	recvAddr, err := newNetAddr(conn.RemoteAddr())
+	if err != nil {
+		return err
+	}
+
+	fromAddr, err := newNetAddr(conn.LocalAddr())
 	if err != nil {
 		return err
 	}
 
+	// Announce best header height, so peers behind can sync from client
+	var startHeight uint32
+	if tree, err := c.headerTree(); err == nil {
+		startHeight = uint32(tree.Best().Height)
+	}
+
 	version := msg.Version{
-		Header: &msg.Header{
-			Magic: c.net,
-			Cmd: protocol.BitcoinCmd{
-				HexData: protocol.VersionCmdData,
-				Name:    protocol.VersionCmd,
-			},
-			Length:   0x64,
-			Checksum: 0x358d4932,
-		},
 		Version:   c.version,
-		Services:  0x00000001,
+		Services:  c.services,
 		Timestamp: time.Now(),
-		Nonce:     0x6517e68c5db32e3b,
-		RecvAddr: &msg.NetAddr{
-			Ip:       ip,
-			Port:     uint16(port),
-			Services: 1,
-		},
-		FromAddr: &msg.NetAddr{
-			Ip:       ip,
-			Port:     uint16(port),
-			Services: 1,
-		},
+		Nonce:     nonce,
+		RecvAddr:  recvAddr,
+		FromAddr:  fromAddr,
 		UserAgent: &msg.VarStr{
 			VarInt: msg.VarInt{
 				Length: 13,
 			},
 			Val: "/Havel:0.0.1/",
 		},
-		StartHeight: 0,
+		StartHeight: startHeight,
+	}
+
+	return msg.WriteMessage(conn, &version, c.version, c.net)
+}
+
+// addrManager returns client address manager, creating it if needed.
+func (c *Client) addrManager() *addrmgr.AddrManager {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.addrs == nil {
+		c.addrs = addrmgr.New()
+	}
+
+	return c.addrs
+}
+
+// knownAddrs returns up to msg.MaxAddrPerMsg known node addresses,
+// connected peers addresses come first.
+func (c *Client) knownAddrs() []*msg.NetAddrV2 {
+	addrs := []*msg.NetAddrV2{}
+	seen := map[string]struct{}{}
+
+	for _, peer := range c.Peers() {
+		if len(addrs) >= msg.MaxAddrPerMsg {
+			return addrs
+		}
+
+		addr, err := msg.NewNetAddrV2(peer.addr)
+		if err != nil {
+			continue
+		}
+
+		addrs = append(addrs, addr)
+		seen[addrmgr.AddrKey(addr)] = struct{}{}
 	}
 
-	conn, err := net.Dial("tcp", addr)
+	for _, addr := range c.addrManager().Addresses(msg.MaxAddrPerMsg) {
+		if len(addrs) >= msg.MaxAddrPerMsg {
+			return addrs
+		}
+
+		if _, ok := seen[addrmgr.AddrKey(addr)]; ok {
+			continue
+		}
+
+		addrs = append(addrs, addr)
+	}
+
+	return addrs
+}
+
+// newNonce returns random nonce, saving it as one of client's ongoing handshake nonces.
+func (c *Client) newNonce() (uint64, error) {
+	nonce, err := randUint64()
 	if err != nil {
-		return fmt.Errorf("Unable to connect peer (%s)", err)
+		return 0, err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.nonces == nil {
+		c.nonces = map[uint64]struct{}{}
+	}
+	c.nonces[nonce] = struct{}{}
+
+	return nonce, nil
+}
+
+// ownNonce returns whether nonce belongs to one of client's ongoing handshakes.
+func (c *Client) ownNonce(nonce uint64) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	_, ok := c.nonces[nonce]
+	return ok
+}
+
+// deleteNonce removes nonce from client's ongoing handshake nonces.
+func (c *Client) deleteNonce(nonce uint64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	delete(c.nonces, nonce)
+}
+
+// randUint64 returns random uint64.
+func randUint64() (uint64, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return 0, err
 	}
 
-	defer conn.Close()
+	return binary.LittleEndian.Uint64(b), nil
+}
 
-	versionData := bytes.NewBuffer([]byte{})
-	err = version.Encode(versionData)
+// newNetAddr returns NetAddr from TCP addr.
+func newNetAddr(addr net.Addr) (*msg.NetAddr, error) {
+	host, portStr, err := net.SplitHostPort(addr.String())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = conn.Write(versionData.Bytes())
+	port, err := strconv.Atoi(portStr)
 	if err != nil {
-		return fmt.Errorf("Unable to send version: %v\n", err)
+		return nil, err
 	}
 
-	// _, err := ioutil.ReadAll(conn)
-	// if err != nil {
-	// 	return err
-	// }
+	return &msg.NetAddr{
+		Services: 0,
+		Ip:       net.ParseIP(host),
+		Port:     uint16(port),
+	}, nil
+}
 
-	return nil
+// parseNetAddr returns NetAddrV2 from "host:port" addr, where host must be an IP address.
+// Host names are not resolved.
+func parseNetAddr(addr string) (*msg.NetAddrV2, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("Host is not an IP address (%s)", addr)
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("Wrong port (%s)", addr)
+	}
+
+	return msg.NewNetAddrV2(&msg.NetAddr{Ip: ip, Port: uint16(port)})
 }
@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// listen starts TCP listener on loopback, calling handle with every accepted connection.
+func listen(t *testing.T, handle func(conn net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen (%s)", err)
+	}
+
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go handle(conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+// write writes m into the connection of peer, which was only handshaked by remote.
+func write(remote *Client, peer *Peer, m msg.Message) error {
+	return msg.WriteMessage(peer.conn, m, peer.version, remote.net)
+}
+
+// connectRemote adds to client a remote peer, which is handled by handle once handshake
+// is completed and the getaddr msg sent by client is consumed.
+func connectRemote(t *testing.T, client *Client, handle func(remote *Client, peer *Peer)) {
+	remote := &Client{
+		version: client.version,
+		net:     client.net,
+	}
+
+	addr := listen(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		peer, err := remote.handshake(conn, true)
+		if err != nil {
+			return
+		}
+
+		_, err = msg.ReadMessage(conn, peer.version, remote.net)
+		if err != nil {
+			return
+		}
+
+		handle(remote, peer)
+	})
+
+	err := client.AddPeer(addr)
+	if err != nil {
+		t.Fatalf("Unable to add peer (%s)", err)
+	}
+}
+
+func TestAddPeer(t *testing.T) {
+	t.Run("should complete handshake", func(t *testing.T) {
+		remote := &Client{
+			version: 70015,
+			net:     protocol.MainNet,
+		}
+
+		addr := listen(t, func(conn net.Conn) {
+			remote.handshake(conn, true)
+		})
+
+		client := &Client{
+			version: 0xea62,
+			net:     protocol.MainNet,
+		}
+
+		err := client.AddPeer(addr)
+		if err != nil {
+			t.Fatalf("Unable to add peer (%s)", err)
+		}
+
+		if len(client.Peers()) != 1 {
+			t.Fatalf("Wrong number of peers (%d)", len(client.Peers()))
+		}
+
+		peer := client.Peers()[0]
+		if peer.version != 0xea62 {
+			t.Errorf("Wrong negotiated version (%d)", peer.version)
+		}
+
+		if peer.userAgent != "/Havel:0.0.1/" {
+			t.Errorf("Wrong user agent (%s)", peer.userAgent)
+		}
+	})
+
+	t.Run("should NOT connect to self", func(t *testing.T) {
+		remote := &Client{
+			version: 0xea62,
+			net:     protocol.MainNet,
+		}
+
+		addr := listen(t, func(conn net.Conn) {
+			defer conn.Close()
+
+			m, err := msg.ReadMessage(conn, remote.version, remote.net)
+			if err != nil {
+				return
+			}
+
+			version, ok := m.(*msg.Version)
+			if !ok {
+				return
+			}
+
+			// Answer with the received nonce, as if we were talking to ourselves
+			remote.sendVersion(conn, version.Nonce)
+			msg.WriteMessage(conn, &msg.Verack{}, remote.version, remote.net)
+		})
+
+		client := &Client{
+			version: 0xea62,
+			net:     protocol.MainNet,
+		}
+
+		err := client.AddPeer(addr)
+		if err == nil {
+			t.Error("Connection to self should have been rejected")
+		}
+
+		if len(client.Peers()) != 0 {
+			t.Errorf("Wrong number of peers (%d)", len(client.Peers()))
+		}
+	})
+
+	t.Run("should NOT accept peer from different network", func(t *testing.T) {
+		remote := &Client{
+			version: 0xea62,
+			net:     protocol.TestNet3,
+		}
+
+		addr := listen(t, func(conn net.Conn) {
+			defer conn.Close()
+			remote.handshake(conn, true)
+		})
+
+		client := &Client{
+			version: 0xea62,
+			net:     protocol.MainNet,
+		}
+
+		err := client.AddPeer(addr)
+		if err == nil {
+			t.Error("Peer from different network should have been rejected")
+		}
+	})
+
+	t.Run("should NOT accept peer without required services", func(t *testing.T) {
+		remote := &Client{
+			version:  0xea62,
+			net:      protocol.MainNet,
+			services: protocol.NODE_NETWORK_LIMITED | protocol.NODE_WITNESS,
+		}
+
+		addr := listen(t, func(conn net.Conn) {
+			defer conn.Close()
+			remote.handshake(conn, true)
+		})
+
+		client := &Client{
+			version:          0xea62,
+			net:              protocol.MainNet,
+			requiredServices: syncServices,
+		}
+
+		err := client.AddPeer(addr)
+		if !errors.Is(err, ErrMissingServices) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+
+		if len(client.Peers()) != 0 {
+			t.Errorf("Wrong number of peers (%d)", len(client.Peers()))
+		}
+	})
+}
+
+func TestMinPeerVersion(t *testing.T) {
+	remote := &Client{
+		version: protocol.SendHeadersVersion,
+		net:     protocol.MainNet,
+	}
+
+	rejects := make(chan *msg.Reject, 1)
+
+	addr := listen(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		err := remote.sendVersion(conn, 1)
+		if err != nil {
+			return
+		}
+
+		for {
+			m, err := msg.ReadMessage(conn, remote.version, remote.net)
+			if err != nil {
+				return
+			}
+
+			if reject, ok := m.(*msg.Reject); ok {
+				rejects <- reject
+				return
+			}
+		}
+	})
+
+	client := &Client{
+		version:    protocol.ProtocolVersion,
+		net:        protocol.MainNet,
+		minVersion: protocol.FeeFilterVersion,
+	}
+
+	err := client.AddPeer(addr)
+	if !errors.Is(err, ErrObsoleteVersion) {
+		t.Errorf("Wrong error (%v)", err)
+	}
+
+	select {
+	case reject := <-rejects:
+		if reject.Cmd != protocol.VersionCmd || reject.Code != msg.RejectObsolete {
+			t.Errorf("Wrong reject (%s, %s)", reject.Cmd, reject.Code)
+		}
+	case <-time.After(time.Second):
+		t.Error("Obsolete peer should have been rejected")
+	}
+
+	if len(client.Peers()) != 0 {
+		t.Errorf("Wrong number of peers (%d)", len(client.Peers()))
+	}
+}
+
+func TestPeersWithServices(t *testing.T) {
+	client := &Client{
+		version: 0xea62,
+		net:     protocol.MainNet,
+	}
+
+	for _, services := range []protocol.ServiceFlag{
+		protocol.NODE_NETWORK | protocol.NODE_WITNESS,
+		protocol.NODE_NETWORK_LIMITED | protocol.NODE_WITNESS,
+		protocol.NODE_NETWORK,
+	} {
+		remote := &Client{
+			version:  0xea62,
+			net:      protocol.MainNet,
+			services: services,
+		}
+
+		addr := listen(t, func(conn net.Conn) {
+			remote.handshake(conn, true)
+		})
+
+		err := client.AddPeer(addr)
+		if err != nil {
+			t.Fatalf("Unable to add peer (%s)", err)
+		}
+	}
+
+	peers := client.PeersWithServices(syncServices)
+	if len(peers) != 1 || peers[0].services != protocol.NODE_NETWORK|protocol.NODE_WITNESS {
+		t.Errorf("Wrong peers with sync services (%d)", len(peers))
+	}
+
+	if len(client.PeersWithServices(protocol.NODE_WITNESS)) != 2 {
+		t.Error("Wrong number of witness peers")
+	}
+
+	if len(client.PeersWithServices(protocol.NODE_NONE)) != 3 {
+		t.Error("Every peer should offer no service")
+	}
+}
+
+func TestAddrGossip(t *testing.T) {
+	gossip := []*msg.NetAddr{
+		{
+			Timestamp: time.Now(),
+			Services:  0x00000001,
+			Ip:        net.ParseIP("11.0.0.1"),
+			Port:      8333,
+		},
+		{
+			Timestamp: time.Now(),
+			Services:  0x00000001,
+			Ip:        net.ParseIP("11.0.0.2"),
+			Port:      8333,
+		},
+		{
+			// Unroutable address should be ignored
+			Ip:   net.IPv4zero,
+			Port: 8333,
+		},
+	}
+
+	remote := &Client{
+		version: 0xea62,
+		net:     protocol.MainNet,
+	}
+
+	replies := make(chan *msg.Addr, 1)
+
+	addr := listen(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		peer, err := remote.handshake(conn, true)
+		if err != nil {
+			return
+		}
+
+		// Client asks for addresses right after handshake
+		m, err := msg.ReadMessage(conn, peer.version, remote.net)
+		if err != nil {
+			return
+		}
+
+		if _, ok := m.(*msg.GetAddr); !ok {
+			return
+		}
+
+		err = msg.WriteMessage(conn, &msg.Addr{AddrList: gossip}, peer.version, remote.net)
+		if err != nil {
+			return
+		}
+
+		err = msg.WriteMessage(conn, &msg.GetAddr{}, peer.version, remote.net)
+		if err != nil {
+			return
+		}
+
+		m, err = msg.ReadMessage(conn, peer.version, remote.net)
+		if err != nil {
+			return
+		}
+
+		if reply, ok := m.(*msg.Addr); ok {
+			replies <- reply
+		}
+	})
+
+	client := &Client{
+		version: 0xea62,
+		net:     protocol.MainNet,
+	}
+
+	err := client.AddPeer(addr)
+	if err != nil {
+		t.Fatalf("Unable to add peer (%s)", err)
+	}
+
+	var reply *msg.Addr
+	select {
+	case reply = <-replies:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Getaddr was not answered")
+	}
+
+	t.Run("should ingest addr gossip", func(t *testing.T) {
+		// Gossiped addresses, loopback peer address is not routable
+		if client.addrManager().Count() != 2 {
+			t.Errorf("Wrong number of known addresses (%d)", client.addrManager().Count())
+		}
+	})
+
+	t.Run("should answer getaddr with known addresses", func(t *testing.T) {
+		// Connected peer plus gossiped addresses
+		if len(reply.AddrList) != 3 {
+			t.Errorf("Wrong number of addresses (%d)", len(reply.AddrList))
+		}
+	})
+}
+
+func TestAddrV2Gossip(t *testing.T) {
+	torV3 := &msg.NetAddrV2{
+		Timestamp: time.Now(),
+		Services:  0x00000001,
+		NetworkID: msg.NetTorV3,
+		Addr:      bytes.Repeat([]byte{0xab}, 32),
+		Port:      8333,
+	}
+
+	remote := &Client{
+		version: protocol.AddrV2Version,
+		net:     protocol.MainNet,
+	}
+
+	replies := make(chan *msg.AddrV2, 1)
+	negotiated := make(chan bool, 1)
+
+	addr := listen(t, func(conn net.Conn) {
+		defer conn.Close()
+
+		peer, err := remote.handshake(conn, true)
+		if err != nil {
+			return
+		}
+
+		negotiated <- peer.addrV2
+
+		// Client asks for addresses right after handshake
+		_, err = msg.ReadMessage(conn, peer.version, remote.net)
+		if err != nil {
+			return
+		}
+
+		err = msg.WriteMessage(conn, &msg.AddrV2{AddrList: []*msg.NetAddrV2{torV3}}, peer.version, remote.net)
+		if err != nil {
+			return
+		}
+
+		err = msg.WriteMessage(conn, &msg.GetAddr{}, peer.version, remote.net)
+		if err != nil {
+			return
+		}
+
+		m, err := msg.ReadMessage(conn, peer.version, remote.net)
+		if err != nil {
+			return
+		}
+
+		if reply, ok := m.(*msg.AddrV2); ok {
+			replies <- reply
+		}
+	})
+
+	client := &Client{
+		version: protocol.AddrV2Version,
+		net:     protocol.MainNet,
+	}
+
+	err := client.AddPeer(addr)
+	if err != nil {
+		t.Fatalf("Unable to add peer (%s)", err)
+	}
+
+	t.Run("should negotiate addrv2 during handshake", func(t *testing.T) {
+		if !<-negotiated {
+			t.Error("Remote should have received sendaddrv2")
+		}
+
+		if !client.Peers()[0].addrV2 {
+			t.Error("Client should have received sendaddrv2")
+		}
+	})
+
+	t.Run("should answer getaddr with addrv2", func(t *testing.T) {
+		var reply *msg.AddrV2
+		select {
+		case reply = <-replies:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Getaddr was not answered with addrv2")
+		}
+
+		// Connected peer plus gossiped Tor v3 address
+		if len(reply.AddrList) != 2 {
+			t.Fatalf("Wrong number of addresses (%d)", len(reply.AddrList))
+		}
+
+		if reply.AddrList[1].NetworkID != msg.NetTorV3 {
+			t.Errorf("Wrong network (%d)", reply.AddrList[1].NetworkID)
+		}
+	})
+}
+
+func TestParseNetAddr(t *testing.T) {
+	t.Run("should parse IP addresses", func(t *testing.T) {
+		tests := []struct {
+			addr      string
+			networkID msg.NetworkID
+			port      uint16
+		}{
+			{addr: "1.2.3.4:8333", networkID: msg.NetIPv4, port: 8333},
+			{addr: "[2a01:4f8::1]:18333", networkID: msg.NetIPv6, port: 18333},
+		}
+
+		for _, test := range tests {
+			netAddr, err := parseNetAddr(test.addr)
+			if err != nil {
+				t.Fatalf("Unable to parse address (%s)", err)
+			}
+
+			if netAddr.NetworkID != test.networkID || netAddr.Port != test.port {
+				t.Errorf("Wrong address (%+v)", netAddr)
+			}
+		}
+	})
+
+	t.Run("should NOT parse host names nor malformed addresses", func(t *testing.T) {
+		for _, addr := range []string{"localhost:8333", "seed.bitcoin.sipa.be:8333", "1.2.3.4", "1.2.3.4:70000"} {
+			_, err := parseNetAddr(addr)
+			if err == nil {
+				t.Errorf("Address (%s) should NOT be parsed", addr)
+			}
+		}
+	})
+}
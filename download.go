@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/elmarsan/havel/blockchain"
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// maxBlocksInFlightPerPeer represents the maximum number of blocks requested to a peer and not received yet.
+const maxBlocksInFlightPerPeer = 16
+
+// blockDownloadWindow represents how many blocks beyond the next one to deliver can be requested,
+// which bounds the number of blocks held waiting for delivery.
+const blockDownloadWindow = 1024
+
+// defaultStallTimeout represents the maximum time a peer may hold requested blocks without delivering any,
+// unless Client sets other.
+const defaultStallTimeout = 30 * time.Second
+
+// BlockEvent represents block downloaded from connected peers, emitted in height order,
+// or the disconnection of delivered blocks which left the best header chain.
+type BlockEvent struct {
+	// Height represents the height of the block within the best header chain,
+	// or the height of the fork point when Disconnect is set.
+	Height int32
+	// Block represents the downloaded block, nil when Disconnect is set.
+	Block *msg.Block
+	// Disconnect represents whether blocks delivered above Height left the best header chain,
+	// the blocks of the new best chain are delivered next.
+	Disconnect bool
+}
+
+// blockRequest represents block requested to peer and not received yet.
+type blockRequest struct {
+	// peer represents the peer the block was requested to.
+	peer *Peer
+	// height represents the height of the block.
+	height int32
+}
+
+// peerDownload represents the download state of a peer.
+type peerDownload struct {
+	// inFlight represents the number of blocks requested to the peer and not received yet.
+	inFlight int
+	// progress represents when the peer last delivered a block, or got its first block requested.
+	progress time.Time
+}
+
+// downloader schedules block requests over peers and holds received blocks until
+// they can be delivered in height order.
+type downloader struct {
+	// mtx protects next, tip, disconnect, inFlight, peers and received.
+	mtx sync.Mutex
+	// next represents the height of the next block to deliver.
+	next int32
+	// tip represents the hash of the last delivered block, zero if unknown.
+	tip protocol.Hash
+	// disconnect represents the height above which delivered blocks left the best chain, -1 if none.
+	disconnect int32
+	// inFlight holds requested blocks not received yet by hash.
+	inFlight map[protocol.Hash]*blockRequest
+	// peers holds the download state of peers with blocks in flight.
+	peers map[*Peer]*peerDownload
+	// received holds received blocks waiting for delivery by hash.
+	received map[protocol.Hash]*msg.Block
+
+	// notify signals received blocks to the delivering goroutine.
+	notify chan struct{}
+	// blocks receives delivered blocks.
+	blocks chan *BlockEvent
+}
+
+// newDownloader returns downloader whose first delivered block is the one at height next,
+// following the delivered block with hash tip.
+func newDownloader(next int32, tip protocol.Hash) *downloader {
+	return &downloader{
+		next:       next,
+		tip:        tip,
+		disconnect: -1,
+		inFlight:   map[protocol.Hash]*blockRequest{},
+		peers:      map[*Peer]*peerDownload{},
+		received:   map[protocol.Hash]*msg.Block{},
+		notify:     make(chan struct{}, 1),
+		blocks:     make(chan *BlockEvent, maxBlocksInFlightPerPeer),
+	}
+}
+
+// Blocks returns channel receiving the blocks of the best header chain in height order,
+// as they are downloaded from connected peers.
+// Downloading pauses while delivered blocks are not received.
+func (c *Client) Blocks() <-chan *BlockEvent {
+	return c.blockDownloader().blocks
+}
+
+// scheduleBlocks requests the blocks of the best header chain within the download window,
+// which are neither received nor in flight, to peers offering syncServices with room in their in-flight window.
+// Blocks which left the best header chain are forgotten first.
+func (c *Client) scheduleBlocks() {
+	tree, err := c.headerTree()
+	if err != nil {
+		return
+	}
+
+	d := c.blockDownloader()
+	best := tree.Best().Height
+	now := time.Now()
+
+	requests := map[*Peer][]*msg.InvVec{}
+
+	d.mtx.Lock()
+	rewound := d.pruneBlocks(tree)
+
+	for _, peer := range c.PeersWithServices(syncServices) {
+		state, ok := d.peers[peer]
+		if !ok {
+			state = &peerDownload{}
+		}
+
+		for height := d.next; height <= best && height < d.next+blockDownloadWindow; height++ {
+			if state.inFlight >= maxBlocksInFlightPerPeer {
+				break
+			}
+
+			node := tree.NodeByHeight(height)
+			if node == nil {
+				break
+			}
+
+			if _, ok := d.inFlight[node.Hash]; ok {
+				continue
+			}
+
+			if _, ok := d.received[node.Hash]; ok {
+				continue
+			}
+
+			if state.inFlight == 0 {
+				state.progress = now
+			}
+
+			state.inFlight++
+			d.inFlight[node.Hash] = &blockRequest{peer: peer, height: height}
+			requests[peer] = append(requests[peer], &msg.InvVec{Obj: msg.MSG_WITNESS_BLOCK, Hash: node.Hash})
+		}
+
+		if state.inFlight > 0 {
+			d.peers[peer] = state
+		}
+	}
+	d.mtx.Unlock()
+
+	if rewound {
+		select {
+		case d.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	for peer, invList := range requests {
+		c.SendTo(peer, &msg.GetData{InvList: invList})
+	}
+}
+
+// downloadRequested returns whether block with hash was requested to peer by the downloader.
+func (c *Client) downloadRequested(peer *Peer, hash protocol.Hash) bool {
+	d := c.blockDownloader()
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	req, ok := d.inFlight[hash]
+	return ok && req.peer == peer
+}
+
+// receiveBlock saves block requested to peer for delivery, then requests more blocks.
+func (c *Client) receiveBlock(peer *Peer, hash protocol.Hash, block *msg.Block) {
+	d := c.blockDownloader()
+
+	d.mtx.Lock()
+	if req, ok := d.inFlight[hash]; ok && req.peer == peer {
+		delete(d.inFlight, hash)
+
+		state := d.peers[peer]
+		state.inFlight--
+		state.progress = time.Now()
+		if state.inFlight == 0 {
+			delete(d.peers, peer)
+		}
+
+		d.received[hash] = block
+	}
+	d.mtx.Unlock()
+
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+
+	c.scheduleBlocks()
+}
+
+// releaseBlocks forgets blocks requested to peer, which are requested to other peers.
+func (c *Client) releaseBlocks(peer *Peer) {
+	d := c.blockDownloader()
+
+	d.mtx.Lock()
+	_, ok := d.peers[peer]
+	if ok {
+		for hash, req := range d.inFlight {
+			if req.peer == peer {
+				delete(d.inFlight, hash)
+			}
+		}
+
+		delete(d.peers, peer)
+	}
+	d.mtx.Unlock()
+
+	if ok && c.peerManager().ctx.Err() == nil {
+		c.scheduleBlocks()
+	}
+}
+
+// downloadBlocks delivers received blocks in height order until client shuts down.
+func (c *Client) downloadBlocks(d *downloader) {
+	ctx := c.peerManager().ctx
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.notify:
+			if !c.deliverBlocks(ctx, d) {
+				return
+			}
+
+			// Delivering moves the download window
+			c.scheduleBlocks()
+		}
+	}
+}
+
+// detectStalls disconnects stalling peers until client shuts down.
+// It runs apart from delivery, which blocks while delivered blocks are not received.
+func (c *Client) detectStalls(d *downloader) {
+	ctx := c.peerManager().ctx
+
+	timeout := c.stallTimeout
+	if timeout == 0 {
+		timeout = defaultStallTimeout
+	}
+
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, peer := range d.stallingPeers(timeout) {
+				log.Printf("Disconnecting peer %s (block download stalled)", peer.conn.RemoteAddr())
+				c.RemovePeer(peer)
+			}
+		}
+	}
+}
+
+// deliverBlocks sends received blocks following the last delivered one into blocks channel.
+// It returns false when ctx is done before blocks are received.
+func (c *Client) deliverBlocks(ctx context.Context, d *downloader) bool {
+	tree, err := c.headerTree()
+	if err != nil {
+		return true
+	}
+
+	for {
+		d.mtx.Lock()
+		if d.disconnect >= 0 {
+			event := &BlockEvent{Height: d.disconnect, Disconnect: true}
+			d.disconnect = -1
+			d.mtx.Unlock()
+
+			select {
+			case d.blocks <- event:
+			case <-ctx.Done():
+				return false
+			}
+
+			continue
+		}
+
+		node := tree.NodeByHeight(d.next)
+		if node == nil {
+			d.mtx.Unlock()
+			return true
+		}
+
+		block, ok := d.received[node.Hash]
+		if !ok {
+			d.mtx.Unlock()
+			return true
+		}
+
+		delete(d.received, node.Hash)
+		d.next++
+		d.tip = node.Hash
+		d.mtx.Unlock()
+
+		select {
+		case d.blocks <- &BlockEvent{Height: node.Height, Block: block}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// pruneBlocks forgets requested and received blocks which are not part of the best chain of tree anymore.
+// When delivered blocks left the best chain, delivery resumes after the fork point and it returns true.
+// It must be called with mtx held.
+func (d *downloader) pruneBlocks(tree *blockchain.HeaderTree) bool {
+	for hash, req := range d.inFlight {
+		node := tree.NodeByHeight(req.height)
+		if node != nil && node.Hash == hash {
+			continue
+		}
+
+		delete(d.inFlight, hash)
+
+		state := d.peers[req.peer]
+		state.inFlight--
+		if state.inFlight == 0 {
+			delete(d.peers, req.peer)
+		}
+	}
+
+	for hash := range d.received {
+		node := tree.Node(hash)
+		if node != nil && tree.NodeByHeight(node.Height) == node {
+			continue
+		}
+
+		delete(d.received, hash)
+	}
+
+	// Unknown tip is checked once its header is received
+	tip := tree.Node(d.tip)
+	if tip == nil || tree.NodeByHeight(tip.Height) == tip {
+		return false
+	}
+
+	fork := tip
+	for tree.NodeByHeight(fork.Height) != fork {
+		fork = fork.Parent
+	}
+
+	log.Printf("Blocks above height %d left the best chain", fork.Height)
+
+	d.next = fork.Height + 1
+	d.tip = fork.Hash
+	if d.disconnect < 0 || fork.Height < d.disconnect {
+		d.disconnect = fork.Height
+	}
+
+	return true
+}
+
+// stallingPeers returns peers holding requested blocks without delivering any for longer than timeout.
+func (d *downloader) stallingPeers(timeout time.Duration) []*Peer {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	peers := []*Peer{}
+	for peer, state := range d.peers {
+		if time.Since(state.progress) > timeout {
+			peers = append(peers, peer)
+		}
+	}
+
+	return peers
+}
+
+// blockDownloader returns client block downloader, creating it and starting its goroutines if needed.
+// Download starts after the best stored block, if any.
+func (c *Client) blockDownloader() *downloader {
+	c.mtx.Lock()
+	d := c.download
+	created := d == nil
+	if created {
+		next, tip := int32(1), protocol.Hash{}
+		if c.store != nil {
+			next = c.store.BestHeight() + 1
+
+			block, err := c.store.BlockByHeight(next - 1)
+			if err == nil {
+				tip = block.BlockHash()
+			}
+		}
+
+		d = newDownloader(next, tip)
+		c.download = d
+	}
+	c.mtx.Unlock()
+
+	if created {
+		c.peerManager().run(func() { c.downloadBlocks(d) })
+		c.peerManager().run(func() { c.detectStalls(d) })
+	}
+
+	return d
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/blockchain"
+	"github.com/elmarsan/havel/blockstore"
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// mineBlocks returns n regtest blocks extending client best header, whose headers are added to client header tree.
+func mineBlocks(t *testing.T, client *Client, n int) []*msg.Block {
+	tree, err := client.headerTree()
+	if err != nil {
+		t.Fatalf("Unable to get header tree (%s)", err)
+	}
+
+	return mineFork(t, client, tree.Best(), n, []byte{0x51})
+}
+
+// mineFork returns n regtest blocks paying to pkScript extending parent, whose headers are added to client header tree.
+func mineFork(t *testing.T, client *Client, parent *blockchain.HeaderNode, n int, pkScript []byte) []*msg.Block {
+	tree, err := client.headerTree()
+	if err != nil {
+		t.Fatalf("Unable to get header tree (%s)", err)
+	}
+
+	blocks := []*msg.Block{}
+
+	for i := 0; i < n; i++ {
+		block := newBlock(pkScript)
+		block.Header.PrevBlock = parent.Hash
+		block.Header.Timestamp = parent.Header.Timestamp.Add(10 * time.Minute)
+
+		for block.Header.CheckProofOfWork(chaincfg.RegTestParams.PowLimit) != nil {
+			block.Header.Nonce++
+		}
+
+		parent, err = tree.AddHeader(&block.Header, block.Header.Timestamp)
+		if err != nil {
+			t.Fatalf("Unable to add header (%s)", err)
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+// blockServer represents remote peer serving blocks requested with getdata.
+type blockServer struct {
+	// stall represents whether requested blocks are never served.
+	stall bool
+
+	mtx sync.Mutex
+	// blocks holds the served blocks by hash.
+	blocks map[protocol.Hash]*msg.Block
+	// served represents the number of served blocks.
+	served int
+	// maxInFlight represents the maximum number of blocks requested and not served yet.
+	maxInFlight int
+	// disconnected is closed once peer is disconnected.
+	disconnected chan struct{}
+}
+
+// serve sets blocks served to peer.
+func (s *blockServer) serve(blocks []*msg.Block) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.blocks = map[protocol.Hash]*msg.Block{}
+	for _, block := range blocks {
+		s.blocks[block.BlockHash()] = block
+	}
+}
+
+// listen starts listening for a peer, which is handshaked offering syncServices and served blocks.
+func (s *blockServer) listen(t *testing.T) string {
+	remote := &Client{
+		version:  protocol.ProtocolVersion,
+		net:      protocol.RegTest,
+		services: syncServices,
+	}
+
+	s.disconnected = make(chan struct{})
+
+	return listen(t, func(conn net.Conn) {
+		defer close(s.disconnected)
+		defer conn.Close()
+
+		peer, err := remote.handshake(conn, true)
+		if err != nil {
+			return
+		}
+
+		inFlight := 0
+		for {
+			m, err := msg.ReadMessage(conn, peer.version, remote.net)
+			if err != nil {
+				return
+			}
+
+			getData, ok := m.(*msg.GetData)
+			if !ok {
+				continue
+			}
+
+			for _, iv := range getData.InvList {
+				if iv.Obj != msg.MSG_WITNESS_BLOCK {
+					t.Errorf("Wrong requested object type (%d)", iv.Obj)
+				}
+			}
+
+			inFlight += len(getData.InvList)
+
+			s.mtx.Lock()
+			if inFlight > s.maxInFlight {
+				s.maxInFlight = inFlight
+			}
+			s.mtx.Unlock()
+
+			if s.stall {
+				continue
+			}
+
+			for _, iv := range getData.InvList {
+				s.mtx.Lock()
+				block, ok := s.blocks[iv.Hash]
+				s.mtx.Unlock()
+
+				// Blocks not served are never delivered
+				if !ok {
+					continue
+				}
+
+				err := write(remote, peer, block)
+				if err != nil {
+					return
+				}
+
+				inFlight--
+
+				s.mtx.Lock()
+				s.served++
+				s.mtx.Unlock()
+			}
+		}
+	})
+}
+
+// waitPeers waits until client is connected to n peers.
+func waitPeers(t *testing.T, client *Client, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for len(client.Peers()) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Wrong number of peers (%d), expected (%d)", len(client.Peers()), n)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// receiveBlocks checks client delivers blocks in order.
+func receiveBlocks(t *testing.T, client *Client, blocks []*msg.Block) {
+	for i, block := range blocks {
+		select {
+		case event := <-client.Blocks():
+			if event.Height != int32(i+1) {
+				t.Fatalf("Wrong block height (%d), expected (%d)", event.Height, i+1)
+			}
+
+			if event.Block.BlockHash() != block.BlockHash() {
+				t.Fatalf("Wrong block at height (%d)", event.Height)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Block at height (%d) was not delivered", i+1)
+		}
+	}
+}
+
+func TestBlockDownloader(t *testing.T) {
+	t.Run("should download blocks in order from multiple peers", func(t *testing.T) {
+		client := &Client{
+			version: protocol.ProtocolVersion,
+			net:     protocol.RegTest,
+		}
+		t.Cleanup(func() { client.peerManager().shutdown(client.RemovePeer) })
+
+		servers := []*blockServer{{}, {}}
+		for _, server := range servers {
+			err := client.AddPeer(server.listen(t))
+			if err != nil {
+				t.Fatalf("Unable to add peer (%s)", err)
+			}
+		}
+
+		waitPeers(t, client, len(servers))
+
+		blocks := mineBlocks(t, client, 3*maxBlocksInFlightPerPeer)
+		for _, server := range servers {
+			server.serve(blocks)
+		}
+
+		client.scheduleBlocks()
+
+		receiveBlocks(t, client, blocks)
+
+		for i, server := range servers {
+			server.mtx.Lock()
+			served, maxInFlight := server.served, server.maxInFlight
+			server.mtx.Unlock()
+
+			if served == 0 {
+				t.Errorf("Peer (%d) should serve blocks", i)
+			}
+
+			if maxInFlight > maxBlocksInFlightPerPeer {
+				t.Errorf("Peer (%d) had too many blocks in flight (%d)", i, maxInFlight)
+			}
+		}
+	})
+
+	t.Run("should reassign blocks of stalling peer", func(t *testing.T) {
+		client := &Client{
+			version:      protocol.ProtocolVersion,
+			net:          protocol.RegTest,
+			stallTimeout: 200 * time.Millisecond,
+		}
+		t.Cleanup(func() { client.peerManager().shutdown(client.RemovePeer) })
+
+		stalling := &blockServer{stall: true}
+		serving := &blockServer{}
+
+		for _, server := range []*blockServer{stalling, serving} {
+			err := client.AddPeer(server.listen(t))
+			if err != nil {
+				t.Fatalf("Unable to add peer (%s)", err)
+			}
+		}
+
+		waitPeers(t, client, 2)
+
+		blocks := mineBlocks(t, client, 3*maxBlocksInFlightPerPeer)
+		serving.serve(blocks)
+
+		client.scheduleBlocks()
+
+		receiveBlocks(t, client, blocks)
+
+		select {
+		case <-stalling.disconnected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Stalling peer should be disconnected")
+		}
+
+		stalling.mtx.Lock()
+		defer stalling.mtx.Unlock()
+
+		if stalling.maxInFlight == 0 {
+			t.Error("Stalling peer should have been requested blocks")
+		}
+	})
+
+	t.Run("should detect stalling peers while delivery is blocked", func(t *testing.T) {
+		client := &Client{
+			version:      protocol.ProtocolVersion,
+			net:          protocol.RegTest,
+			stallTimeout: 200 * time.Millisecond,
+		}
+		t.Cleanup(func() { client.peerManager().shutdown(client.RemovePeer) })
+
+		server := &blockServer{}
+		err := client.AddPeer(server.listen(t))
+		if err != nil {
+			t.Fatalf("Unable to add peer (%s)", err)
+		}
+
+		waitPeers(t, client, 1)
+
+		// Delivered blocks are never received, while the last blocks are never served
+		blocks := mineBlocks(t, client, 3*maxBlocksInFlightPerPeer)
+		server.serve(blocks[:2*maxBlocksInFlightPerPeer])
+
+		client.scheduleBlocks()
+
+		select {
+		case <-server.disconnected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Stalling peer should be disconnected")
+		}
+	})
+
+	t.Run("should forget blocks which left the best chain", func(t *testing.T) {
+		client := &Client{
+			version: protocol.ProtocolVersion,
+			net:     protocol.RegTest,
+		}
+		t.Cleanup(func() { client.peerManager().shutdown(client.RemovePeer) })
+
+		server := &blockServer{stall: true}
+		err := client.AddPeer(server.listen(t))
+		if err != nil {
+			t.Fatalf("Unable to add peer (%s)", err)
+		}
+
+		waitPeers(t, client, 1)
+
+		tree, err := client.headerTree()
+		if err != nil {
+			t.Fatalf("Unable to get header tree (%s)", err)
+		}
+		genesis := tree.Best()
+
+		stale := mineBlocks(t, client, 3)
+
+		// Out of order block waiting for delivery
+		d := client.blockDownloader()
+		d.mtx.Lock()
+		d.received[stale[1].BlockHash()] = stale[1]
+		d.mtx.Unlock()
+
+		client.scheduleBlocks()
+
+		// Heavier chain replaces the stale blocks
+		fork := mineFork(t, client, genesis, 4, []byte{0x52})
+
+		client.scheduleBlocks()
+
+		d.mtx.Lock()
+		defer d.mtx.Unlock()
+
+		if len(d.received) != 0 {
+			t.Errorf("Wrong number of received blocks (%d)", len(d.received))
+		}
+
+		if len(d.inFlight) != len(fork) {
+			t.Errorf("Wrong number of blocks in flight (%d), expected (%d)", len(d.inFlight), len(fork))
+		}
+
+		for _, block := range fork {
+			if _, ok := d.inFlight[block.BlockHash()]; !ok {
+				t.Errorf("Block %s should be in flight", block.BlockHash())
+			}
+		}
+
+		for _, state := range d.peers {
+			if state.inFlight != len(fork) {
+				t.Errorf("Wrong number of peer blocks in flight (%d)", state.inFlight)
+			}
+		}
+	})
+
+	t.Run("should deliver new best chain after delivered blocks left it", func(t *testing.T) {
+		client := &Client{
+			version: protocol.ProtocolVersion,
+			net:     protocol.RegTest,
+		}
+		t.Cleanup(func() { client.peerManager().shutdown(client.RemovePeer) })
+
+		server := &blockServer{}
+		err := client.AddPeer(server.listen(t))
+		if err != nil {
+			t.Fatalf("Unable to add peer (%s)", err)
+		}
+
+		waitPeers(t, client, 1)
+
+		tree, err := client.headerTree()
+		if err != nil {
+			t.Fatalf("Unable to get header tree (%s)", err)
+		}
+
+		stale := mineBlocks(t, client, 3)
+		server.serve(stale)
+
+		client.scheduleBlocks()
+
+		receiveBlocks(t, client, stale)
+
+		// Heavier chain forks below the delivered tip
+		fork := mineFork(t, client, tree.NodeByHeight(1), 3, []byte{0x52})
+		server.serve(append(stale[:1:1], fork...))
+
+		client.scheduleBlocks()
+
+		select {
+		case event := <-client.Blocks():
+			if !event.Disconnect || event.Height != 1 {
+				t.Fatalf("Wrong event (%+v), expected disconnect above height 1", event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Disconnect was not delivered")
+		}
+
+		for i, block := range fork {
+			select {
+			case event := <-client.Blocks():
+				if event.Height != int32(i+2) || event.Block.BlockHash() != block.BlockHash() {
+					t.Fatalf("Wrong block at height (%d), expected height (%d)", event.Height, i+2)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Block at height (%d) was not delivered", i+2)
+			}
+		}
+	})
+
+	t.Run("should resume after best stored block", func(t *testing.T) {
+		store, err := blockstore.Open(t.TempDir(), protocol.RegTest)
+		if err != nil {
+			t.Fatalf("Unable to open store (%s)", err)
+		}
+		defer store.Close()
+
+		err = store.Put(newBlock([]byte{0x51}), 1, blockstore.StatusValid)
+		if err != nil {
+			t.Fatalf("Unable to put block (%s)", err)
+		}
+
+		client := &Client{
+			version: protocol.ProtocolVersion,
+			net:     protocol.RegTest,
+			store:   store,
+		}
+		t.Cleanup(func() { client.peerManager().shutdown(client.RemovePeer) })
+
+		d := client.blockDownloader()
+
+		d.mtx.Lock()
+		defer d.mtx.Unlock()
+
+		if d.next != 2 {
+			t.Errorf("Wrong next height (%d)", d.next)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/elmarsan/havel/blockstore"
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// maxRequestedInv represents the maximum number of inventory vectors remembered as requested.
+const maxRequestedInv = 50000
+
+// maxQueuedBlocks represents the number of msgs queued for being written into a peer above which
+// requested blocks are answered with notfound, so a big getdata never fills the outbound queue.
+const maxQueuedBlocks = 128
+
+// handleInv requests to peer the announced blocks which are neither known nor already requested.
+// Transactions are not requested, since nothing uses them yet.
+func (c *Client) handleInv(peer *Peer, inv *msg.Inv) error {
+	c.mtx.Lock()
+
+	if c.requested == nil {
+		c.requested = map[msg.InvVec]*Peer{}
+	}
+
+	invList := []*msg.InvVec{}
+	for _, iv := range inv.InvList {
+		if iv.Obj != msg.MSG_BLOCK && iv.Obj != msg.MSG_WITNESS_BLOCK {
+			continue
+		}
+
+		if c.haveInv(iv) {
+			continue
+		}
+
+		// Ask witness peers for blocks including witness data
+		if iv.Obj == msg.MSG_BLOCK && peer.services.Has(protocol.NODE_WITNESS) {
+			iv = &msg.InvVec{Obj: msg.MSG_WITNESS_BLOCK, Hash: iv.Hash}
+		}
+
+		if _, ok := c.requested[*iv]; ok {
+			continue
+		}
+
+		if len(c.requested) >= maxRequestedInv {
+			break
+		}
+
+		c.requested[*iv] = peer
+		invList = append(invList, iv)
+	}
+
+	c.mtx.Unlock()
+
+	if len(invList) == 0 {
+		return nil
+	}
+
+	return c.SendTo(peer, &msg.GetData{InvList: invList})
+}
+
+// handleGetData sends to peer the requested blocks client can serve, answering with notfound for the rest.
+// Blocks requested while peer outbound queue holds maxQueuedBlocks msgs are answered with notfound too,
+// peer can request them again once the sent ones are received.
+// Blocks requested as MSG_BLOCK are sent without witness data.
+func (c *Client) handleGetData(peer *Peer, getData *msg.GetData) error {
+	notFound := []*msg.InvVec{}
+
+	for _, iv := range getData.InvList {
+		if !c.canServe(iv) || len(peer.outbound) >= maxQueuedBlocks {
+			notFound = append(notFound, iv)
+			continue
+		}
+
+		block, err := c.store.Block(iv.Hash)
+		if err != nil {
+			log.Printf("Unable to read block %s (%s)", iv.Hash, err)
+			notFound = append(notFound, iv)
+			continue
+		}
+
+		if iv.Obj == msg.MSG_BLOCK {
+			block = stripWitness(block)
+		}
+
+		err = c.SendTo(peer, block)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(notFound) == 0 {
+		return nil
+	}
+
+	return c.SendTo(peer, &msg.NotFound{InvList: notFound})
+}
+
+// handleNotFound forgets the objects requested to peer which peer cannot serve,
+// so they can be requested again when announced by other peer.
+func (c *Client) handleNotFound(peer *Peer, notFound *msg.NotFound) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, iv := range notFound.InvList {
+		if c.requested[*iv] == peer {
+			delete(c.requested, *iv)
+		}
+	}
+}
+
+// forgetRequests forgets the objects requested to peer, which is disconnected.
+func (c *Client) forgetRequests(peer *Peer) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for iv, requestedPeer := range c.requested {
+		if requestedPeer == peer {
+			delete(c.requested, iv)
+		}
+	}
+}
+
+// handleBlock checks block received from peer, which must have been requested before.
+// Blocks with wrong proof of work, merkle root, mutated merkle tree or wrong witness commitment are
+// discarded and peer is disconnected, so block can be requested again to other peer.
+func (c *Client) handleBlock(peer *Peer, block *msg.Block) error {
+	hash := block.BlockHash()
+
+	// Arrived blocks are no longer requested, whether valid or not
+	c.mtx.Lock()
+	_, requested := c.requested[msg.InvVec{Obj: msg.MSG_BLOCK, Hash: hash}]
+	_, witnessRequested := c.requested[msg.InvVec{Obj: msg.MSG_WITNESS_BLOCK, Hash: hash}]
+	delete(c.requested, msg.InvVec{Obj: msg.MSG_BLOCK, Hash: hash})
+	delete(c.requested, msg.InvVec{Obj: msg.MSG_WITNESS_BLOCK, Hash: hash})
+	c.mtx.Unlock()
+
+	downloading := c.downloadRequested(peer, hash)
+
+	// Unrequested blocks are ignored
+	if !requested && !witnessRequested && !downloading {
+		return nil
+	}
+
+	params, err := chaincfg.ParamsForNet(c.net)
+	if err != nil {
+		return err
+	}
+
+	err = block.Header.CheckProofOfWork(params.PowLimit)
+	if err == nil {
+		err = block.CheckMerkleRoot()
+	}
+	if err == nil {
+		err = block.CheckWitnessCommitment()
+	}
+
+	if err != nil {
+		return fmt.Errorf("Invalid block %s (%s)", hash, err)
+	}
+
+	log.Printf("Received block %s from peer %s", hash, peer.conn.RemoteAddr())
+
+	if downloading {
+		c.receiveBlock(peer, hash, block)
+	}
+
+	return nil
+}
+
+// haveInv returns whether client holds the object identified by iv.
+// Blocks are held once stored, unless they failed validation, while transactions are never held.
+func (c *Client) haveInv(iv *msg.InvVec) bool {
+	if c.store == nil || (iv.Obj != msg.MSG_BLOCK && iv.Obj != msg.MSG_WITNESS_BLOCK) {
+		return false
+	}
+
+	entry, ok := c.store.Entry(iv.Hash)
+	return ok && !entry.Status.Has(blockstore.StatusInvalid)
+}
+
+// canServe returns whether client can serve the block identified by iv to peers.
+// Only validated blocks are served, stored blocks which only passed proof of work, merkle root
+// and witness commitment checks are not.
+func (c *Client) canServe(iv *msg.InvVec) bool {
+	if c.store == nil || (iv.Obj != msg.MSG_BLOCK && iv.Obj != msg.MSG_WITNESS_BLOCK) {
+		return false
+	}
+
+	entry, ok := c.store.Entry(iv.Hash)
+	return ok && entry.Status.Has(blockstore.StatusValid) && !entry.Status.Has(blockstore.StatusInvalid)
+}
+
+// stripWitness returns copy of block whose transactions carry no witness data.
+func stripWitness(block *msg.Block) *msg.Block {
+	stripped := &msg.Block{Header: block.Header}
+
+	for _, tx := range block.Transactions {
+		strippedTx := *tx
+		strippedTx.TxIn = make([]*msg.TxIn, 0, len(tx.TxIn))
+
+		for _, txIn := range tx.TxIn {
+			strippedTxIn := *txIn
+			strippedTxIn.Witness = nil
+			strippedTx.TxIn = append(strippedTx.TxIn, &strippedTxIn)
+		}
+
+		stripped.Transactions = append(stripped.Transactions, &strippedTx)
+	}
+
+	return stripped
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/blockstore"
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestInventory(t *testing.T) {
+	block1 := &msg.InvVec{Obj: msg.MSG_BLOCK, Hash: [32]byte{0x01}}
+	block2 := &msg.InvVec{Obj: msg.MSG_BLOCK, Hash: [32]byte{0x02}}
+	block3 := &msg.InvVec{Obj: msg.MSG_BLOCK, Hash: [32]byte{0x03}}
+	tx := &msg.InvVec{Obj: msg.MSG_TX, Hash: [32]byte{0x04}}
+
+	replies := make(chan msg.Message, 3)
+
+	client := &Client{
+		version: protocol.AddrV2Version,
+		net:     protocol.MainNet,
+	}
+
+	connectRemote(t, client, func(remote *Client, peer *Peer) {
+		requests := []msg.Message{
+			// Transactions are not requested
+			&msg.Inv{InvList: []*msg.InvVec{block1, block2, tx}},
+			// block2 was already requested
+			&msg.Inv{InvList: []*msg.InvVec{block2, block3}},
+			&msg.GetData{InvList: []*msg.InvVec{block1}},
+		}
+
+		for _, request := range requests {
+			err := write(remote, peer, request)
+			if err != nil {
+				return
+			}
+
+			m, err := msg.ReadMessage(peer.conn, peer.version, remote.net)
+			if err != nil {
+				return
+			}
+
+			replies <- m
+		}
+	})
+
+	next := func() msg.Message {
+		select {
+		case m := <-replies:
+			return m
+		case <-time.After(5 * time.Second):
+			t.Fatal("Msg was not answered")
+		}
+
+		return nil
+	}
+
+	t.Run("should request announced objects", func(t *testing.T) {
+		getData, ok := next().(*msg.GetData)
+		if !ok {
+			t.Fatal("Inv should be answered with getdata")
+		}
+
+		if len(getData.InvList) != 2 {
+			t.Errorf("Wrong number of requested objects (%d)", len(getData.InvList))
+		}
+	})
+
+	t.Run("should NOT request objects twice", func(t *testing.T) {
+		getData, ok := next().(*msg.GetData)
+		if !ok {
+			t.Fatal("Inv should be answered with getdata")
+		}
+
+		if len(getData.InvList) != 1 || *getData.InvList[0] != *block3 {
+			t.Errorf("Wrong requested objects (%v)", getData.InvList)
+		}
+	})
+
+	t.Run("should answer getdata with notfound", func(t *testing.T) {
+		notFound, ok := next().(*msg.NotFound)
+		if !ok {
+			t.Fatal("Getdata should be answered with notfound")
+		}
+
+		if len(notFound.InvList) != 1 || *notFound.InvList[0] != *block1 {
+			t.Errorf("Wrong not found objects (%v)", notFound.InvList)
+		}
+	})
+}
+
+func TestRequestedInv(t *testing.T) {
+	client := &Client{
+		version: protocol.ProtocolVersion,
+		net:     protocol.RegTest,
+	}
+
+	conn, remoteConn := net.Pipe()
+	defer conn.Close()
+	defer remoteConn.Close()
+
+	peer := &Peer{
+		conn:     conn,
+		outbound: make(chan msg.Message, outboundQueueSize),
+		quit:     make(chan struct{}),
+	}
+
+	block := &msg.InvVec{Obj: msg.MSG_BLOCK, Hash: protocol.Hash{0x01}}
+
+	request := func() bool {
+		err := client.handleInv(peer, &msg.Inv{InvList: []*msg.InvVec{block}})
+		if err != nil {
+			t.Fatalf("Unable to handle inv (%s)", err)
+		}
+
+		select {
+		case m := <-peer.outbound:
+			_, ok := m.(*msg.GetData)
+			return ok
+		default:
+			return false
+		}
+	}
+
+	if !request() {
+		t.Fatal("Announced block should be requested")
+	}
+
+	if request() {
+		t.Fatal("Requested block should NOT be requested again")
+	}
+
+	t.Run("should forget requests answered with notfound", func(t *testing.T) {
+		// Only the peer requested can answer
+		client.handleNotFound(&Peer{}, &msg.NotFound{InvList: []*msg.InvVec{block}})
+		if request() {
+			t.Fatal("Block should remain requested")
+		}
+
+		client.handleNotFound(peer, &msg.NotFound{InvList: []*msg.InvVec{block}})
+		if !request() {
+			t.Error("Block not found should be requested again")
+		}
+	})
+
+	t.Run("should forget requests of disconnected peer", func(t *testing.T) {
+		client.forgetRequests(peer)
+		if !request() {
+			t.Error("Block requested to disconnected peer should be requested again")
+		}
+	})
+}
+
+func TestServeBlocks(t *testing.T) {
+	store, err := blockstore.Open(t.TempDir(), protocol.RegTest)
+	if err != nil {
+		t.Fatalf("Unable to open store (%s)", err)
+	}
+	defer store.Close()
+
+	stored := newBlock([]byte{0x51})
+	err = store.Put(stored, 1, blockstore.StatusValid)
+	if err != nil {
+		t.Fatalf("Unable to put block (%s)", err)
+	}
+
+	unvalidated := newBlock([]byte{0x52})
+	err = store.Put(unvalidated, 2, blockstore.StatusHaveData)
+	if err != nil {
+		t.Fatalf("Unable to put block (%s)", err)
+	}
+
+	storedInv := &msg.InvVec{Obj: msg.MSG_WITNESS_BLOCK, Hash: stored.BlockHash()}
+	unvalidatedInv := &msg.InvVec{Obj: msg.MSG_WITNESS_BLOCK, Hash: unvalidated.BlockHash()}
+	unknownInv := &msg.InvVec{Obj: msg.MSG_WITNESS_BLOCK, Hash: protocol.Hash{0x01}}
+
+	client := &Client{
+		version: protocol.ProtocolVersion,
+		net:     protocol.RegTest,
+		store:   store,
+	}
+
+	t.Run("should hold stored blocks", func(t *testing.T) {
+		if !client.haveInv(storedInv) || !client.haveInv(unvalidatedInv) || client.haveInv(unknownInv) {
+			t.Error("Only stored blocks should be held")
+		}
+	})
+
+	t.Run("should only serve validated blocks", func(t *testing.T) {
+		if !client.canServe(storedInv) || client.canServe(unvalidatedInv) || client.canServe(unknownInv) {
+			t.Error("Only validated blocks should be served")
+		}
+	})
+
+	replies := make(chan msg.Message, 2)
+
+	connectRemote(t, client, func(remote *Client, peer *Peer) {
+		err := write(remote, peer, &msg.GetData{InvList: []*msg.InvVec{storedInv, unvalidatedInv, unknownInv}})
+		if err != nil {
+			return
+		}
+
+		for i := 0; i < 2; i++ {
+			m, err := msg.ReadMessage(peer.conn, peer.version, remote.net)
+			if err != nil {
+				return
+			}
+
+			replies <- m
+		}
+	})
+
+	t.Run("should serve validated blocks", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			select {
+			case m := <-replies:
+				switch m := m.(type) {
+				case *msg.Block:
+					if m.BlockHash() != stored.BlockHash() {
+						t.Errorf("Wrong block (%s)", m.BlockHash())
+					}
+				case *msg.NotFound:
+					if len(m.InvList) != 2 || *m.InvList[0] != *unvalidatedInv || *m.InvList[1] != *unknownInv {
+						t.Errorf("Wrong not found objects (%v)", m.InvList)
+					}
+				default:
+					t.Errorf("Wrong reply (%s)", m.Command())
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Getdata was not answered")
+			}
+		}
+	})
+
+	t.Run("should NOT fill outbound queue", func(t *testing.T) {
+		conn, remoteConn := net.Pipe()
+		defer conn.Close()
+		defer remoteConn.Close()
+
+		peer := &Peer{
+			conn:     conn,
+			outbound: make(chan msg.Message, outboundQueueSize),
+			quit:     make(chan struct{}),
+		}
+
+		invList := []*msg.InvVec{}
+		for i := 0; i < outboundQueueSize; i++ {
+			invList = append(invList, storedInv)
+		}
+
+		err := client.handleGetData(peer, &msg.GetData{InvList: invList})
+		if err != nil {
+			t.Fatalf("Peer should NOT be disconnected (%s)", err)
+		}
+
+		// Queued blocks followed by notfound
+		if len(peer.outbound) != maxQueuedBlocks+1 {
+			t.Fatalf("Wrong number of queued msgs (%d)", len(peer.outbound))
+		}
+
+		for i := 0; i < maxQueuedBlocks; i++ {
+			<-peer.outbound
+		}
+
+		notFound, ok := (<-peer.outbound).(*msg.NotFound)
+		if !ok || len(notFound.InvList) != outboundQueueSize-maxQueuedBlocks {
+			t.Error("Blocks beyond the queue limit should be answered with notfound")
+		}
+	})
+
+	t.Run("should strip witness data", func(t *testing.T) {
+		block := newBlock([]byte{0x51})
+		block.Transactions[0].TxIn[0].Witness = [][]byte{make([]byte, 32)}
+
+		stripped := stripWitness(block)
+		if stripped.Transactions[0].TxIn[0].Witness != nil {
+			t.Error("Witness data should be stripped")
+		}
+
+		if block.Transactions[0].TxIn[0].Witness == nil {
+			t.Error("Original block should NOT be modified")
+		}
+
+		if stripped.BlockHash() != block.BlockHash() || stripped.Transactions[0].TxHash() != block.Transactions[0].TxHash() {
+			t.Error("Stripped block should keep its hashes")
+		}
+	})
+}
+
+// newBlock returns regtest block with single coinbase transaction paying to pkScript.
+func newBlock(pkScript []byte) *msg.Block {
+	coinbase := &msg.Tx{
+		Version: 1,
+		TxIn: []*msg.TxIn{
+			{
+				PreviousOutPoint: msg.OutPoint{Index: 0xffffffff},
+				SignatureScript:  []byte{0x01, 0x01},
+				Sequence:         0xffffffff,
+			},
+		},
+		TxOut: []*msg.TxOut{
+			{
+				Value:    5000000000,
+				PkScript: pkScript,
+			},
+		},
+	}
+
+	block := &msg.Block{
+		Header: msg.BlockHeader{
+			Version:    1,
+			MerkleRoot: coinbase.TxHash(),
+			Bits:       0x207fffff,
+		},
+		Transactions: []*msg.Tx{coinbase},
+	}
+
+	for block.Header.CheckProofOfWork(chaincfg.RegTestParams.PowLimit) != nil {
+		block.Header.Nonce++
+	}
+
+	return block
+}
+
+func TestBlockDownload(t *testing.T) {
+	valid := newBlock([]byte{0x51})
+	invalid := newBlock([]byte{0x52})
+	invalid.Header.MerkleRoot = protocol.Hash{0x01}
+
+	replies := make(chan msg.Message, 2)
+	disconnected := make(chan bool, 1)
+
+	client := &Client{
+		version: protocol.AddrV2Version,
+		net:     protocol.RegTest,
+	}
+
+	connectRemote(t, client, func(remote *Client, peer *Peer) {
+		for _, block := range []*msg.Block{valid, invalid} {
+			hash := block.BlockHash()
+			err := write(remote, peer, &msg.Inv{InvList: []*msg.InvVec{{Obj: msg.MSG_BLOCK, Hash: hash}}})
+			if err != nil {
+				return
+			}
+
+			m, err := msg.ReadMessage(peer.conn, peer.version, remote.net)
+			if err != nil {
+				return
+			}
+
+			replies <- m
+
+			err = write(remote, peer, block)
+			if err != nil {
+				return
+			}
+		}
+
+		_, err := msg.ReadMessage(peer.conn, peer.version, remote.net)
+		disconnected <- err != nil
+	})
+
+	for _, block := range []*msg.Block{valid, invalid} {
+		select {
+		case m := <-replies:
+			getData, ok := m.(*msg.GetData)
+			if !ok {
+				t.Fatal("Inv should be answered with getdata")
+			}
+
+			if len(getData.InvList) != 1 || getData.InvList[0].Hash != block.BlockHash() {
+				t.Fatalf("Wrong requested objects (%v)", getData.InvList)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Inv was not answered")
+		}
+	}
+
+	t.Run("should disconnect peer sending invalid block", func(t *testing.T) {
+		select {
+		case ok := <-disconnected:
+			if !ok {
+				t.Error("Peer should have been disconnected")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Peer was not disconnected")
+		}
+	})
+
+	t.Run("should forget received block requests", func(t *testing.T) {
+		client.mtx.Lock()
+		defer client.mtx.Unlock()
+
+		if len(client.requested) != 0 {
+			t.Errorf("Received blocks should NOT remain requested (%d)", len(client.requested))
+		}
+	})
+}
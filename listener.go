@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/elmarsan/havel/addrmgr"
+	"github.com/elmarsan/havel/msg"
+)
+
+// Listen accepts connections on TCP addr until client shuts down, completing version handshake
+// and saving them as connected inbound peers.
+// It returns the address of the listener.
+func (c *Client) Listen(addr string) (net.Addr, error) {
+	pm := c.peerManager()
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to listen (%s)", err)
+	}
+
+	err = pm.run(func() { c.accept(l) })
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	// Stop accepting connections on shutdown
+	err = pm.run(func() {
+		<-pm.ctx.Done()
+		l.Close()
+	})
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l.Addr(), nil
+}
+
+// accept accepts connections from l until it is closed, handshaking each one in its own goroutine.
+func (c *Client) accept(l net.Listener) {
+	pm := c.peerManager()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if pm.ctx.Err() == nil {
+				log.Printf("Unable to accept connection (%s)", err)
+			}
+			return
+		}
+
+		err = pm.run(func() { c.acceptPeer(conn) })
+		if err != nil {
+			conn.Close()
+			return
+		}
+	}
+}
+
+// acceptPeer saves inbound conn as connected peer, closing it on failure.
+func (c *Client) acceptPeer(conn net.Conn) {
+	err := c.addInboundPeer(conn)
+	if err != nil {
+		log.Printf("Rejecting inbound peer %s (%s)", conn.RemoteAddr(), err)
+		conn.Close()
+	}
+}
+
+// addInboundPeer completes version handshake over inbound conn and saves it as connected peer.
+// Connections exceeding inbound limits are refused before handshake.
+func (c *Client) addInboundPeer(conn net.Conn) error {
+	err := c.peerManager().available(true, netGroup(conn.RemoteAddr()))
+	if err != nil {
+		return err
+	}
+
+	peer, err := c.handshake(conn, true)
+	if err != nil {
+		return err
+	}
+
+	return c.startPeer(peer)
+}
+
+// netGroup returns the network group of addr, used for limiting connections from the same network.
+func netGroup(addr net.Addr) string {
+	netAddr, err := newNetAddr(addr)
+	if err != nil {
+		return addr.String()
+	}
+
+	addrV2, err := msg.NewNetAddrV2(netAddr)
+	if err != nil {
+		return addr.String()
+	}
+
+	return addrmgr.GroupKey(addrV2)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// waitInbound waits until client has n inbound peers.
+func waitInbound(t *testing.T, client *Client, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for client.peerManager().count(true) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Wrong number of inbound peers (%d), expected (%d)", client.peerManager().count(true), n)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestListen(t *testing.T) {
+	t.Run("should accept inbound peer", func(t *testing.T) {
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		addr, err := client.Listen("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Unable to listen (%s)", err)
+		}
+
+		remote := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		err = remote.AddPeer(addr.String())
+		if err != nil {
+			t.Fatalf("Unable to add peer (%s)", err)
+		}
+
+		waitInbound(t, client, 1)
+
+		peer := client.Peers()[0]
+		if !peer.inbound || !peer.addrV2 {
+			t.Errorf("Wrong inbound peer (inbound %t, addrv2 %t)", peer.inbound, peer.addrV2)
+		}
+	})
+
+	t.Run("should NOT connect to self", func(t *testing.T) {
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		addr, err := client.Listen("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Unable to listen (%s)", err)
+		}
+
+		err = client.AddPeer(addr.String())
+		if err == nil {
+			t.Error("Connection to self should have been rejected")
+		}
+
+		if len(client.Peers()) != 0 {
+			t.Errorf("Wrong number of peers (%d)", len(client.Peers()))
+		}
+	})
+
+	t.Run("should enforce max inbound peers per network group", func(t *testing.T) {
+		client := &Client{
+			version:            protocol.AddrV2Version,
+			net:                protocol.MainNet,
+			maxInboundPerGroup: 1,
+		}
+
+		addr, err := client.Listen("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Unable to listen (%s)", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			remote := &Client{
+				version: protocol.AddrV2Version,
+				net:     protocol.MainNet,
+			}
+
+			err = remote.AddPeer(addr.String())
+			if i == 0 && err != nil {
+				t.Fatalf("Unable to add peer (%s)", err)
+			}
+
+			if i == 1 && err == nil {
+				t.Error("Peer from the same network group should have been rejected")
+			}
+
+			waitInbound(t, client, 1)
+		}
+	})
+
+	t.Run("should stop listening on shutdown", func(t *testing.T) {
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		addr, err := client.Listen("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Unable to listen (%s)", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		client.Run(ctx)
+
+		conn, err := net.Dial("tcp", addr.String())
+		if err == nil {
+			conn.Close()
+			t.Error("Listener should have been closed")
+		}
+	})
+}
+
+func TestNetGroup(t *testing.T) {
+	tests := []struct {
+		addr  string
+		group string
+	}{
+		{addr: "10.1.2.3:8333", group: "10.1.0.0/16"},
+		{addr: "[2001:db8:1:2::1]:8333", group: "2001:db8::/32"},
+	}
+
+	for _, test := range tests {
+		addr, _ := net.ResolveTCPAddr("tcp", test.addr)
+		if group := netGroup(addr); group != test.group {
+			t.Errorf("Wrong network group (%s) of %s", group, test.addr)
+		}
+	}
+}
@@ -1,19 +1,202 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"time"
 
+	"github.com/elmarsan/havel/addrmgr"
+	"github.com/elmarsan/havel/blockstore"
+	"github.com/elmarsan/havel/chaincfg"
 	"github.com/elmarsan/havel/protocol"
 )
 
+// peersFile represents the file holding known mainnet node addresses between restarts,
+// other networks prefix it with their name.
+const peersFile = "peers.dat"
+
+// blocksDir represents the directory holding mainnet blocks, other networks prefix it with their name.
+const blocksDir = "blocks"
+
+// blockFlushInterval represents the time between flushes of stored blocks.
+const blockFlushInterval = time.Minute
+
+// maxConnectAttempts represents the maximum number of connection attempts made on startup.
+const maxConnectAttempts = 100
+
 func main() {
-	client := Client{
-		version: 0xea62,
-		net:     protocol.MainNet,
+	network := flag.String("net", chaincfg.MainNetParams.Name, "network: mainnet, testnet3, testnet4, signet, regtest or simnet")
+	challenge := flag.String("signetchallenge", "", "hex encoded challenge script of a custom signet, requires -net signet")
+	minVersion := flag.Uint("minversion", uint(protocol.MinPeerVersion), "minimum protocol version of accepted peers")
+	connect := flag.String("connect", "", "address of the only node to connect to, e.g. a local regtest bitcoind")
+	flag.Parse()
+
+	params, err := netParams(*network, *challenge)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	err := client.AddPeer("127.0.0.1:8333")
+	err = run(params, *connect, uint32(*minVersion))
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// run runs client on the network of params until interrupted, storing downloaded blocks.
+// Client only connects to connect when given.
+// Errors are returned instead of exiting, so stored blocks are always flushed.
+func run(params *chaincfg.ChainParams, connect string, minVersion uint32) error {
+	file := peersFile
+	dir := blocksDir
+	if params.Net != chaincfg.MainNetParams.Net {
+		file = params.Name + "-" + peersFile
+		dir = params.Name + "-" + blocksDir
+	}
+
+	store, err := blockstore.Open(dir, params.Net)
+	if err != nil {
+		return err
+	}
+
+	// Keep running until interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	addrs := addrmgr.New()
+	err = addrs.Load(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Unable to load known addresses (%s)", err)
+	}
+
+	client := Client{
+		version:          protocol.ProtocolVersion,
+		net:              params.Net,
+		minVersion:       minVersion,
+		services:         protocol.NODE_NETWORK,
+		requiredServices: syncServices,
+		addrs:            addrs,
+		store:            store,
+	}
+
+	// Save known addresses however client stops
+	defer func() {
+		err := addrs.Save(file)
+		if err != nil {
+			log.Printf("Unable to save known addresses (%s)", err)
+		}
+	}()
+
+	// Wait for stored blocks to be flushed before returning
+	stored := make(chan struct{})
+	defer func() {
+		stop()
+		<-stored
+	}()
+
+	go func() {
+		storeBlocks(ctx, stop, &client, store)
+		close(stored)
+	}()
+
+	if connect != "" {
+		err = client.AddPeer(connect)
+		if err != nil {
+			return err
+		}
+
+		client.Run(ctx)
+		return nil
+	}
+
+	// Ask DNS seeds for addresses when none is known
+	if addrs.Count() == 0 {
+		err = client.Bootstrap(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	go client.connectPeers(defaultMaxOutbound, maxConnectAttempts)
+
+	client.Run(ctx)
+
+	return nil
+}
+
+// storeBlocks stores the blocks downloaded by client, disconnecting the ones which left the best chain,
+// and flushes them every blockFlushInterval,
+// until ctx is cancelled, then store is closed.
+// Blocks which cannot be stored stop the client calling stop, since the following ones could not be stored either.
+func storeBlocks(ctx context.Context, stop context.CancelFunc, client *Client, store *blockstore.Store) {
+	defer func() {
+		err := store.Close()
+		if err != nil {
+			log.Printf("Unable to close block store (%s)", err)
+		}
+	}()
+
+	ticker := time.NewTicker(blockFlushInterval)
+	defer ticker.Stop()
+
+	blocks := client.Blocks()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := store.Flush()
+			if err != nil {
+				log.Printf("Unable to flush block store (%s)", err)
+			}
+		case event := <-blocks:
+			if event.Disconnect {
+				store.Disconnect(event.Height)
+				log.Printf("Disconnected blocks above height %d", event.Height)
+				continue
+			}
+
+			// Downloaded blocks only passed proof of work, merkle root and witness commitment checks,
+			// neither scripts nor spent outputs are validated yet
+			err := store.Put(event.Block, event.Height, blockstore.StatusHaveData)
+			if err != nil {
+				log.Printf("Unable to store block %d, shutting down (%s)", event.Height, err)
+				stop()
+				return
+			}
+
+			log.Printf("Stored block %d (%s)", event.Height, event.Block.BlockHash())
+		}
+	}
+}
+
+// netParams returns the parameters of the network called name.
+// A custom signet is registered when challenge other than the default signet one is given,
+// which requires name to be signet.
+func netParams(name, challenge string) (*chaincfg.ChainParams, error) {
+	if challenge == "" {
+		return chaincfg.ParamsForName(name)
+	}
+
+	if name != chaincfg.SigNetParams.Name {
+		return nil, fmt.Errorf("Signet challenge given for network (%s), expected (%s)", name, chaincfg.SigNetParams.Name)
+	}
+
+	script, err := hex.DecodeString(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	// Default challenge is the default signet
+	if chaincfg.SigNetMagic(script) == chaincfg.SigNetParams.Net {
+		return &chaincfg.SigNetParams, nil
+	}
+
+	params := chaincfg.CustomSigNetParams(script, nil)
+	return params, chaincfg.Register(params)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/blockstore"
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestStoreBlocks(t *testing.T) {
+	store, err := blockstore.Open(t.TempDir(), protocol.RegTest)
+	if err != nil {
+		t.Fatalf("Unable to open store (%s)", err)
+	}
+
+	client := &Client{
+		version: protocol.ProtocolVersion,
+		net:     protocol.RegTest,
+		store:   store,
+	}
+	t.Cleanup(func() { client.peerManager().shutdown(client.RemovePeer) })
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	done := make(chan struct{})
+	go func() {
+		storeBlocks(ctx, stop, client, store)
+		close(done)
+	}()
+
+	blocks := client.blockDownloader().blocks
+
+	block := newBlock([]byte{0x51})
+	blocks <- &BlockEvent{Height: 1, Block: block}
+
+	t.Run("should store blocks without validating them", func(t *testing.T) {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			entry, ok := store.Entry(block.BlockHash())
+			if ok {
+				if entry.Status != blockstore.StatusHaveData {
+					t.Errorf("Wrong status (%d)", entry.Status)
+				}
+
+				break
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatal("Block was not stored")
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("should disconnect blocks which left the best chain", func(t *testing.T) {
+		blocks <- &BlockEvent{Height: 0, Disconnect: true}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for store.BestHeight() != 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("Block was not disconnected")
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("should stop when block cannot be stored", func(t *testing.T) {
+		store.Close()
+
+		blocks <- &BlockEvent{Height: 2, Block: newBlock([]byte{0x52})}
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Storing should stop")
+		}
+
+		if ctx.Err() == nil {
+			t.Error("Client should be stopped")
+		}
+	})
+}
+
+func TestNetParams(t *testing.T) {
+	t.Run("should return params of network", func(t *testing.T) {
+		params, err := netParams(chaincfg.RegTestParams.Name, "")
+		if err != nil || params != &chaincfg.RegTestParams {
+			t.Errorf("Wrong params (%v)", err)
+		}
+	})
+
+	t.Run("should register custom signet", func(t *testing.T) {
+		params, err := netParams(chaincfg.SigNetParams.Name, "51")
+		if err != nil {
+			t.Fatalf("Unable to get params (%s)", err)
+		}
+
+		if params.Net != chaincfg.SigNetMagic([]byte{0x51}) {
+			t.Errorf("Wrong network magic (0x%08x)", uint32(params.Net))
+		}
+	})
+
+	t.Run("should return default signet for its challenge", func(t *testing.T) {
+		challenge := hex.EncodeToString(chaincfg.SigNetParams.SigNetChallenge)
+
+		params, err := netParams(chaincfg.SigNetParams.Name, challenge)
+		if err != nil || params != &chaincfg.SigNetParams {
+			t.Errorf("Wrong params (%v)", err)
+		}
+	})
+
+	t.Run("should NOT accept challenge for other network", func(t *testing.T) {
+		_, err := netParams(chaincfg.MainNetParams.Name, "51")
+		if err == nil {
+			t.Error("Challenge for mainnet should NOT be accepted")
+		}
+	})
+}
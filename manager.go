@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// defaultMaxOutbound represents the maximum number of outbound peers, unless Client sets other.
+const defaultMaxOutbound = 8
+
+// defaultMaxInbound represents the maximum number of inbound peers, unless Client sets other.
+const defaultMaxInbound = 117
+
+// defaultMaxInboundPerGroup represents the maximum number of inbound peers from the same network group,
+// unless Client sets other.
+const defaultMaxInboundPerGroup = 4
+
+// outboundQueueSize represents the maximum number of msgs queued for being written into a peer.
+const outboundQueueSize = 1000
+
+var (
+	ErrMaxPeers         = errors.New("Maximum number of peers reached")
+	ErrMaxPeersPerGroup = errors.New("Maximum number of peers from the same network group reached")
+	ErrClientClosed     = errors.New("Client closed")
+	ErrPeerDisconnected = errors.New("Peer disconnected")
+)
+
+// peerManager tracks connected peers and the goroutines running them.
+type peerManager struct {
+	// ctx is cancelled when manager shuts down.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// wg tracks peer goroutines.
+	wg sync.WaitGroup
+
+	// mtx protects peers.
+	mtx sync.Mutex
+	// peers represents connected peers.
+	peers []*Peer
+	// maxOutbound represents the maximum number of outbound peers.
+	maxOutbound int
+	// maxInbound represents the maximum number of inbound peers.
+	maxInbound int
+	// maxInboundPerGroup represents the maximum number of inbound peers from the same network group.
+	maxInboundPerGroup int
+}
+
+// newPeerManager returns peerManager accepting up to maxOutbound outbound peers and maxInbound inbound peers,
+// with up to maxInboundPerGroup inbound peers from the same network group.
+func newPeerManager(maxOutbound, maxInbound, maxInboundPerGroup int) *peerManager {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &peerManager{
+		ctx:                ctx,
+		cancel:             cancel,
+		maxOutbound:        maxOutbound,
+		maxInbound:         maxInbound,
+		maxInboundPerGroup: maxInboundPerGroup,
+	}
+}
+
+// available returns nil when a new peer in the given direction can be added.
+// group represents the network group of inbound peers, it is ignored for outbound ones.
+func (pm *peerManager) available(inbound bool, group string) error {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+
+	return pm.availableLocked(inbound, group)
+}
+
+// availableLocked is available, it must be called holding pm.mtx.
+func (pm *peerManager) availableLocked(inbound bool, group string) error {
+	if pm.ctx.Err() != nil {
+		return ErrClientClosed
+	}
+
+	max := pm.maxOutbound
+	if inbound {
+		max = pm.maxInbound
+	}
+
+	if pm.countLocked(inbound) >= max {
+		return ErrMaxPeers
+	}
+
+	if !inbound {
+		return nil
+	}
+
+	n := 0
+	for _, peer := range pm.peers {
+		if peer.inbound && netGroup(peer.conn.RemoteAddr()) == group {
+			n++
+		}
+	}
+
+	if n >= pm.maxInboundPerGroup {
+		return ErrMaxPeersPerGroup
+	}
+
+	return nil
+}
+
+// add saves peer as connected, running every function of run in its own goroutine.
+func (pm *peerManager) add(peer *Peer, run ...func()) error {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+
+	err := pm.availableLocked(peer.inbound, netGroup(peer.conn.RemoteAddr()))
+	if err != nil {
+		return err
+	}
+
+	pm.peers = append(pm.peers, peer)
+
+	for _, f := range run {
+		pm.goLocked(f)
+	}
+
+	return nil
+}
+
+// run runs f in its own goroutine, which is waited on shutdown.
+func (pm *peerManager) run(f func()) error {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+
+	if pm.ctx.Err() != nil {
+		return ErrClientClosed
+	}
+
+	pm.goLocked(f)
+	return nil
+}
+
+// goLocked runs f in its own goroutine tracked by pm.wg, it must be called holding pm.mtx.
+func (pm *peerManager) goLocked(f func()) {
+	pm.wg.Add(1)
+	go func() {
+		defer pm.wg.Done()
+		f()
+	}()
+}
+
+// remove removes peer from connected peers, returning false if it was not connected.
+func (pm *peerManager) remove(peer *Peer) bool {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+
+	for i, p := range pm.peers {
+		if p == peer {
+			pm.peers = append(pm.peers[:i], pm.peers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// all returns connected peers.
+func (pm *peerManager) all() []*Peer {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+
+	return append([]*Peer{}, pm.peers...)
+}
+
+// count returns the number of connected peers in the given direction.
+func (pm *peerManager) count(inbound bool) int {
+	pm.mtx.Lock()
+	defer pm.mtx.Unlock()
+
+	return pm.countLocked(inbound)
+}
+
+// countLocked is count, it must be called holding pm.mtx.
+func (pm *peerManager) countLocked(inbound bool) int {
+	n := 0
+	for _, peer := range pm.peers {
+		if peer.inbound == inbound {
+			n++
+		}
+	}
+
+	return n
+}
+
+// shutdown stops accepting peers, disconnects the connected ones using disconnect
+// and waits for their goroutines.
+func (pm *peerManager) shutdown(disconnect func(peer *Peer)) {
+	pm.mtx.Lock()
+	pm.cancel()
+	peers := append([]*Peer{}, pm.peers...)
+	pm.mtx.Unlock()
+
+	for _, peer := range peers {
+		disconnect(peer)
+	}
+
+	pm.wg.Wait()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestPeerManager(t *testing.T) {
+	t.Run("should enforce max outbound peers", func(t *testing.T) {
+		client := &Client{
+			version:     protocol.AddrV2Version,
+			net:         protocol.MainNet,
+			maxOutbound: 1,
+		}
+
+		connectRemote(t, client, func(remote *Client, peer *Peer) {
+			msg.ReadMessage(peer.conn, peer.version, remote.net)
+		})
+
+		addr := listen(t, func(conn net.Conn) {
+			conn.Close()
+		})
+
+		err := client.AddPeer(addr)
+		if !errors.Is(err, ErrMaxPeers) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+
+	t.Run("should broadcast msg to every peer", func(t *testing.T) {
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		received := make(chan msg.Message, 3)
+		for i := 0; i < 3; i++ {
+			connectRemote(t, client, func(remote *Client, peer *Peer) {
+				m, err := msg.ReadMessage(peer.conn, peer.version, remote.net)
+				if err != nil {
+					return
+				}
+
+				received <- m
+			})
+		}
+
+		client.Broadcast(&msg.Ping{Nonce: 0x01})
+
+		for i := 0; i < 3; i++ {
+			select {
+			case m := <-received:
+				if ping, ok := m.(*msg.Ping); !ok || ping.Nonce != 0x01 {
+					t.Errorf("Wrong msg (%v)", m)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Msg was not broadcast")
+			}
+		}
+	})
+
+	t.Run("should remove peer", func(t *testing.T) {
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		started := make(chan struct{})
+		disconnected := make(chan struct{})
+		connectRemote(t, client, func(remote *Client, peer *Peer) {
+			defer close(disconnected)
+			close(started)
+			msg.ReadMessage(peer.conn, peer.version, remote.net)
+		})
+
+		<-started
+
+		peer := client.Peers()[0]
+		client.RemovePeer(peer)
+
+		select {
+		case <-disconnected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Peer connection was not closed")
+		}
+
+		if len(client.Peers()) != 0 {
+			t.Errorf("Wrong number of peers (%d)", len(client.Peers()))
+		}
+
+		err := client.SendTo(peer, &msg.Ping{})
+		if !errors.Is(err, ErrPeerDisconnected) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+
+	t.Run("should shutdown on context cancellation", func(t *testing.T) {
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		for i := 0; i < 2; i++ {
+			connectRemote(t, client, func(remote *Client, peer *Peer) {
+				msg.ReadMessage(peer.conn, peer.version, remote.net)
+			})
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			client.Run(ctx)
+			close(done)
+		}()
+
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Client did not shutdown")
+		}
+
+		if len(client.Peers()) != 0 {
+			t.Errorf("Wrong number of peers (%d)", len(client.Peers()))
+		}
+
+		err := client.AddPeer("127.0.0.1:8333")
+		if !errors.Is(err, ErrClientClosed) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+}
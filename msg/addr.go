@@ -0,0 +1,72 @@
+package msg
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// MaxAddrPerMsg represents the maximum number of addresses carried by addr msg.
+const MaxAddrPerMsg = 1000
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#addr
+type Addr struct {
+	// AddrList holds the known addresses of other nodes.
+	AddrList []*NetAddr
+}
+
+// Command returns addr command name.
+func (addr *Addr) Command() protocol.BitcoinCmdName {
+	return protocol.AddrCmd
+}
+
+// Encode encodes Addr into w.
+func (addr *Addr) Encode(w io.Writer, pver uint32) error {
+	if len(addr.AddrList) > MaxAddrPerMsg {
+		return fmt.Errorf("Too many addresses (%d), max is (%d)", len(addr.AddrList), MaxAddrPerMsg)
+	}
+
+	count := VarInt{
+		Length: uint(len(addr.AddrList)),
+	}
+	err := count.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	for _, netAddr := range addr.AddrList {
+		err = netAddr.EncodeWithTimestamp(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode decodes Addr from r.
+func (addr *Addr) Decode(r io.Reader, pver uint32) error {
+	count := VarInt{}
+	err := count.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	if count.Length > MaxAddrPerMsg {
+		return fmt.Errorf("Too many addresses (%d), max is (%d)", count.Length, MaxAddrPerMsg)
+	}
+
+	addr.AddrList = make([]*NetAddr, 0, count.Length)
+	for i := uint(0); i < count.Length; i++ {
+		netAddr := &NetAddr{}
+		err = netAddr.DecodeWithTimestamp(r)
+		if err != nil {
+			return err
+		}
+
+		addr.AddrList = append(addr.AddrList, netAddr)
+	}
+
+	return nil
+}
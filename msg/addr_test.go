@@ -0,0 +1,85 @@
+package msg
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAddr(t *testing.T) {
+	data := []byte{
+		// Count
+		0x01,
+		// Timestamp
+		0xe2, 0x15, 0x10, 0x4d,
+		// Services
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		// Ip
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0xff, 0xff, 0x0a, 0x00, 0x00, 0x01,
+		// Port
+		0x20, 0x8d,
+	}
+
+	sample := &Addr{
+		AddrList: []*NetAddr{
+			{
+				Timestamp: time.Unix(0x4d1015e2, 0),
+				Services:  0x00000001,
+				Ip:        net.ParseIP("10.0.0.1"),
+				Port:      8333,
+			},
+		},
+	}
+
+	t.Run("Decode", func(t *testing.T) {
+		b := bytes.NewBuffer(data)
+
+		addr := &Addr{}
+		err := addr.Decode(b, 0xea62)
+		if err != nil {
+			t.Errorf("Unable to decode (%s)", err)
+		}
+
+		if !reflect.DeepEqual(addr, sample) {
+			t.Error("Wrong decoding")
+		}
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		err := sample.Encode(b, 0xea62)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if bytes.Compare(b.Bytes(), data) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("should NOT encode more than MaxAddrPerMsg addresses", func(t *testing.T) {
+		addr := &Addr{
+			AddrList: make([]*NetAddr, MaxAddrPerMsg+1),
+		}
+
+		err := addr.Encode(bytes.NewBuffer([]byte{}), 0xea62)
+		if err == nil {
+			t.Error("Addr with too many addresses should NOT be encoded")
+		}
+	})
+
+	t.Run("should NOT decode more than MaxAddrPerMsg addresses", func(t *testing.T) {
+		// Count of 1001 addresses
+		b := bytes.NewBuffer([]byte{0xfd, 0xe9, 0x03})
+
+		addr := &Addr{}
+		err := addr.Decode(b, 0xea62)
+		if err == nil {
+			t.Error("Addr with too many addresses should NOT be decoded")
+		}
+	})
+}
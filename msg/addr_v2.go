@@ -0,0 +1,69 @@
+package msg
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// https://github.com/bitcoin/bips/blob/master/bip-0155.mediawiki
+type AddrV2 struct {
+	// AddrList holds the known addresses of other nodes.
+	AddrList []*NetAddrV2
+}
+
+// Command returns addrv2 command name.
+func (addr *AddrV2) Command() protocol.BitcoinCmdName {
+	return protocol.AddrV2Cmd
+}
+
+// Encode encodes AddrV2 into w.
+func (addr *AddrV2) Encode(w io.Writer, pver uint32) error {
+	if len(addr.AddrList) > MaxAddrPerMsg {
+		return fmt.Errorf("Too many addresses (%d), max is (%d)", len(addr.AddrList), MaxAddrPerMsg)
+	}
+
+	count := VarInt{
+		Length: uint(len(addr.AddrList)),
+	}
+	err := count.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	for _, netAddr := range addr.AddrList {
+		err = netAddr.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode decodes AddrV2 from r.
+func (addr *AddrV2) Decode(r io.Reader, pver uint32) error {
+	count := VarInt{}
+	err := count.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	if count.Length > MaxAddrPerMsg {
+		return fmt.Errorf("Too many addresses (%d), max is (%d)", count.Length, MaxAddrPerMsg)
+	}
+
+	addr.AddrList = make([]*NetAddrV2, 0, count.Length)
+	for i := uint(0); i < count.Length; i++ {
+		netAddr := &NetAddrV2{}
+		err = netAddr.Decode(r)
+		if err != nil {
+			return err
+		}
+
+		addr.AddrList = append(addr.AddrList, netAddr)
+	}
+
+	return nil
+}
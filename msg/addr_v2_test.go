@@ -0,0 +1,77 @@
+package msg
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAddrV2(t *testing.T) {
+	data := []byte{
+		// Count
+		0x01,
+		// Timestamp
+		0xe2, 0x15, 0x10, 0x4d,
+		// Services
+		0x01,
+		// Network ID
+		0x01,
+		// Address size
+		0x04,
+		// Address
+		0x0a, 0x00, 0x00, 0x01,
+		// Port
+		0x20, 0x8d,
+	}
+
+	sample := &AddrV2{
+		AddrList: []*NetAddrV2{
+			{
+				Timestamp: time.Unix(0x4d1015e2, 0),
+				Services:  0x00000001,
+				NetworkID: NetIPv4,
+				Addr:      []byte{0x0a, 0x00, 0x00, 0x01},
+				Port:      8333,
+			},
+		},
+	}
+
+	t.Run("Decode", func(t *testing.T) {
+		b := bytes.NewBuffer(data)
+
+		addr := &AddrV2{}
+		err := addr.Decode(b, 70016)
+		if err != nil {
+			t.Errorf("Unable to decode (%s)", err)
+		}
+
+		if !reflect.DeepEqual(addr, sample) {
+			t.Error("Wrong decoding")
+		}
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		err := sample.Encode(b, 70016)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if bytes.Compare(b.Bytes(), data) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("should NOT decode more than MaxAddrPerMsg addresses", func(t *testing.T) {
+		// Count of 1001 addresses
+		b := bytes.NewBuffer([]byte{0xfd, 0xe9, 0x03})
+
+		addr := &AddrV2{}
+		err := addr.Decode(b, 70016)
+		if err == nil {
+			t.Error("AddrV2 with too many addresses should NOT be decoded")
+		}
+	})
+}
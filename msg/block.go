@@ -0,0 +1,189 @@
+package msg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// minTxSize represents the minimum size of encoded Tx: version, empty inputs and outputs and lock time.
+const minTxSize = 10
+
+// maxTxPerBlock represents the maximum number of transactions fitting into a msg.
+const maxTxPerBlock = MaxPayloadSize / minTxSize
+
+// witnessCommitmentHeader represents the beginning of coinbase output committing to block witness data:
+// OP_RETURN, push of 36 bytes and the commitment header 0xaa21a9ed.
+var witnessCommitmentHeader = []byte{0x6a, 0x24, 0xaa, 0x21, 0xa9, 0xed}
+
+// witnessCommitmentSize represents the size of output script committing to block witness data.
+const witnessCommitmentSize = 38
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#block
+type Block struct {
+	// Header represents block header.
+	Header BlockHeader
+	// Transactions holds block transactions, the first one is the coinbase.
+	Transactions []*Tx
+}
+
+// Command returns block command name.
+func (block *Block) Command() protocol.BitcoinCmdName {
+	return protocol.BlockCmd
+}
+
+// BlockHash returns the hash of block header.
+func (block *Block) BlockHash() protocol.Hash {
+	return block.Header.BlockHash()
+}
+
+// Encode encodes Block into w.
+func (block *Block) Encode(w io.Writer, pver uint32) error {
+	err := block.Header.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	count := VarInt{
+		Length: uint(len(block.Transactions)),
+	}
+	err = count.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	for _, tx := range block.Transactions {
+		err = tx.Encode(w, pver)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode decodes Block from r.
+func (block *Block) Decode(r io.Reader, pver uint32) error {
+	err := block.Header.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	count := VarInt{}
+	err = count.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	if count.Length > maxTxPerBlock {
+		return fmt.Errorf("Too many transactions (%d), max is (%d)", count.Length, maxTxPerBlock)
+	}
+
+	err = checkRemaining(r, count.Length, minTxSize)
+	if err != nil {
+		return err
+	}
+
+	// Transactions grow as they are decoded, so a lying count cannot force a large allocation
+	block.Transactions = []*Tx{}
+	for i := uint(0); i < count.Length; i++ {
+		tx := &Tx{}
+		err = tx.Decode(r, pver)
+		if err != nil {
+			return err
+		}
+
+		block.Transactions = append(block.Transactions, tx)
+	}
+
+	return nil
+}
+
+// CheckMerkleRoot checks that header MerkleRoot matches the merkle root of block transaction hashes,
+// and that the merkle tree is not mutated.
+func (block *Block) CheckMerkleRoot() error {
+	if len(block.Transactions) == 0 {
+		return fmt.Errorf("Block has no transactions")
+	}
+
+	hashes := make([]protocol.Hash, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		hashes = append(hashes, tx.TxHash())
+	}
+
+	root, mutated := CalcMerkleRoot(hashes)
+	if mutated {
+		return fmt.Errorf("Block merkle tree is mutated (duplicated transactions)")
+	}
+
+	if root != block.Header.MerkleRoot {
+		return fmt.Errorf("Wrong merkle root (%s), expected (%s)", root, block.Header.MerkleRoot)
+	}
+
+	return nil
+}
+
+// CheckWitnessCommitment checks the coinbase commitment to block witness data.
+// Blocks without commitment cannot contain witness data.
+// https://github.com/bitcoin/bips/blob/master/bip-0141.mediawiki#commitment-structure
+func (block *Block) CheckWitnessCommitment() error {
+	if len(block.Transactions) == 0 {
+		return fmt.Errorf("Block has no transactions")
+	}
+
+	coinbase := block.Transactions[0]
+
+	commitment, ok := witnessCommitment(coinbase)
+	if !ok {
+		for _, tx := range block.Transactions {
+			if tx.HasWitness() {
+				return fmt.Errorf("Block without witness commitment has witness data (%s)", tx.TxHash())
+			}
+		}
+
+		return nil
+	}
+
+	// Coinbase witness holds the reserved value
+	if len(coinbase.TxIn) == 0 || len(coinbase.TxIn[0].Witness) != 1 || len(coinbase.TxIn[0].Witness[0]) != protocol.HashSize {
+		return fmt.Errorf("Wrong coinbase witness reserved value")
+	}
+
+	// Coinbase witness hash is zero
+	hashes := make([]protocol.Hash, 0, len(block.Transactions))
+	hashes = append(hashes, protocol.Hash{})
+	for _, tx := range block.Transactions[1:] {
+		hashes = append(hashes, tx.WitnessHash())
+	}
+
+	root, _ := CalcMerkleRoot(hashes)
+
+	b := bytes.NewBuffer(make([]byte, 0, 2*protocol.HashSize))
+	b.Write(root[:])
+	b.Write(coinbase.TxIn[0].Witness[0])
+
+	expected := protocol.DoubleHash(b.Bytes())
+	if expected != commitment {
+		return fmt.Errorf("Wrong witness commitment (%s), expected (%s)", commitment, expected)
+	}
+
+	return nil
+}
+
+// witnessCommitment returns the witness commitment of coinbase, held by its last output
+// starting with witnessCommitmentHeader.
+func witnessCommitment(coinbase *Tx) (protocol.Hash, bool) {
+	for i := len(coinbase.TxOut) - 1; i >= 0; i-- {
+		script := coinbase.TxOut[i].PkScript
+
+		if len(script) >= witnessCommitmentSize && bytes.HasPrefix(script, witnessCommitmentHeader) {
+			var commitment protocol.Hash
+			copy(commitment[:], script[len(witnessCommitmentHeader):witnessCommitmentSize])
+			return commitment, true
+		}
+	}
+
+	return protocol.Hash{}, false
+}
@@ -0,0 +1,140 @@
+package msg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// BlockHeaderSize represents the size of encoded BlockHeader in number of bytes.
+const BlockHeaderSize = 80
+
+// BlockHeader represents the header of a block.
+// https://en.bitcoin.it/wiki/Protocol_documentation#Block_Headers
+type BlockHeader struct {
+	// Version represents block version, used for signalling soft forks.
+	Version int32
+	// PrevBlock represents the hash of the previous block header.
+	PrevBlock protocol.Hash
+	// MerkleRoot represents the merkle tree root of the block transactions.
+	MerkleRoot protocol.Hash
+	// Timestamp represents the time the block was created.
+	Timestamp time.Time
+	// Bits represents the target of the block hash in compact form.
+	Bits uint32
+	// Nonce represents the value used for generating the block proof of work.
+	Nonce uint32
+}
+
+// Decode decodes BlockHeader from r.
+func (bh *BlockHeader) Decode(r io.Reader) error {
+	var version, unix uint32
+
+	vals := []DecodeVal{
+		{
+			Order: binary.LittleEndian,
+			Val:   &version,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &bh.PrevBlock,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &bh.MerkleRoot,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &unix,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &bh.Bits,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &bh.Nonce,
+		},
+	}
+
+	err := DecodeBatch(r, vals...)
+	if err != nil {
+		return err
+	}
+
+	bh.Version = int32(version)
+	bh.Timestamp = time.Unix(int64(unix), 0)
+
+	return nil
+}
+
+// Encode encodes BlockHeader into w.
+func (bh *BlockHeader) Encode(w io.Writer) error {
+	version := uint32(bh.Version)
+	unix := uint32(bh.Timestamp.Unix())
+
+	vals := []EncodeVal{
+		{
+			Order: binary.LittleEndian,
+			Val:   &version,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &bh.PrevBlock,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &bh.MerkleRoot,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &unix,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &bh.Bits,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &bh.Nonce,
+		},
+	}
+
+	return EncodeBatch(w, vals...)
+}
+
+// BlockHash returns the block hash, computed as sha256(sha256(header)).
+func (bh *BlockHeader) BlockHash() protocol.Hash {
+	b := bytes.NewBuffer(make([]byte, 0, BlockHeaderSize))
+
+	// Encoding into a buffer never fails
+	_ = bh.Encode(b)
+
+	return protocol.DoubleHash(b.Bytes())
+}
+
+// CheckProofOfWork checks that header Bits represent a valid target not greater than powLimit,
+// and that the block hash is not greater than such target.
+func (bh *BlockHeader) CheckProofOfWork(powLimit *big.Int) error {
+	target := protocol.CompactToBig(bh.Bits)
+
+	if target.Sign() <= 0 {
+		return fmt.Errorf("Block target (0x%08x) must be positive", bh.Bits)
+	}
+
+	if target.Cmp(powLimit) > 0 {
+		return fmt.Errorf("Block target (0x%08x) is greater than proof of work limit (0x%064x)", bh.Bits, powLimit)
+	}
+
+	hash := bh.BlockHash()
+	if protocol.HashToBig(&hash).Cmp(target) > 0 {
+		return fmt.Errorf("Block hash (%s) is greater than target (0x%064x)", hash, target)
+	}
+
+	return nil
+}
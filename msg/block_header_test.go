@@ -0,0 +1,127 @@
+package msg
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// genesisHeaderData holds mainnet genesis block header.
+var genesisHeaderData = []byte{
+	// Version
+	0x01, 0x00, 0x00, 0x00,
+	// PrevBlock
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	// MerkleRoot
+	0x3b, 0xa3, 0xed, 0xfd, 0x7a, 0x7b, 0x12, 0xb2,
+	0x7a, 0xc7, 0x2c, 0x3e, 0x67, 0x76, 0x8f, 0x61,
+	0x7f, 0xc8, 0x1b, 0xc3, 0x88, 0x8a, 0x51, 0x32,
+	0x3a, 0x9f, 0xb8, 0xaa, 0x4b, 0x1e, 0x5e, 0x4a,
+	// Timestamp
+	0x29, 0xab, 0x5f, 0x49,
+	// Bits
+	0xff, 0xff, 0x00, 0x1d,
+	// Nonce
+	0x1d, 0xac, 0x2b, 0x7c,
+}
+
+// genesisHeader represents mainnet genesis block header.
+var genesisHeader = &BlockHeader{
+	Version:   1,
+	PrevBlock: protocol.Hash{},
+	MerkleRoot: protocol.Hash{
+		0x3b, 0xa3, 0xed, 0xfd, 0x7a, 0x7b, 0x12, 0xb2,
+		0x7a, 0xc7, 0x2c, 0x3e, 0x67, 0x76, 0x8f, 0x61,
+		0x7f, 0xc8, 0x1b, 0xc3, 0x88, 0x8a, 0x51, 0x32,
+		0x3a, 0x9f, 0xb8, 0xaa, 0x4b, 0x1e, 0x5e, 0x4a,
+	},
+	Timestamp: time.Unix(1231006505, 0),
+	Bits:      0x1d00ffff,
+	Nonce:     2083236893,
+}
+
+func TestBlockHeader(t *testing.T) {
+	t.Run("Decode", func(t *testing.T) {
+		b := bytes.NewBuffer(genesisHeaderData)
+
+		bh := &BlockHeader{}
+		err := bh.Decode(b)
+		if err != nil {
+			t.Errorf("Unable to decode (%s)", err)
+		}
+
+		if !reflect.DeepEqual(bh, genesisHeader) {
+			t.Error("Wrong decoding")
+		}
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		err := genesisHeader.Encode(b)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if b.Len() != BlockHeaderSize {
+			t.Errorf("Wrong encoding size (%d)", b.Len())
+		}
+
+		if bytes.Compare(b.Bytes(), genesisHeaderData) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+}
+
+func TestBlockHash(t *testing.T) {
+	hash := genesisHeader.BlockHash()
+
+	expected := "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	if hash.String() != expected {
+		t.Errorf("Wrong block hash (%s)", hash)
+	}
+}
+
+func TestCheckProofOfWork(t *testing.T) {
+	powLimit := protocol.CompactToBig(0x1d00ffff)
+
+	t.Run("should accept genesis header", func(t *testing.T) {
+		err := genesisHeader.CheckProofOfWork(powLimit)
+		if err != nil {
+			t.Errorf("Genesis header should have valid proof of work (%s)", err)
+		}
+	})
+
+	t.Run("should NOT accept hash greater than target", func(t *testing.T) {
+		bh := *genesisHeader
+		bh.Nonce++
+
+		err := bh.CheckProofOfWork(powLimit)
+		if err == nil {
+			t.Error("Header with wrong nonce should NOT have valid proof of work")
+		}
+	})
+
+	t.Run("should NOT accept target greater than proof of work limit", func(t *testing.T) {
+		err := genesisHeader.CheckProofOfWork(protocol.CompactToBig(0x1c00ffff))
+		if err == nil {
+			t.Error("Header with target greater than limit should NOT have valid proof of work")
+		}
+	})
+
+	t.Run("should NOT accept negative target", func(t *testing.T) {
+		bh := *genesisHeader
+		bh.Bits = 0x1d80ffff
+
+		err := bh.CheckProofOfWork(powLimit)
+		if err == nil {
+			t.Error("Header with negative target should NOT have valid proof of work")
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package msg
+
+import (
+	"github.com/elmarsan/havel/protocol"
+)
+
+// NewBlockLocator returns block locator hashes of chain, where chain[i] is the hash of block at height i.
+// The 11 most recent hashes are included, then the step between hashes doubles,
+// genesis hash is always the last one.
+// https://en.bitcoin.it/wiki/Protocol_documentation#getblocks
+func NewBlockLocator(chain []protocol.Hash) []protocol.Hash {
+	locator := []protocol.Hash{}
+	if len(chain) == 0 {
+		return locator
+	}
+
+	step := 1
+	height := len(chain) - 1
+
+	for {
+		locator = append(locator, chain[height])
+
+		if height == 0 {
+			return locator
+		}
+
+		height -= step
+		if height < 0 {
+			height = 0
+		}
+
+		if len(locator) > 10 {
+			step *= 2
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package msg
+
+import (
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestNewBlockLocator(t *testing.T) {
+	// newChain returns chain where every hash holds its height.
+	newChain := func(size int) []protocol.Hash {
+		chain := make([]protocol.Hash, size)
+		for i := range chain {
+			chain[i][0] = uint8(i)
+			chain[i][1] = uint8(i >> 8)
+		}
+
+		return chain
+	}
+
+	heightOf := func(h protocol.Hash) int {
+		return int(h[0]) | int(h[1])<<8
+	}
+
+	t.Run("should return empty locator for empty chain", func(t *testing.T) {
+		locator := NewBlockLocator([]protocol.Hash{})
+		if len(locator) != 0 {
+			t.Errorf("Wrong locator size (%d)", len(locator))
+		}
+	})
+
+	t.Run("should include every hash of short chain", func(t *testing.T) {
+		locator := NewBlockLocator(newChain(5))
+
+		expected := []int{4, 3, 2, 1, 0}
+		if len(locator) != len(expected) {
+			t.Fatalf("Wrong locator size (%d)", len(locator))
+		}
+
+		for i, h := range locator {
+			if heightOf(h) != expected[i] {
+				t.Errorf("Wrong locator[%d] height: actual (%d), expected (%d)", i, heightOf(h), expected[i])
+			}
+		}
+	})
+
+	t.Run("should space hashes exponentially", func(t *testing.T) {
+		locator := NewBlockLocator(newChain(1000))
+
+		expected := []int{999, 998, 997, 996, 995, 994, 993, 992, 991, 990, 989, 988, 986, 982, 974, 958, 926, 862, 734, 478, 0}
+		if len(locator) != len(expected) {
+			t.Fatalf("Wrong locator size (%d)", len(locator))
+		}
+
+		for i, h := range locator {
+			if heightOf(h) != expected[i] {
+				t.Errorf("Wrong locator[%d] height: actual (%d), expected (%d)", i, heightOf(h), expected[i])
+			}
+		}
+	})
+}
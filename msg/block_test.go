@@ -0,0 +1,156 @@
+package msg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// newGenesisBlock returns mainnet genesis block.
+func newGenesisBlock(t *testing.T) *Block {
+	data, _ := hex.DecodeString(genesisCoinbaseHex)
+
+	coinbase := &Tx{}
+	err := coinbase.Decode(bytes.NewBuffer(data), 70016)
+	if err != nil {
+		t.Fatalf("Unable to decode coinbase (%s)", err)
+	}
+
+	return &Block{
+		Header:       *genesisHeader,
+		Transactions: []*Tx{coinbase},
+	}
+}
+
+// newSegwitBlock returns block with a segwit transaction and valid witness commitment.
+func newSegwitBlock() *Block {
+	reserved := make([]byte, protocol.HashSize)
+	tx := newSegwitTx()
+
+	root, _ := CalcMerkleRoot([]protocol.Hash{{}, tx.WitnessHash()})
+	commitment := protocol.DoubleHash(append(root[:], reserved...))
+
+	coinbase := &Tx{
+		Version: 2,
+		TxIn: []*TxIn{
+			{
+				PreviousOutPoint: OutPoint{Index: 0xffffffff},
+				SignatureScript:  []byte{0x03, 0x01, 0x02, 0x03},
+				Witness:          [][]byte{reserved},
+				Sequence:         0xffffffff,
+			},
+		},
+		TxOut: []*TxOut{
+			{
+				Value:    625000000,
+				PkScript: append([]byte{0x00, 0x14}, bytes.Repeat([]byte{0x33}, 20)...),
+			},
+			{
+				Value:    0,
+				PkScript: append(append([]byte{}, witnessCommitmentHeader...), commitment[:]...),
+			},
+		},
+	}
+
+	block := &Block{
+		Header:       BlockHeader{Version: 0x20000000, Bits: 0x207fffff},
+		Transactions: []*Tx{coinbase, tx},
+	}
+	block.Header.MerkleRoot, _ = CalcMerkleRoot([]protocol.Hash{coinbase.TxHash(), tx.TxHash()})
+
+	return block
+}
+
+func TestBlock(t *testing.T) {
+	t.Run("Encode and Decode", func(t *testing.T) {
+		for _, block := range []*Block{newGenesisBlock(t), newSegwitBlock()} {
+			b := bytes.NewBuffer([]byte{})
+			err := block.Encode(b, 70016)
+			if err != nil {
+				t.Fatalf("Unable to encode (%s)", err)
+			}
+
+			decoded := &Block{}
+			err = decoded.Decode(b, 70016)
+			if err != nil {
+				t.Fatalf("Unable to decode (%s)", err)
+			}
+
+			if decoded.BlockHash() != block.BlockHash() {
+				t.Errorf("Wrong block hash (%s)", decoded.BlockHash())
+			}
+
+			if len(decoded.Transactions) != len(block.Transactions) {
+				t.Errorf("Wrong number of transactions (%d)", len(decoded.Transactions))
+			}
+
+			if decoded.Transactions[0].WitnessHash() != block.Transactions[0].WitnessHash() {
+				t.Error("Wrong coinbase")
+			}
+		}
+	})
+
+	t.Run("should NOT allocate huge transaction count", func(t *testing.T) {
+		payload := append(make([]byte, BlockHeaderSize), 0xfe, 0x00, 0x00, 0x30, 0x00)
+
+		alloc, err := decodeAlloc(payload, &Block{})
+		if err == nil {
+			t.Error("Block claiming huge transaction count should NOT be decoded")
+		}
+
+		if alloc > 1<<20 {
+			t.Errorf("Too many bytes allocated (%d)", alloc)
+		}
+	})
+
+	t.Run("CheckMerkleRoot", func(t *testing.T) {
+		block := newGenesisBlock(t)
+		err := block.CheckMerkleRoot()
+		if err != nil {
+			t.Errorf("Genesis block merkle root should be valid (%s)", err)
+		}
+
+		block.Header.MerkleRoot = protocol.Hash{}
+		err = block.CheckMerkleRoot()
+		if err == nil {
+			t.Error("Wrong merkle root should be rejected")
+		}
+
+		// Duplicated transaction leads to same merkle root
+		block = newSegwitBlock()
+		block.Transactions = append(block.Transactions, block.Transactions[1])
+		err = block.CheckMerkleRoot()
+		if err == nil {
+			t.Error("Mutated block should be rejected")
+		}
+	})
+
+	t.Run("CheckWitnessCommitment", func(t *testing.T) {
+		err := newGenesisBlock(t).CheckWitnessCommitment()
+		if err != nil {
+			t.Errorf("Block without witness data should be valid (%s)", err)
+		}
+
+		block := newSegwitBlock()
+		err = block.CheckWitnessCommitment()
+		if err != nil {
+			t.Errorf("Witness commitment should be valid (%s)", err)
+		}
+
+		block.Transactions[1].TxIn[0].Witness[0][0] = 0x31
+		err = block.CheckWitnessCommitment()
+		if err == nil {
+			t.Error("Wrong witness commitment should be rejected")
+		}
+
+		// Witness data without commitment
+		block = newSegwitBlock()
+		block.Transactions[0].TxOut = block.Transactions[0].TxOut[:1]
+		err = block.CheckWitnessCommitment()
+		if err == nil {
+			t.Error("Witness data without commitment should be rejected")
+		}
+	})
+}
@@ -3,6 +3,8 @@ package msg
 import (
 	"encoding/binary"
 	"io"
+
+	"github.com/elmarsan/havel/protocol"
 )
 
 // readUint8 reads uint8 from r into d.
@@ -50,6 +52,15 @@ func readSlice(r io.Reader, order binary.ByteOrder, d *[]byte) error {
 	return nil
 }
 
+// readHash reads protocol.Hash from r into d.
+func readHash(r io.Reader, order binary.ByteOrder, d *protocol.Hash) error {
+	if err := binary.Read(r, order, d); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // Decode decodes r into d.
 func Decode(r io.Reader, order binary.ByteOrder, d interface{}) error {
 	switch t := d.(type) {
@@ -63,6 +74,8 @@ func Decode(r io.Reader, order binary.ByteOrder, d interface{}) error {
 		return readUint64(r, order, t)
 	case *[]byte:
 		return readSlice(r, order, t)
+	case *protocol.Hash:
+		return readHash(r, order, t)
 	}
 	return nil
 }
@@ -131,6 +144,15 @@ func writeSlice(w io.Writer, order binary.ByteOrder, d *[]byte) error {
 	return nil
 }
 
+// writeHash writes protocol.Hash from d into w.
+func writeHash(w io.Writer, order binary.ByteOrder, d *protocol.Hash) error {
+	if err := binary.Write(w, order, d); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // Encode encodes d into w.
 func Encode(w io.Writer, order binary.ByteOrder, d interface{}) error {
 	switch t := d.(type) {
@@ -144,6 +166,8 @@ func Encode(w io.Writer, order binary.ByteOrder, d interface{}) error {
 		return writeUint64(w, order, t)
 	case *[]byte:
 		return writeSlice(w, order, t)
+	case *protocol.Hash:
+		return writeHash(w, order, t)
 	}
 
 	return nil
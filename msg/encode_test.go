@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"testing"
+
+	"github.com/elmarsan/havel/protocol"
 )
 
 func TestReadUint8(t *testing.T) {
@@ -149,6 +151,31 @@ func TestReadSlice(t *testing.T) {
 	})
 }
 
+func TestReadHash(t *testing.T) {
+	d := bytes.Repeat([]byte{0xaa, 0xbb}, 16)
+	b := bytes.NewBuffer(d)
+
+	t.Run("should read hash", func(t *testing.T) {
+		var val protocol.Hash
+		err := readHash(b, binary.LittleEndian, &val)
+		if err != nil {
+			t.Error("Could not read hash")
+		}
+
+		if bytes.Compare(val[:], d) != 0 {
+			t.Errorf("Wrong hash read: actual (%v), expected (%v)", val, d)
+		}
+	})
+
+	t.Run("should NOT read hash", func(t *testing.T) {
+		var val protocol.Hash
+		err := readHash(b, binary.LittleEndian, &val)
+		if err == nil {
+			t.Error("An error should have occurred reading hash")
+		}
+	})
+}
+
 func TestDecodeBatch(t *testing.T) {
 	d := []byte{
 		0xaa, 0xbb, 0xcc, 0xdd,
@@ -435,3 +462,19 @@ func TestEncodeBatch(t *testing.T) {
 		}
 	})
 }
+
+func TestWriteHash(t *testing.T) {
+	t.Run("should write hash", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+		val := protocol.Hash{0xaa, 0xbb, 0xcc}
+
+		err := writeHash(b, binary.LittleEndian, &val)
+		if err != nil {
+			t.Error("Could not write hash")
+		}
+
+		if bytes.Compare(b.Bytes(), val[:]) != 0 {
+			t.Errorf("Wrong hash write: actual (%v), expected (%v)", b.Bytes(), val)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package msg
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// FeeFilter asks the peer not to announce transactions paying a fee rate lower than MinFeeRate.
+// https://github.com/bitcoin/bips/blob/master/bip-0133.mediawiki
+type FeeFilter struct {
+	// MinFeeRate represents the minimum fee rate in satoshis per kilo virtual byte.
+	MinFeeRate int64
+}
+
+// Command returns feefilter command name.
+func (feeFilter *FeeFilter) Command() protocol.BitcoinCmdName {
+	return protocol.FeeFilterCmd
+}
+
+// Encode encodes FeeFilter into w.
+// FeeFilter requires protocol version FeeFilterVersion.
+func (feeFilter *FeeFilter) Encode(w io.Writer, pver uint32) error {
+	err := checkVersion(feeFilter.Command(), pver, protocol.FeeFilterVersion)
+	if err != nil {
+		return err
+	}
+
+	feeRate := uint64(feeFilter.MinFeeRate)
+	return Encode(w, binary.LittleEndian, &feeRate)
+}
+
+// Decode decodes FeeFilter from r.
+// FeeFilter requires protocol version FeeFilterVersion.
+func (feeFilter *FeeFilter) Decode(r io.Reader, pver uint32) error {
+	err := checkVersion(feeFilter.Command(), pver, protocol.FeeFilterVersion)
+	if err != nil {
+		return err
+	}
+
+	var feeRate uint64
+	err = Decode(r, binary.LittleEndian, &feeRate)
+	if err != nil {
+		return err
+	}
+
+	feeFilter.MinFeeRate = int64(feeRate)
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package msg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestFeeFilter(t *testing.T) {
+	data := []byte{
+		// MinFeeRate
+		0xe8, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	sample := &FeeFilter{MinFeeRate: 1000}
+
+	t.Run("Decode", func(t *testing.T) {
+		feeFilter := &FeeFilter{}
+		err := feeFilter.Decode(bytes.NewBuffer(data), 70016)
+		if err != nil {
+			t.Errorf("Unable to decode (%s)", err)
+		}
+
+		if *feeFilter != *sample {
+			t.Errorf("Wrong fee rate (%d)", feeFilter.MinFeeRate)
+		}
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+		err := sample.Encode(b, 70016)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if bytes.Compare(b.Bytes(), data) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("should NOT encode before BIP133", func(t *testing.T) {
+		err := sample.Encode(bytes.NewBuffer([]byte{}), protocol.FeeFilterVersion-1)
+		if err == nil {
+			t.Error("FeeFilter should NOT be encoded before BIP133")
+		}
+	})
+
+	t.Run("should NOT decode before BIP133", func(t *testing.T) {
+		feeFilter := &FeeFilter{}
+		err := feeFilter.Decode(bytes.NewBuffer(data), protocol.FeeFilterVersion-1)
+		if err == nil {
+			t.Error("FeeFilter should NOT be decoded before BIP133")
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package msg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// WriteFrame writes header followed by payload into w.
+// Header Length and Checksum are computed from payload.
+func WriteFrame(w io.Writer, header *Header, payload []byte) error {
+	if len(payload) > MaxPayloadSize {
+		return fmt.Errorf("Payload too large (%d), size cannot be greater than (%d)", len(payload), MaxPayloadSize)
+	}
+
+	header.Length = uint32(len(payload))
+	header.Checksum = Checksum(payload)
+
+	b := bytes.NewBuffer(make([]byte, 0, HeaderSize+len(payload)))
+
+	err := header.Encode(b)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.Write(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// ReadFrame reads Header and payload from r.
+// Payload is only returned when its length and checksum match the ones declared in Header.
+func ReadFrame(r io.Reader) (*Header, []byte, error) {
+	header := &Header{}
+	err := header.Decode(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if header.Length > MaxPayloadSize {
+		return nil, nil, fmt.Errorf("Payload too large (%d), size cannot be greater than (%d)", header.Length, MaxPayloadSize)
+	}
+
+	payload := make([]byte, header.Length)
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to read payload (%s)", err)
+	}
+
+	checksum := Checksum(payload)
+	if checksum != header.Checksum {
+		return nil, nil, fmt.Errorf("Wrong checksum (0x%x), expected (0x%x)", checksum, header.Checksum)
+	}
+
+	return header, payload, nil
+}
@@ -0,0 +1,102 @@
+package msg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestFrame(t *testing.T) {
+	payload := []byte{0x01, 0x02, 0x03, 0x04}
+
+	data := []byte{
+		// Magic
+		0xf9, 0xbe, 0xb4, 0xd9,
+		// Command
+		0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		// Length
+		0x04, 0x00, 0x00, 0x00,
+		// Checksum
+		0x8d, 0xe4, 0x72, 0xe2,
+		// Payload
+		0x01, 0x02, 0x03, 0x04,
+	}
+
+	newHeader := func() *Header {
+		return &Header{
+			Magic: protocol.MainNet,
+			Cmd: protocol.BitcoinCmd{
+				HexData: protocol.VersionCmdData,
+				Name:    protocol.VersionCmd,
+			},
+		}
+	}
+
+	t.Run("WriteFrame", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		header := newHeader()
+		err := WriteFrame(b, header, payload)
+		if err != nil {
+			t.Errorf("Unable to write frame (%s)", err)
+		}
+
+		if header.Length != 4 {
+			t.Errorf("Wrong length (%d)", header.Length)
+		}
+
+		if header.Checksum != 0xe272e48d {
+			t.Errorf("Wrong checksum (0x%x)", header.Checksum)
+		}
+
+		if bytes.Compare(b.Bytes(), data) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("ReadFrame", func(t *testing.T) {
+		b := bytes.NewBuffer(data)
+
+		header, p, err := ReadFrame(b)
+		if err != nil {
+			t.Errorf("Unable to read frame (%s)", err)
+		}
+
+		if header.Cmd.Name != protocol.VersionCmd {
+			t.Errorf("Wrong command (%s)", header.Cmd.Name)
+		}
+
+		if bytes.Compare(p, payload) != 0 {
+			t.Error("Wrong payload")
+		}
+	})
+
+	t.Run("should NOT read frame with wrong checksum", func(t *testing.T) {
+		corrupted := append([]byte{}, data...)
+		corrupted[len(corrupted)-1] = 0xff
+
+		_, _, err := ReadFrame(bytes.NewBuffer(corrupted))
+		if err == nil {
+			t.Error("Frame with wrong checksum should NOT be read")
+		}
+	})
+
+	t.Run("should NOT read frame with truncated payload", func(t *testing.T) {
+		_, _, err := ReadFrame(bytes.NewBuffer(data[:len(data)-1]))
+		if err == nil {
+			t.Error("Frame with truncated payload should NOT be read")
+		}
+	})
+
+	t.Run("should NOT read frame with payload too large", func(t *testing.T) {
+		large := append([]byte{}, data[:HeaderSize]...)
+		large[16], large[17], large[18], large[19] = 0xff, 0xff, 0xff, 0xff
+
+		_, _, err := ReadFrame(bytes.NewBuffer(large))
+		if err == nil {
+			t.Error("Frame with payload too large should NOT be read")
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package msg
+
+import (
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#getaddr
+type GetAddr struct{}
+
+// Command returns getaddr command name.
+func (getAddr *GetAddr) Command() protocol.BitcoinCmdName {
+	return protocol.GetAddrCmd
+}
+
+// Encode encodes GetAddr into w.
+// GetAddr has no payload.
+func (getAddr *GetAddr) Encode(w io.Writer, pver uint32) error {
+	return nil
+}
+
+// Decode decodes GetAddr from r.
+// GetAddr has no payload.
+func (getAddr *GetAddr) Decode(r io.Reader, pver uint32) error {
+	return nil
+}
@@ -0,0 +1,40 @@
+package msg
+
+import (
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#getblocks
+type GetBlocks struct {
+	// Version represents the protocol version.
+	Version uint32
+	// BlockLocatorHashes holds block hashes, newest first, used to find the last common block.
+	BlockLocatorHashes []protocol.Hash
+	// HashStop represents the hash of the last desired block, zero to get as many as possible.
+	HashStop protocol.Hash
+}
+
+// Command returns getblocks command name.
+func (getBlocks *GetBlocks) Command() protocol.BitcoinCmdName {
+	return protocol.GetBlocksCmd
+}
+
+// Encode encodes GetBlocks into w.
+func (getBlocks *GetBlocks) Encode(w io.Writer, pver uint32) error {
+	return encodeLocator(w, getBlocks.Version, getBlocks.BlockLocatorHashes, &getBlocks.HashStop)
+}
+
+// Decode decodes GetBlocks from r.
+func (getBlocks *GetBlocks) Decode(r io.Reader, pver uint32) error {
+	version, hashes, err := decodeLocator(r, &getBlocks.HashStop)
+	if err != nil {
+		return err
+	}
+
+	getBlocks.Version = version
+	getBlocks.BlockLocatorHashes = hashes
+
+	return nil
+}
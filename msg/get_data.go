@@ -0,0 +1,34 @@
+package msg
+
+import (
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#getdata
+type GetData struct {
+	// InvList holds the requested inventory vectors.
+	InvList []*InvVec
+}
+
+// Command returns getdata command name.
+func (getData *GetData) Command() protocol.BitcoinCmdName {
+	return protocol.GetDataCmd
+}
+
+// Encode encodes GetData into w.
+func (getData *GetData) Encode(w io.Writer, pver uint32) error {
+	return encodeInvList(w, getData.InvList)
+}
+
+// Decode decodes GetData from r.
+func (getData *GetData) Decode(r io.Reader, pver uint32) error {
+	invList, err := decodeInvList(r)
+	if err != nil {
+		return err
+	}
+
+	getData.InvList = invList
+	return nil
+}
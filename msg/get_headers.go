@@ -0,0 +1,108 @@
+package msg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// MaxBlockLocatorsPerMsg represents the maximum number of block locator hashes carried by getheaders and getblocks msgs.
+const MaxBlockLocatorsPerMsg = 101
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#getheaders
+type GetHeaders struct {
+	// Version represents the protocol version.
+	Version uint32
+	// BlockLocatorHashes holds block hashes, newest first, used to find the last common block.
+	BlockLocatorHashes []protocol.Hash
+	// HashStop represents the hash of the last desired block header, zero to get as many as possible.
+	HashStop protocol.Hash
+}
+
+// Command returns getheaders command name.
+func (getHeaders *GetHeaders) Command() protocol.BitcoinCmdName {
+	return protocol.GetHeadersCmd
+}
+
+// Encode encodes GetHeaders into w.
+func (getHeaders *GetHeaders) Encode(w io.Writer, pver uint32) error {
+	return encodeLocator(w, getHeaders.Version, getHeaders.BlockLocatorHashes, &getHeaders.HashStop)
+}
+
+// Decode decodes GetHeaders from r.
+func (getHeaders *GetHeaders) Decode(r io.Reader, pver uint32) error {
+	version, hashes, err := decodeLocator(r, &getHeaders.HashStop)
+	if err != nil {
+		return err
+	}
+
+	getHeaders.Version = version
+	getHeaders.BlockLocatorHashes = hashes
+
+	return nil
+}
+
+// encodeLocator encodes version, VarInt counted locator hashes and hashStop into w.
+func encodeLocator(w io.Writer, version uint32, hashes []protocol.Hash, hashStop *protocol.Hash) error {
+	if len(hashes) > MaxBlockLocatorsPerMsg {
+		return fmt.Errorf("Too many block locator hashes (%d), max is (%d)", len(hashes), MaxBlockLocatorsPerMsg)
+	}
+
+	err := Encode(w, binary.LittleEndian, &version)
+	if err != nil {
+		return err
+	}
+
+	count := VarInt{
+		Length: uint(len(hashes)),
+	}
+	err = count.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	for i := range hashes {
+		err = Encode(w, binary.LittleEndian, &hashes[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return Encode(w, binary.LittleEndian, hashStop)
+}
+
+// decodeLocator decodes version, VarInt counted locator hashes and hashStop from r.
+func decodeLocator(r io.Reader, hashStop *protocol.Hash) (uint32, []protocol.Hash, error) {
+	var version uint32
+	err := Decode(r, binary.LittleEndian, &version)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	count := VarInt{}
+	err = count.Decode(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if count.Length > MaxBlockLocatorsPerMsg {
+		return 0, nil, fmt.Errorf("Too many block locator hashes (%d), max is (%d)", count.Length, MaxBlockLocatorsPerMsg)
+	}
+
+	hashes := make([]protocol.Hash, count.Length)
+	for i := range hashes {
+		err = Decode(r, binary.LittleEndian, &hashes[i])
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	err = Decode(r, binary.LittleEndian, hashStop)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return version, hashes, nil
+}
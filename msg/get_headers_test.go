@@ -0,0 +1,81 @@
+package msg
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestGetHeaders(t *testing.T) {
+	data := []byte{
+		// Version
+		0x80, 0x11, 0x01, 0x00,
+		// Count
+		0x02,
+		// Locator hashes
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		// Hash stop
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	locator := []protocol.Hash{{0x01}, {0x02}}
+
+	samples := map[string]struct {
+		empty  Message
+		sample Message
+	}{
+		"GetHeaders": {&GetHeaders{}, &GetHeaders{Version: 70016, BlockLocatorHashes: locator}},
+		"GetBlocks":  {&GetBlocks{}, &GetBlocks{Version: 70016, BlockLocatorHashes: locator}},
+	}
+
+	for name, s := range samples {
+		t.Run(name+" Decode", func(t *testing.T) {
+			b := bytes.NewBuffer(data)
+
+			err := s.empty.Decode(b, 70016)
+			if err != nil {
+				t.Errorf("Unable to decode (%s)", err)
+			}
+
+			if !reflect.DeepEqual(s.empty, s.sample) {
+				t.Error("Wrong decoding")
+			}
+		})
+
+		t.Run(name+" Encode", func(t *testing.T) {
+			b := bytes.NewBuffer([]byte{})
+
+			err := s.sample.Encode(b, 70016)
+			if err != nil {
+				t.Errorf("Unable to encode (%s)", err)
+			}
+
+			if bytes.Compare(b.Bytes(), data) != 0 {
+				t.Error("Wrong encoding")
+			}
+		})
+	}
+
+	t.Run("should NOT encode more than MaxBlockLocatorsPerMsg hashes", func(t *testing.T) {
+		getHeaders := &GetHeaders{
+			BlockLocatorHashes: make([]protocol.Hash, MaxBlockLocatorsPerMsg+1),
+		}
+
+		err := getHeaders.Encode(bytes.NewBuffer([]byte{}), 70016)
+		if err == nil {
+			t.Error("GetHeaders with too many locator hashes should NOT be encoded")
+		}
+	})
+}
@@ -1,12 +1,20 @@
 package msg
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
 
 	"github.com/elmarsan/havel/protocol"
 )
 
+// HeaderSize represents the size of encoded Header in number of bytes.
+const HeaderSize = 24
+
+// MaxPayloadSize represents the maximum size of msg payload in number of bytes.
+const MaxPayloadSize = 32 * 1024 * 1024
+
 // Header represents a default information contained in every protocol msg.
 // https://en.bitcoin.it/wiki/Protocol_documentation#Message_structure
 type Header struct {
@@ -84,9 +92,18 @@ func (header *Header) Decode(r io.Reader) error {
 
 	// Convert cmd to BitcoinCmd
 	err = header.Cmd.FromHex(cmd)
-	if err != nil {
-		return err
+	if errors.Is(err, protocol.ErrUnknownCmd) {
+		// Keep unknown commands, so their msgs can be skipped by readers
+		copy(header.Cmd.HexData[:], cmd)
+		header.Cmd.Name = protocol.BitcoinCmdName(bytes.TrimRight(cmd, "\x00"))
+		return nil
 	}
 
 	return err
 }
+
+// Checksum returns the first 4 bytes of sha256(sha256(payload)).
+func Checksum(payload []byte) uint32 {
+	hash := protocol.DoubleHash(payload)
+	return binary.LittleEndian.Uint32(hash[:4])
+}
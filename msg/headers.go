@@ -0,0 +1,89 @@
+package msg
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// MaxHeadersPerMsg represents the maximum number of block headers carried by headers msg.
+const MaxHeadersPerMsg = 2000
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#headers
+type Headers struct {
+	// Headers holds block headers.
+	Headers []*BlockHeader
+}
+
+// Command returns headers command name.
+func (headers *Headers) Command() protocol.BitcoinCmdName {
+	return protocol.HeadersCmd
+}
+
+// Encode encodes Headers into w.
+// Every header is followed by its transaction count, which is always zero.
+func (headers *Headers) Encode(w io.Writer, pver uint32) error {
+	if len(headers.Headers) > MaxHeadersPerMsg {
+		return fmt.Errorf("Too many headers (%d), max is (%d)", len(headers.Headers), MaxHeadersPerMsg)
+	}
+
+	count := VarInt{
+		Length: uint(len(headers.Headers)),
+	}
+	err := count.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	txCount := VarInt{}
+	for _, bh := range headers.Headers {
+		err = bh.Encode(w)
+		if err != nil {
+			return err
+		}
+
+		err = txCount.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode decodes Headers from r.
+func (headers *Headers) Decode(r io.Reader, pver uint32) error {
+	count := VarInt{}
+	err := count.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	if count.Length > MaxHeadersPerMsg {
+		return fmt.Errorf("Too many headers (%d), max is (%d)", count.Length, MaxHeadersPerMsg)
+	}
+
+	headers.Headers = make([]*BlockHeader, 0, count.Length)
+	for i := uint(0); i < count.Length; i++ {
+		bh := &BlockHeader{}
+		err = bh.Decode(r)
+		if err != nil {
+			return err
+		}
+
+		txCount := VarInt{}
+		err = txCount.Decode(r)
+		if err != nil {
+			return err
+		}
+
+		if txCount.Length != 0 {
+			return fmt.Errorf("Wrong header transaction count (%d), expected (0)", txCount.Length)
+		}
+
+		headers.Headers = append(headers.Headers, bh)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package msg
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestHeaders(t *testing.T) {
+	data := []byte{
+		// Count
+		0x01,
+	}
+	data = append(data, genesisHeaderData...)
+	// Transaction count
+	data = append(data, 0x00)
+
+	sample := &Headers{
+		Headers: []*BlockHeader{genesisHeader},
+	}
+
+	t.Run("Decode", func(t *testing.T) {
+		b := bytes.NewBuffer(data)
+
+		headers := &Headers{}
+		err := headers.Decode(b, 70016)
+		if err != nil {
+			t.Errorf("Unable to decode (%s)", err)
+		}
+
+		if !reflect.DeepEqual(headers, sample) {
+			t.Error("Wrong decoding")
+		}
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		err := sample.Encode(b, 70016)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if bytes.Compare(b.Bytes(), data) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("should NOT decode header with transactions", func(t *testing.T) {
+		corrupted := append([]byte{}, data...)
+		corrupted[len(corrupted)-1] = 0x01
+
+		headers := &Headers{}
+		err := headers.Decode(bytes.NewBuffer(corrupted), 70016)
+		if err == nil {
+			t.Error("Header with transactions should NOT be decoded")
+		}
+	})
+
+	t.Run("should NOT decode more than MaxHeadersPerMsg headers", func(t *testing.T) {
+		// Count of 2001 headers
+		b := bytes.NewBuffer([]byte{0xfd, 0xd1, 0x07})
+
+		headers := &Headers{}
+		err := headers.Decode(b, 70016)
+		if err == nil {
+			t.Error("Headers with too many headers should NOT be decoded")
+		}
+	})
+}
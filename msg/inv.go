@@ -0,0 +1,88 @@
+package msg
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// MaxInvPerMsg represents the maximum number of inventory vectors carried by inv, getdata and notfound msgs.
+const MaxInvPerMsg = 50000
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#inv
+type Inv struct {
+	// InvList holds the announced inventory vectors.
+	InvList []*InvVec
+}
+
+// Command returns inv command name.
+func (inv *Inv) Command() protocol.BitcoinCmdName {
+	return protocol.InvCmd
+}
+
+// Encode encodes Inv into w.
+func (inv *Inv) Encode(w io.Writer, pver uint32) error {
+	return encodeInvList(w, inv.InvList)
+}
+
+// Decode decodes Inv from r.
+func (inv *Inv) Decode(r io.Reader, pver uint32) error {
+	invList, err := decodeInvList(r)
+	if err != nil {
+		return err
+	}
+
+	inv.InvList = invList
+	return nil
+}
+
+// encodeInvList encodes VarInt counted invList into w.
+func encodeInvList(w io.Writer, invList []*InvVec) error {
+	if len(invList) > MaxInvPerMsg {
+		return fmt.Errorf("Too many inventory vectors (%d), max is (%d)", len(invList), MaxInvPerMsg)
+	}
+
+	count := VarInt{
+		Length: uint(len(invList)),
+	}
+	err := count.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	for _, iv := range invList {
+		err = iv.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeInvList decodes VarInt counted list of InvVec from r.
+func decodeInvList(r io.Reader) ([]*InvVec, error) {
+	count := VarInt{}
+	err := count.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if count.Length > MaxInvPerMsg {
+		return nil, fmt.Errorf("Too many inventory vectors (%d), max is (%d)", count.Length, MaxInvPerMsg)
+	}
+
+	invList := make([]*InvVec, 0, count.Length)
+	for i := uint(0); i < count.Length; i++ {
+		iv := &InvVec{}
+		err = iv.Decode(r)
+		if err != nil {
+			return nil, err
+		}
+
+		invList = append(invList, iv)
+	}
+
+	return invList, nil
+}
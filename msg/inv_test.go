@@ -0,0 +1,90 @@
+package msg
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestInvList(t *testing.T) {
+	data := []byte{
+		// Count
+		0x01,
+		// Type
+		0x02, 0x00, 0x00, 0x00,
+		// Hash
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0xcb, 0x1f, 0x16, 0xba, 0x92, 0x3a,
+		0x07, 0x3b, 0x79, 0xc3, 0xdf, 0x15, 0x8a, 0x92,
+		0x40, 0xd5, 0x2c, 0xa8, 0x1b, 0xba, 0x18, 0xbc,
+	}
+
+	hash := [32]byte{}
+	copy(hash[:], data[5:])
+
+	invList := []*InvVec{
+		{
+			Obj:  MSG_BLOCK,
+			Hash: hash,
+		},
+	}
+
+	samples := map[string]struct {
+		empty  Message
+		sample Message
+	}{
+		"Inv":      {&Inv{}, &Inv{InvList: invList}},
+		"GetData":  {&GetData{}, &GetData{InvList: invList}},
+		"NotFound": {&NotFound{}, &NotFound{InvList: invList}},
+	}
+
+	for name, s := range samples {
+		t.Run(name+" Decode", func(t *testing.T) {
+			b := bytes.NewBuffer(data)
+
+			err := s.empty.Decode(b, 70016)
+			if err != nil {
+				t.Errorf("Unable to decode (%s)", err)
+			}
+
+			if !reflect.DeepEqual(s.empty, s.sample) {
+				t.Error("Wrong decoding")
+			}
+		})
+
+		t.Run(name+" Encode", func(t *testing.T) {
+			b := bytes.NewBuffer([]byte{})
+
+			err := s.sample.Encode(b, 70016)
+			if err != nil {
+				t.Errorf("Unable to encode (%s)", err)
+			}
+
+			if bytes.Compare(b.Bytes(), data) != 0 {
+				t.Error("Wrong encoding")
+			}
+		})
+	}
+
+	t.Run("should NOT encode more than MaxInvPerMsg inventory vectors", func(t *testing.T) {
+		inv := &Inv{
+			InvList: make([]*InvVec, MaxInvPerMsg+1),
+		}
+
+		err := inv.Encode(bytes.NewBuffer([]byte{}), 70016)
+		if err == nil {
+			t.Error("Inv with too many inventory vectors should NOT be encoded")
+		}
+	})
+
+	t.Run("should NOT decode more than MaxInvPerMsg inventory vectors", func(t *testing.T) {
+		// Count of 50001 inventory vectors
+		b := bytes.NewBuffer([]byte{0xfd, 0x51, 0xc3})
+
+		getData := &GetData{}
+		err := getData.Decode(b, 70016)
+		if err == nil {
+			t.Error("GetData with too many inventory vectors should NOT be decoded")
+		}
+	})
+}
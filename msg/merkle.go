@@ -0,0 +1,43 @@
+package msg
+
+import (
+	"github.com/elmarsan/havel/protocol"
+)
+
+// CalcMerkleRoot returns the merkle tree root of hashes.
+// Levels with odd number of hashes duplicate the last one.
+// It also reports whether the tree is mutated, meaning that two identical hashes are paired at
+// any level, so different lists of hashes produce the same root (CVE-2012-2459).
+// https://en.bitcoin.it/wiki/Protocol_documentation#Merkle_Trees
+func CalcMerkleRoot(hashes []protocol.Hash) (protocol.Hash, bool) {
+	if len(hashes) == 0 {
+		return protocol.Hash{}, false
+	}
+
+	level := append([]protocol.Hash{}, hashes...)
+	mutated := false
+	node := make([]byte, 2*protocol.HashSize)
+
+	for len(level) > 1 {
+		for i := 0; i+1 < len(level); i += 2 {
+			if level[i] == level[i+1] {
+				mutated = true
+			}
+		}
+
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+
+		next := make([]protocol.Hash, 0, len(level)/2)
+		for i := 0; i < len(level); i += 2 {
+			copy(node, level[i][:])
+			copy(node[protocol.HashSize:], level[i+1][:])
+			next = append(next, protocol.DoubleHash(node))
+		}
+
+		level = next
+	}
+
+	return level[0], mutated
+}
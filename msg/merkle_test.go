@@ -0,0 +1,65 @@
+package msg
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// displayHash returns Hash from its byte-reversed hex representation.
+func displayHash(s string) protocol.Hash {
+	b, _ := hex.DecodeString(s)
+
+	var h protocol.Hash
+	for i := range b {
+		h[len(b)-1-i] = b[i]
+	}
+
+	return h
+}
+
+func TestCalcMerkleRoot(t *testing.T) {
+	// Mainnet block 100000 transactions
+	hashes := []protocol.Hash{
+		displayHash("8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87"),
+		displayHash("fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4"),
+		displayHash("6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4"),
+		displayHash("e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d"),
+	}
+
+	t.Run("should calculate merkle root", func(t *testing.T) {
+		root, mutated := CalcMerkleRoot(hashes)
+		if mutated {
+			t.Error("Merkle tree should NOT be mutated")
+		}
+
+		if root.String() != "f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766" {
+			t.Errorf("Wrong merkle root (%s)", root)
+		}
+	})
+
+	t.Run("should return the hash of single leaf tree", func(t *testing.T) {
+		root, mutated := CalcMerkleRoot(hashes[:1])
+		if mutated || root != hashes[0] {
+			t.Errorf("Wrong merkle root (%s)", root)
+		}
+	})
+
+	t.Run("should detect mutated tree (CVE-2012-2459)", func(t *testing.T) {
+		odd, mutated := CalcMerkleRoot(hashes[:3])
+		if mutated {
+			t.Fatal("Odd merkle tree should NOT be mutated")
+		}
+
+		// Duplicating last hash yields same root
+		root, mutated := CalcMerkleRoot(append(hashes[:3:3], hashes[2]))
+		if !mutated {
+			t.Error("Merkle tree should be mutated")
+		}
+
+		if root != odd {
+			t.Errorf("Wrong merkle root (%s), expected (%s)", root, odd)
+		}
+	})
+}
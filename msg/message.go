@@ -0,0 +1,138 @@
+package msg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// Message represents msg exchanged between nodes.
+// Message encoding and decoding only covers its payload, Header is handled by
+// WriteMessage and ReadMessage.
+type Message interface {
+	// Command returns the name of msg command.
+	Command() protocol.BitcoinCmdName
+	// Encode encodes msg payload into w using protocol version pver.
+	Encode(w io.Writer, pver uint32) error
+	// Decode decodes msg payload from r using protocol version pver.
+	Decode(r io.Reader, pver uint32) error
+}
+
+// messages is a map of commands back to the constructor of their Message.
+var messages = map[protocol.BitcoinCmdName]func() Message{
+	protocol.VersionCmd:     func() Message { return &Version{} },
+	protocol.VerackCmd:      func() Message { return &Verack{} },
+	protocol.AddrCmd:        func() Message { return &Addr{} },
+	protocol.AddrV2Cmd:      func() Message { return &AddrV2{} },
+	protocol.SendAddrV2Cmd:  func() Message { return &SendAddrV2{} },
+	protocol.GetAddrCmd:     func() Message { return &GetAddr{} },
+	protocol.InvCmd:         func() Message { return &Inv{} },
+	protocol.GetDataCmd:     func() Message { return &GetData{} },
+	protocol.NotFoundCmd:    func() Message { return &NotFound{} },
+	protocol.GetHeadersCmd:  func() Message { return &GetHeaders{} },
+	protocol.GetBlocksCmd:   func() Message { return &GetBlocks{} },
+	protocol.HeadersCmd:     func() Message { return &Headers{} },
+	protocol.TxCmd:          func() Message { return &Tx{} },
+	protocol.BlockCmd:       func() Message { return &Block{} },
+	protocol.PingCmd:        func() Message { return &Ping{} },
+	protocol.PongCmd:        func() Message { return &Pong{} },
+	protocol.RejectCmd:      func() Message { return &Reject{} },
+	protocol.SendHeadersCmd: func() Message { return &SendHeaders{} },
+	protocol.FeeFilterCmd:   func() Message { return &FeeFilter{} },
+	protocol.SendCmpctCmd:   func() Message { return &SendCmpct{} },
+	protocol.WtxidRelayCmd:  func() Message { return &WTxIDRelay{} },
+}
+
+// newMessage returns empty Message matching cmd.
+func newMessage(cmd protocol.BitcoinCmdName) Message {
+	if fn, ok := messages[cmd]; ok {
+		return fn()
+	}
+
+	return &Unknown{Cmd: cmd}
+}
+
+// checkVersion returns error when msg of cmd, supported from protocol version min,
+// is not supported by protocol version pver.
+func checkVersion(cmd protocol.BitcoinCmdName, pver, min uint32) error {
+	if pver < min {
+		return fmt.Errorf("%s msg is not supported by protocol version (%d), minimum (%d)", cmd, pver, min)
+	}
+
+	return nil
+}
+
+// WriteMessage writes m into w, framed with Header of net.
+func WriteMessage(w io.Writer, m Message, pver uint32, net protocol.BitcoinNet) error {
+	cmd := protocol.BitcoinCmd{}
+	err := cmd.FromString(string(m.Command()))
+	if err != nil {
+		return fmt.Errorf("Unable to encode %s (%s)", m.Command(), err)
+	}
+
+	payload := bytes.NewBuffer([]byte{})
+	err = m.Encode(payload, pver)
+	if err != nil {
+		return fmt.Errorf("Unable to encode %s (%s)", m.Command(), err)
+	}
+
+	header := &Header{
+		Magic: net,
+		Cmd:   cmd,
+	}
+
+	return WriteFrame(w, header, payload.Bytes())
+}
+
+// ReadMessage reads next msg of net from r.
+// Msgs with unknown command are returned as Unknown, so callers can skip them.
+func ReadMessage(r io.Reader, pver uint32, net protocol.BitcoinNet) (Message, error) {
+	header, payload, err := ReadFrame(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Magic != net {
+		return nil, fmt.Errorf("Wrong network (0x%x), expected (0x%x)", uint32(header.Magic), uint32(net))
+	}
+
+	m := newMessage(header.Cmd.Name)
+	err = m.Decode(bytes.NewReader(payload), pver)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode %s (%s)", header.Cmd.Name, err)
+	}
+
+	return m, nil
+}
+
+// Unknown represents msg whose command is not recognised.
+type Unknown struct {
+	// Cmd represents msg command name.
+	Cmd protocol.BitcoinCmdName
+	// Payload holds raw msg payload.
+	Payload []byte
+}
+
+// Command returns the name of Unknown command.
+func (unknown *Unknown) Command() protocol.BitcoinCmdName {
+	return unknown.Cmd
+}
+
+// Encode encodes Unknown into w.
+func (unknown *Unknown) Encode(w io.Writer, pver uint32) error {
+	_, err := w.Write(unknown.Payload)
+	return err
+}
+
+// Decode decodes Unknown from r.
+func (unknown *Unknown) Decode(r io.Reader, pver uint32) error {
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	unknown.Payload = payload
+	return nil
+}
@@ -0,0 +1,109 @@
+package msg
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestMessage(t *testing.T) {
+	verackData := []byte{
+		// Magic
+		0xf9, 0xbe, 0xb4, 0xd9,
+		// Command
+		0x76, 0x65, 0x72, 0x61, 0x63, 0x6b, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		// Length
+		0x00, 0x00, 0x00, 0x00,
+		// Checksum
+		0x5d, 0xf6, 0xe0, 0xe2,
+	}
+
+	unknownData := []byte{
+		// Magic
+		0xf9, 0xbe, 0xb4, 0xd9,
+		// Command
+		0x66, 0x6f, 0x6f, 0x62, 0x61, 0x72, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		// Length
+		0x04, 0x00, 0x00, 0x00,
+		// Checksum
+		0x8d, 0xe4, 0x72, 0xe2,
+		// Payload
+		0x01, 0x02, 0x03, 0x04,
+	}
+
+	t.Run("WriteMessage", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		err := WriteMessage(b, &Verack{}, 0xea62, protocol.MainNet)
+		if err != nil {
+			t.Errorf("Unable to write msg (%s)", err)
+		}
+
+		if bytes.Compare(b.Bytes(), verackData) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("ReadMessage", func(t *testing.T) {
+		b := bytes.NewBuffer(verackData)
+
+		m, err := ReadMessage(b, 0xea62, protocol.MainNet)
+		if err != nil {
+			t.Errorf("Unable to read msg (%s)", err)
+		}
+
+		if _, ok := m.(*Verack); !ok {
+			t.Errorf("Wrong msg type (%T)", m)
+		}
+	})
+
+	t.Run("should read unknown msg", func(t *testing.T) {
+		b := bytes.NewBuffer(append(unknownData, verackData...))
+
+		m, err := ReadMessage(b, 0xea62, protocol.MainNet)
+		if err != nil {
+			t.Errorf("Unable to read msg (%s)", err)
+		}
+
+		expected := &Unknown{
+			Cmd:     "foobar",
+			Payload: []byte{0x01, 0x02, 0x03, 0x04},
+		}
+
+		if !reflect.DeepEqual(m, expected) {
+			t.Errorf("Wrong unknown msg (%v)", m)
+		}
+
+		// Next msg should still be readable
+		m, err = ReadMessage(b, 0xea62, protocol.MainNet)
+		if err != nil {
+			t.Errorf("Unable to read msg (%s)", err)
+		}
+
+		if _, ok := m.(*Verack); !ok {
+			t.Errorf("Wrong msg type (%T)", m)
+		}
+	})
+
+	t.Run("should NOT read msg from different network", func(t *testing.T) {
+		b := bytes.NewBuffer(verackData)
+
+		_, err := ReadMessage(b, 0xea62, protocol.TestNet3)
+		if err == nil {
+			t.Error("Msg from different network should NOT be read")
+		}
+	})
+
+	t.Run("should NOT write unknown msg", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		err := WriteMessage(b, &Unknown{Cmd: "foobar"}, 0xea62, protocol.MainNet)
+		if err == nil {
+			t.Error("Unknown msg should NOT be written")
+		}
+	})
+}
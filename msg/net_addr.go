@@ -4,13 +4,19 @@ import (
 	"encoding/binary"
 	"io"
 	"net"
+	"time"
+
+	"github.com/elmarsan/havel/protocol"
 )
 
 // NetAddr represents network address of node.
 // https://en.bitcoin.it/wiki/Protocol_documentation#Network_address
 type NetAddr struct {
+	// Timestamp represents the last time the node was seen.
+	// It is only encoded by addr msgs, version msg addresses have no timestamp.
+	Timestamp time.Time
 	// Services represents bitfield of features to be enabled for this connection.
-	Services uint64
+	Services protocol.ServiceFlag
 	// Ip represents node's ip.
 	Ip net.IP
 	// Port represent node's port.
@@ -19,12 +25,13 @@ type NetAddr struct {
 
 // Decode decodes NetAddr from r.
 func (netAddr *NetAddr) Decode(r io.Reader) error {
+	var services uint64
 	ip := make([]byte, 16)
 
 	vals := []DecodeVal{
 		{
 			Order: binary.LittleEndian,
-			Val:   &netAddr.Services,
+			Val:   &services,
 		},
 		{
 			Order: binary.BigEndian,
@@ -41,6 +48,7 @@ func (netAddr *NetAddr) Decode(r io.Reader) error {
 		return err
 	}
 
+	netAddr.Services = protocol.ServiceFlag(services)
 	netAddr.Ip = ip
 
 	return nil
@@ -48,13 +56,14 @@ func (netAddr *NetAddr) Decode(r io.Reader) error {
 
 // Encode encodes NetAddr into w.
 func (netAddr *NetAddr) Encode(w io.Writer) error {
+	services := uint64(netAddr.Services)
 	ip := make([]byte, 16)
 	copy(ip[:], netAddr.Ip)
 
 	vals := []EncodeVal{
 		{
 			Order: binary.LittleEndian,
-			Val:   &netAddr.Services,
+			Val:   &services,
 		},
 		{
 			Order: binary.BigEndian,
@@ -68,3 +77,27 @@ func (netAddr *NetAddr) Encode(w io.Writer) error {
 
 	return EncodeBatch(w, vals...)
 }
+
+// DecodeWithTimestamp decodes NetAddr preceded by its Timestamp from r.
+func (netAddr *NetAddr) DecodeWithTimestamp(r io.Reader) error {
+	var unix uint32
+	err := Decode(r, binary.LittleEndian, &unix)
+	if err != nil {
+		return err
+	}
+
+	netAddr.Timestamp = time.Unix(int64(unix), 0)
+
+	return netAddr.Decode(r)
+}
+
+// EncodeWithTimestamp encodes NetAddr preceded by its Timestamp into w.
+func (netAddr *NetAddr) EncodeWithTimestamp(w io.Writer) error {
+	unix := uint32(netAddr.Timestamp.Unix())
+	err := Encode(w, binary.LittleEndian, &unix)
+	if err != nil {
+		return err
+	}
+
+	return netAddr.Encode(w)
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestNetAddr(t *testing.T) {
@@ -55,3 +56,51 @@ func TestNetAddr(t *testing.T) {
 		}
 	})
 }
+
+func TestNetAddrWithTimestamp(t *testing.T) {
+	data := []byte{
+		// Timestamp
+		0xe2, 0x15, 0x10, 0x4d,
+		// Services
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		// Ip
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0xff, 0xff, 0x0a, 0x00, 0x00, 0x01,
+		// Port
+		0x20, 0x8d,
+	}
+
+	sample := &NetAddr{
+		Timestamp: time.Unix(0x4d1015e2, 0),
+		Services:  0x00000001,
+		Ip:        net.ParseIP("10.0.0.1"),
+		Port:      8333,
+	}
+
+	t.Run("DecodeWithTimestamp", func(t *testing.T) {
+		b := bytes.NewBuffer(data)
+
+		netAddr := &NetAddr{}
+		err := netAddr.DecodeWithTimestamp(b)
+		if err != nil {
+			t.Error("Could not decode")
+		}
+
+		if !reflect.DeepEqual(netAddr, sample) {
+			t.Error("Wrong decoding")
+		}
+	})
+
+	t.Run("EncodeWithTimestamp", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		err := sample.EncodeWithTimestamp(b)
+		if err != nil {
+			t.Error("Could not encode")
+		}
+
+		if bytes.Compare(b.Bytes(), data) != 0 {
+			t.Errorf("Wrong encoding")
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package msg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// NetworkID represents the network of NetAddrV2.
+type NetworkID uint8
+
+// Network IDs defined by BIP155.
+const (
+	NetIPv4  NetworkID = 0x01
+	NetIPv6  NetworkID = 0x02
+	NetTorV2 NetworkID = 0x03
+	NetTorV3 NetworkID = 0x04
+	NetI2P   NetworkID = 0x05
+	NetCJDNS NetworkID = 0x06
+)
+
+// networkAddrSize is a map of known NetworkID back to their address size in number of bytes.
+var networkAddrSize = map[NetworkID]int{
+	NetIPv4:  4,
+	NetIPv6:  16,
+	NetTorV2: 10,
+	NetTorV3: 32,
+	NetI2P:   32,
+	NetCJDNS: 16,
+}
+
+// MaxAddrV2Size represents the maximum size of NetAddrV2 address in number of bytes.
+const MaxAddrV2Size = 512
+
+// onionCatPrefix represents the IPv6 prefix used for embedding Tor v2 addresses.
+var onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
+// NetAddrV2 represents network address of node, supporting non IP networks.
+// https://github.com/bitcoin/bips/blob/master/bip-0155.mediawiki
+type NetAddrV2 struct {
+	// Timestamp represents the last time the node was seen.
+	Timestamp time.Time
+	// Services represents bitfield of features to be enabled for this connection.
+	Services protocol.ServiceFlag
+	// NetworkID represents the network the address belongs to.
+	NetworkID NetworkID
+	// Addr holds the network address, its size depends on NetworkID.
+	Addr []byte
+	// Port represent node's port.
+	Port uint16
+}
+
+// NewNetAddrV2 returns NetAddrV2 from netAddr.
+// IPv4-mapped addresses are converted into IPv4 and OnionCat addresses into Tor v2.
+// It fails when netAddr IP is neither 4 nor 16 bytes.
+func NewNetAddrV2(netAddr *NetAddr) (*NetAddrV2, error) {
+	if len(netAddr.Ip) != net.IPv4len && len(netAddr.Ip) != net.IPv6len {
+		return nil, fmt.Errorf("Invalid address size (%d)", len(netAddr.Ip))
+	}
+
+	addrV2 := &NetAddrV2{
+		Timestamp: netAddr.Timestamp,
+		Services:  netAddr.Services,
+		Port:      netAddr.Port,
+	}
+
+	ip := netAddr.Ip.To16()
+
+	switch {
+	case netAddr.Ip.To4() != nil:
+		addrV2.NetworkID = NetIPv4
+		addrV2.Addr = []byte(netAddr.Ip.To4())
+	case bytes.HasPrefix(ip, onionCatPrefix):
+		addrV2.NetworkID = NetTorV2
+		addrV2.Addr = append([]byte{}, ip[len(onionCatPrefix):]...)
+	default:
+		addrV2.NetworkID = NetIPv6
+		addrV2.Addr = append([]byte{}, ip...)
+	}
+
+	return addrV2, nil
+}
+
+// ToNetAddr returns NetAddr from NetAddrV2.
+// Only IPv4, IPv6 and Tor v2 addresses can be represented as NetAddr.
+func (addrV2 *NetAddrV2) ToNetAddr() (*NetAddr, error) {
+	netAddr := &NetAddr{
+		Timestamp: addrV2.Timestamp,
+		Services:  addrV2.Services,
+		Port:      addrV2.Port,
+	}
+
+	switch addrV2.NetworkID {
+	case NetIPv4, NetIPv6:
+		netAddr.Ip = net.IP(addrV2.Addr).To16()
+	case NetTorV2:
+		netAddr.Ip = net.IP(append(append([]byte{}, onionCatPrefix...), addrV2.Addr...))
+	default:
+		return nil, fmt.Errorf("Network (%d) cannot be represented as NetAddr", addrV2.NetworkID)
+	}
+
+	if netAddr.Ip == nil {
+		return nil, fmt.Errorf("Invalid address size (%d)", len(addrV2.Addr))
+	}
+
+	return netAddr, nil
+}
+
+// Decode decodes NetAddrV2 from r.
+// Addresses of unknown networks are decoded, as long as they are not greater than MaxAddrV2Size.
+func (addrV2 *NetAddrV2) Decode(r io.Reader) error {
+	var unix uint32
+	err := Decode(r, binary.LittleEndian, &unix)
+	if err != nil {
+		return err
+	}
+
+	addrV2.Timestamp = time.Unix(int64(unix), 0)
+
+	services := VarInt{}
+	err = services.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	addrV2.Services = protocol.ServiceFlag(services.Length)
+
+	var networkID uint8
+	err = Decode(r, binary.LittleEndian, &networkID)
+	if err != nil {
+		return err
+	}
+
+	addrV2.NetworkID = NetworkID(networkID)
+
+	size := VarInt{}
+	err = size.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	if size.Length > MaxAddrV2Size {
+		return fmt.Errorf("Address too large (%d), size cannot be greater than (%d)", size.Length, MaxAddrV2Size)
+	}
+
+	if expected, ok := networkAddrSize[addrV2.NetworkID]; ok && int(size.Length) != expected {
+		return fmt.Errorf("Wrong address size (%d) for network (%d), expected (%d)", size.Length, networkID, expected)
+	}
+
+	addr := make([]byte, size.Length)
+	vals := []DecodeVal{
+		{
+			Order: binary.BigEndian,
+			Val:   &addr,
+		},
+		{
+			Order: binary.BigEndian,
+			Val:   &addrV2.Port,
+		},
+	}
+
+	err = DecodeBatch(r, vals...)
+	if err != nil {
+		return err
+	}
+
+	addrV2.Addr = addr
+
+	return nil
+}
+
+// Encode encodes NetAddrV2 into w.
+func (addrV2 *NetAddrV2) Encode(w io.Writer) error {
+	if len(addrV2.Addr) > MaxAddrV2Size {
+		return fmt.Errorf("Address too large (%d), size cannot be greater than (%d)", len(addrV2.Addr), MaxAddrV2Size)
+	}
+
+	unix := uint32(addrV2.Timestamp.Unix())
+	err := Encode(w, binary.LittleEndian, &unix)
+	if err != nil {
+		return err
+	}
+
+	services := VarInt{
+		Length: uint(addrV2.Services),
+	}
+	err = services.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	networkID := uint8(addrV2.NetworkID)
+	err = Encode(w, binary.LittleEndian, &networkID)
+	if err != nil {
+		return err
+	}
+
+	size := VarInt{
+		Length: uint(len(addrV2.Addr)),
+	}
+	err = size.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	addr := append([]byte{}, addrV2.Addr...)
+	vals := []EncodeVal{
+		{
+			Order: binary.BigEndian,
+			Val:   &addr,
+		},
+		{
+			Order: binary.BigEndian,
+			Val:   &addrV2.Port,
+		},
+	}
+
+	return EncodeBatch(w, vals...)
+}
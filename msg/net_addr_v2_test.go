@@ -0,0 +1,210 @@
+package msg
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNetAddrV2(t *testing.T) {
+	torV3 := bytes.Repeat([]byte{0xab}, 32)
+
+	data := []byte{
+		// Timestamp
+		0xe2, 0x15, 0x10, 0x4d,
+		// Services
+		0xfd, 0x09, 0x04,
+		// Network ID
+		0x04,
+		// Address size
+		0x20,
+	}
+	data = append(data, torV3...)
+	// Port
+	data = append(data, 0x20, 0x8d)
+
+	sample := &NetAddrV2{
+		Timestamp: time.Unix(0x4d1015e2, 0),
+		Services:  0x0409,
+		NetworkID: NetTorV3,
+		Addr:      torV3,
+		Port:      8333,
+	}
+
+	t.Run("Decode", func(t *testing.T) {
+		b := bytes.NewBuffer(data)
+
+		addrV2 := &NetAddrV2{}
+		err := addrV2.Decode(b)
+		if err != nil {
+			t.Errorf("Unable to decode (%s)", err)
+		}
+
+		if !reflect.DeepEqual(addrV2, sample) {
+			t.Error("Wrong decoding")
+		}
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		err := sample.Encode(b)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if bytes.Compare(b.Bytes(), data) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("should NOT decode address with wrong size for its network", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{
+			// Timestamp
+			0xe2, 0x15, 0x10, 0x4d,
+			// Services
+			0x01,
+			// Network ID (IPv4)
+			0x01,
+			// Address size
+			0x05,
+			0x0a, 0x00, 0x00, 0x01, 0x01,
+			// Port
+			0x20, 0x8d,
+		})
+
+		addrV2 := &NetAddrV2{}
+		err := addrV2.Decode(b)
+		if err == nil {
+			t.Error("Address with wrong size should NOT be decoded")
+		}
+	})
+
+	t.Run("should decode address of unknown network", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{
+			// Timestamp
+			0xe2, 0x15, 0x10, 0x4d,
+			// Services
+			0x01,
+			// Network ID
+			0x2a,
+			// Address size
+			0x03,
+			0x01, 0x02, 0x03,
+			// Port
+			0x20, 0x8d,
+		})
+
+		addrV2 := &NetAddrV2{}
+		err := addrV2.Decode(b)
+		if err != nil {
+			t.Errorf("Unable to decode (%s)", err)
+		}
+
+		if addrV2.NetworkID != 0x2a || addrV2.Port != 8333 {
+			t.Error("Wrong decoding")
+		}
+	})
+}
+
+func TestNetAddrV2Conversion(t *testing.T) {
+	t.Run("should convert IPv4 address", func(t *testing.T) {
+		netAddr := &NetAddr{
+			Services: 1,
+			Ip:       net.ParseIP("10.0.0.1"),
+			Port:     8333,
+		}
+
+		addrV2, err := NewNetAddrV2(netAddr)
+		if err != nil {
+			t.Fatalf("Unable to convert (%s)", err)
+		}
+		if addrV2.NetworkID != NetIPv4 || len(addrV2.Addr) != 4 {
+			t.Errorf("Wrong conversion (%d, %v)", addrV2.NetworkID, addrV2.Addr)
+		}
+
+		converted, err := addrV2.ToNetAddr()
+		if err != nil {
+			t.Errorf("Unable to convert (%s)", err)
+		}
+
+		if !reflect.DeepEqual(converted, netAddr) {
+			t.Error("Wrong conversion")
+		}
+	})
+
+	t.Run("should convert IPv6 address", func(t *testing.T) {
+		netAddr := &NetAddr{
+			Services: 1,
+			Ip:       net.ParseIP("2001:db8::1"),
+			Port:     8333,
+		}
+
+		addrV2, err := NewNetAddrV2(netAddr)
+		if err != nil {
+			t.Fatalf("Unable to convert (%s)", err)
+		}
+		if addrV2.NetworkID != NetIPv6 || len(addrV2.Addr) != 16 {
+			t.Errorf("Wrong conversion (%d, %v)", addrV2.NetworkID, addrV2.Addr)
+		}
+
+		converted, err := addrV2.ToNetAddr()
+		if err != nil {
+			t.Errorf("Unable to convert (%s)", err)
+		}
+
+		if !reflect.DeepEqual(converted, netAddr) {
+			t.Error("Wrong conversion")
+		}
+	})
+
+	t.Run("should convert OnionCat address into Tor v2", func(t *testing.T) {
+		netAddr := &NetAddr{
+			Services: 1,
+			Ip:       net.ParseIP("fd87:d87e:eb43:102:304:506:708:90a"),
+			Port:     8333,
+		}
+
+		addrV2, err := NewNetAddrV2(netAddr)
+		if err != nil {
+			t.Fatalf("Unable to convert (%s)", err)
+		}
+		expected := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a}
+		if addrV2.NetworkID != NetTorV2 || bytes.Compare(addrV2.Addr, expected) != 0 {
+			t.Errorf("Wrong conversion (%d, %v)", addrV2.NetworkID, addrV2.Addr)
+		}
+
+		converted, err := addrV2.ToNetAddr()
+		if err != nil {
+			t.Errorf("Unable to convert (%s)", err)
+		}
+
+		if !reflect.DeepEqual(converted, netAddr) {
+			t.Error("Wrong conversion")
+		}
+	})
+
+	t.Run("should NOT convert Tor v3 address", func(t *testing.T) {
+		addrV2 := &NetAddrV2{
+			NetworkID: NetTorV3,
+			Addr:      bytes.Repeat([]byte{0xab}, 32),
+			Port:      8333,
+		}
+
+		_, err := addrV2.ToNetAddr()
+		if err == nil {
+			t.Error("Tor v3 address should NOT be converted")
+		}
+	})
+
+	t.Run("should NOT convert invalid address", func(t *testing.T) {
+		for _, ip := range []net.IP{nil, {0x01, 0x02}, make(net.IP, 5)} {
+			_, err := NewNetAddrV2(&NetAddr{Ip: ip, Port: 8333})
+			if err == nil {
+				t.Errorf("Address (%v) should NOT be converted", []byte(ip))
+			}
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package msg
+
+import (
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#notfound
+type NotFound struct {
+	// InvList holds the inventory vectors which could not be served.
+	InvList []*InvVec
+}
+
+// Command returns notfound command name.
+func (notFound *NotFound) Command() protocol.BitcoinCmdName {
+	return protocol.NotFoundCmd
+}
+
+// Encode encodes NotFound into w.
+func (notFound *NotFound) Encode(w io.Writer, pver uint32) error {
+	return encodeInvList(w, notFound.InvList)
+}
+
+// Decode decodes NotFound from r.
+func (notFound *NotFound) Decode(r io.Reader, pver uint32) error {
+	invList, err := decodeInvList(r)
+	if err != nil {
+		return err
+	}
+
+	notFound.InvList = invList
+	return nil
+}
@@ -0,0 +1,38 @@
+package msg
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#ping
+type Ping struct {
+	// Nonce represents random nonce, which must be sent back in pong msg.
+	// Nonce is only encoded for protocol versions greater than protocol.BIP0031Version.
+	Nonce uint64
+}
+
+// Command returns ping command name.
+func (ping *Ping) Command() protocol.BitcoinCmdName {
+	return protocol.PingCmd
+}
+
+// Encode encodes Ping into w.
+func (ping *Ping) Encode(w io.Writer, pver uint32) error {
+	if pver <= protocol.BIP0031Version {
+		return nil
+	}
+
+	return Encode(w, binary.LittleEndian, &ping.Nonce)
+}
+
+// Decode decodes Ping from r.
+func (ping *Ping) Decode(r io.Reader, pver uint32) error {
+	if pver <= protocol.BIP0031Version {
+		return nil
+	}
+
+	return Decode(r, binary.LittleEndian, &ping.Nonce)
+}
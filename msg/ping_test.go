@@ -0,0 +1,53 @@
+package msg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestPing(t *testing.T) {
+	data := []byte{
+		// Nonce
+		0xef, 0xcd, 0xab, 0x89, 0x67, 0x45, 0x23, 0x01,
+	}
+
+	sample := &Ping{Nonce: 0x0123456789abcdef}
+
+	t.Run("Decode", func(t *testing.T) {
+		ping := &Ping{}
+		err := ping.Decode(bytes.NewBuffer(data), 70016)
+		if err != nil {
+			t.Errorf("Unable to decode (%s)", err)
+		}
+
+		if *ping != *sample {
+			t.Errorf("Wrong nonce (0x%x)", ping.Nonce)
+		}
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+		err := sample.Encode(b, 70016)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if bytes.Compare(b.Bytes(), data) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("should NOT encode nonce before BIP31", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+		err := sample.Encode(b, protocol.BIP0031Version)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if b.Len() != 0 {
+			t.Errorf("Wrong payload size (%d)", b.Len())
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package msg
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#pong
+type Pong struct {
+	// Nonce represents the nonce of answered ping msg.
+	Nonce uint64
+}
+
+// Command returns pong command name.
+func (pong *Pong) Command() protocol.BitcoinCmdName {
+	return protocol.PongCmd
+}
+
+// Encode encodes Pong into w.
+func (pong *Pong) Encode(w io.Writer, pver uint32) error {
+	return Encode(w, binary.LittleEndian, &pong.Nonce)
+}
+
+// Decode decodes Pong from r.
+func (pong *Pong) Decode(r io.Reader, pver uint32) error {
+	return Decode(r, binary.LittleEndian, &pong.Nonce)
+}
@@ -0,0 +1,39 @@
+package msg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPong(t *testing.T) {
+	data := []byte{
+		// Nonce
+		0xef, 0xcd, 0xab, 0x89, 0x67, 0x45, 0x23, 0x01,
+	}
+
+	sample := &Pong{Nonce: 0x0123456789abcdef}
+
+	t.Run("Decode", func(t *testing.T) {
+		pong := &Pong{}
+		err := pong.Decode(bytes.NewBuffer(data), 70016)
+		if err != nil {
+			t.Errorf("Unable to decode (%s)", err)
+		}
+
+		if *pong != *sample {
+			t.Errorf("Wrong nonce (0x%x)", pong.Nonce)
+		}
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+		err := sample.Encode(b, 70016)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if bytes.Compare(b.Bytes(), data) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package msg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// RejectCode represents the reason of rejecting a msg.
+type RejectCode uint8
+
+const (
+	RejectMalformed       RejectCode = 0x01
+	RejectInvalid         RejectCode = 0x10
+	RejectObsolete        RejectCode = 0x11
+	RejectDuplicate       RejectCode = 0x12
+	RejectNonstandard     RejectCode = 0x40
+	RejectDust            RejectCode = 0x41
+	RejectInsufficientFee RejectCode = 0x42
+	RejectCheckpoint      RejectCode = 0x43
+)
+
+var (
+	ErrRejectMalformed       = errors.New("Malformed msg")
+	ErrRejectInvalid         = errors.New("Invalid msg")
+	ErrRejectObsolete        = errors.New("Obsolete msg")
+	ErrRejectDuplicate       = errors.New("Duplicated msg")
+	ErrRejectNonstandard     = errors.New("Nonstandard transaction")
+	ErrRejectDust            = errors.New("Dust transaction")
+	ErrRejectInsufficientFee = errors.New("Insufficient fee")
+	ErrRejectCheckpoint      = errors.New("Block conflicting with checkpoint")
+	ErrRejectUnknown         = errors.New("Unknown reject code")
+)
+
+// rejectCodeErr is a map of reject codes to their error value.
+var rejectCodeErr = map[RejectCode]error{
+	RejectMalformed:       ErrRejectMalformed,
+	RejectInvalid:         ErrRejectInvalid,
+	RejectObsolete:        ErrRejectObsolete,
+	RejectDuplicate:       ErrRejectDuplicate,
+	RejectNonstandard:     ErrRejectNonstandard,
+	RejectDust:            ErrRejectDust,
+	RejectInsufficientFee: ErrRejectInsufficientFee,
+	RejectCheckpoint:      ErrRejectCheckpoint,
+}
+
+// Err returns the error value of code, ErrRejectUnknown for unknown codes.
+func (code RejectCode) Err() error {
+	if err, ok := rejectCodeErr[code]; ok {
+		return err
+	}
+
+	return ErrRejectUnknown
+}
+
+// String returns human-readable representation of code.
+func (code RejectCode) String() string {
+	return fmt.Sprintf("%s (0x%02x)", code.Err(), uint8(code))
+}
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#reject
+type Reject struct {
+	// Cmd represents the command of rejected msg.
+	Cmd protocol.BitcoinCmdName
+	// Code represents the reason of rejection.
+	Code RejectCode
+	// Reason represents human-readable reason of rejection.
+	Reason string
+	// Hash represents the hash of rejected block or transaction,
+	// only present when Cmd is block or tx.
+	Hash protocol.Hash
+}
+
+// Command returns reject command name.
+func (reject *Reject) Command() protocol.BitcoinCmdName {
+	return protocol.RejectCmd
+}
+
+// Encode encodes Reject into w.
+func (reject *Reject) Encode(w io.Writer, pver uint32) error {
+	err := encodeVarBytes(w, []byte(reject.Cmd))
+	if err != nil {
+		return err
+	}
+
+	code := uint8(reject.Code)
+	err = Encode(w, binary.LittleEndian, &code)
+	if err != nil {
+		return err
+	}
+
+	err = encodeVarBytes(w, []byte(reject.Reason))
+	if err != nil {
+		return err
+	}
+
+	if reject.hasHash() {
+		return Encode(w, binary.LittleEndian, &reject.Hash)
+	}
+
+	return nil
+}
+
+// Decode decodes Reject from r.
+func (reject *Reject) Decode(r io.Reader, pver uint32) error {
+	cmd, err := decodeVarBytes(r)
+	if err != nil {
+		return err
+	}
+
+	reject.Cmd = protocol.BitcoinCmdName(cmd)
+
+	var code uint8
+	err = Decode(r, binary.LittleEndian, &code)
+	if err != nil {
+		return err
+	}
+
+	reject.Code = RejectCode(code)
+
+	reason, err := decodeVarBytes(r)
+	if err != nil {
+		return err
+	}
+
+	reject.Reason = string(reason)
+
+	if reject.hasHash() {
+		return Decode(r, binary.LittleEndian, &reject.Hash)
+	}
+
+	return nil
+}
+
+// hasHash returns whether reject holds the hash of rejected object.
+func (reject *Reject) hasHash() bool {
+	return reject.Cmd == protocol.BlockCmd || reject.Cmd == protocol.TxCmd
+}
+
+// RejectError represents the rejection of a msg by a peer.
+// It wraps the error value of reject code, so it can be checked using errors.Is.
+type RejectError struct {
+	// Reject represents the received reject msg.
+	Reject *Reject
+}
+
+// Error returns the description of the rejection.
+func (err *RejectError) Error() string {
+	if err.Reject.hasHash() {
+		return fmt.Sprintf("Rejected %s %s: %s (%s)", err.Reject.Cmd, err.Reject.Hash, err.Reject.Code, err.Reject.Reason)
+	}
+
+	return fmt.Sprintf("Rejected %s: %s (%s)", err.Reject.Cmd, err.Reject.Code, err.Reject.Reason)
+}
+
+// Unwrap returns the error value of reject code.
+func (err *RejectError) Unwrap() error {
+	return err.Reject.Code.Err()
+}
@@ -0,0 +1,98 @@
+package msg
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestReject(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		sample *Reject
+	}{
+		{
+			name: "version",
+			data: []byte{
+				// Cmd
+				0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+				// Code
+				0x11,
+				// Reason
+				0x0d, 0x6f, 0x62, 0x73, 0x6f, 0x6c, 0x65, 0x74,
+				0x65, 0x20, 0x70, 0x65, 0x65, 0x72,
+			},
+			sample: &Reject{
+				Cmd:    protocol.VersionCmd,
+				Code:   RejectObsolete,
+				Reason: "obsolete peer",
+			},
+		},
+		{
+			name: "tx",
+			data: append([]byte{
+				// Cmd
+				0x02, 0x74, 0x78,
+				// Code
+				0x42,
+				// Reason
+				0x07, 0x6c, 0x6f, 0x77, 0x20, 0x66, 0x65, 0x65,
+			},
+				// Hash
+				bytes.Repeat([]byte{0xab}, 32)...),
+			sample: &Reject{
+				Cmd:    protocol.TxCmd,
+				Code:   RejectInsufficientFee,
+				Reason: "low fee",
+				Hash: protocol.Hash{
+					0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab,
+					0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab,
+					0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab,
+					0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab, 0xab,
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("Decode "+test.name, func(t *testing.T) {
+			reject := &Reject{}
+			err := reject.Decode(bytes.NewBuffer(test.data), 70016)
+			if err != nil {
+				t.Errorf("Unable to decode (%s)", err)
+			}
+
+			if !reflect.DeepEqual(reject, test.sample) {
+				t.Errorf("Wrong decoding (%+v)", reject)
+			}
+		})
+
+		t.Run("Encode "+test.name, func(t *testing.T) {
+			b := bytes.NewBuffer([]byte{})
+			err := test.sample.Encode(b, 70016)
+			if err != nil {
+				t.Errorf("Unable to encode (%s)", err)
+			}
+
+			if bytes.Compare(b.Bytes(), test.data) != 0 {
+				t.Error("Wrong encoding")
+			}
+		})
+	}
+
+	t.Run("RejectError", func(t *testing.T) {
+		var err error = &RejectError{Reject: tests[0].sample}
+
+		if !errors.Is(err, ErrRejectObsolete) {
+			t.Errorf("Wrong error value (%s)", err)
+		}
+
+		if errors.Is(&RejectError{Reject: &Reject{Code: 0x99}}, ErrRejectObsolete) {
+			t.Error("Unknown reject code should NOT match")
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package msg
+
+import (
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// SendAddrV2 signals preference for receiving addrv2 msgs instead of addr msgs.
+// https://github.com/bitcoin/bips/blob/master/bip-0155.mediawiki
+type SendAddrV2 struct{}
+
+// Command returns sendaddrv2 command name.
+func (sendAddrV2 *SendAddrV2) Command() protocol.BitcoinCmdName {
+	return protocol.SendAddrV2Cmd
+}
+
+// Encode encodes SendAddrV2 into w.
+// SendAddrV2 has no payload.
+func (sendAddrV2 *SendAddrV2) Encode(w io.Writer, pver uint32) error {
+	return nil
+}
+
+// Decode decodes SendAddrV2 from r.
+// SendAddrV2 has no payload.
+func (sendAddrV2 *SendAddrV2) Decode(r io.Reader, pver uint32) error {
+	return nil
+}
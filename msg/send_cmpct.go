@@ -0,0 +1,80 @@
+package msg
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// SendCmpct signals support for compact blocks.
+// https://github.com/bitcoin/bips/blob/master/bip-0152.mediawiki
+type SendCmpct struct {
+	// Announce represents whether new blocks should be announced by cmpctblock msg.
+	Announce bool
+	// Version represents the compact blocks version, 2 for blocks including witness data.
+	Version uint64
+}
+
+// Command returns sendcmpct command name.
+func (sendCmpct *SendCmpct) Command() protocol.BitcoinCmdName {
+	return protocol.SendCmpctCmd
+}
+
+// Encode encodes SendCmpct into w.
+// SendCmpct requires protocol version ShortIDsBlocksVersion.
+func (sendCmpct *SendCmpct) Encode(w io.Writer, pver uint32) error {
+	err := checkVersion(sendCmpct.Command(), pver, protocol.ShortIDsBlocksVersion)
+	if err != nil {
+		return err
+	}
+
+	var announce uint8
+	if sendCmpct.Announce {
+		announce = 0x01
+	}
+
+	vals := []EncodeVal{
+		{
+			Order: binary.LittleEndian,
+			Val:   &announce,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &sendCmpct.Version,
+		},
+	}
+
+	return EncodeBatch(w, vals...)
+}
+
+// Decode decodes SendCmpct from r.
+// SendCmpct requires protocol version ShortIDsBlocksVersion.
+func (sendCmpct *SendCmpct) Decode(r io.Reader, pver uint32) error {
+	err := checkVersion(sendCmpct.Command(), pver, protocol.ShortIDsBlocksVersion)
+	if err != nil {
+		return err
+	}
+
+	var announce uint8
+
+	vals := []DecodeVal{
+		{
+			Order: binary.LittleEndian,
+			Val:   &announce,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &sendCmpct.Version,
+		},
+	}
+
+	err = DecodeBatch(r, vals...)
+	if err != nil {
+		return err
+	}
+
+	sendCmpct.Announce = announce == 0x01
+
+	return nil
+}
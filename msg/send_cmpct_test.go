@@ -0,0 +1,50 @@
+package msg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestSendCmpct(t *testing.T) {
+	data := []byte{
+		// Announce
+		0x01,
+		// Version
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+
+	sample := &SendCmpct{Announce: true, Version: 2}
+
+	t.Run("Decode", func(t *testing.T) {
+		sendCmpct := &SendCmpct{}
+		err := sendCmpct.Decode(bytes.NewBuffer(data), 70016)
+		if err != nil {
+			t.Errorf("Unable to decode (%s)", err)
+		}
+
+		if *sendCmpct != *sample {
+			t.Error("Wrong decoding")
+		}
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+		err := sample.Encode(b, 70016)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if bytes.Compare(b.Bytes(), data) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("should NOT encode before BIP152", func(t *testing.T) {
+		err := sample.Encode(bytes.NewBuffer([]byte{}), protocol.ShortIDsBlocksVersion-1)
+		if err == nil {
+			t.Error("SendCmpct should NOT be encoded before BIP152")
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package msg
+
+import (
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// SendHeaders signals preference for receiving new blocks announced by headers msg instead of inv msg.
+// https://github.com/bitcoin/bips/blob/master/bip-0130.mediawiki
+type SendHeaders struct{}
+
+// Command returns sendheaders command name.
+func (sendHeaders *SendHeaders) Command() protocol.BitcoinCmdName {
+	return protocol.SendHeadersCmd
+}
+
+// Encode encodes SendHeaders into w.
+// SendHeaders has no payload, it requires protocol version SendHeadersVersion.
+func (sendHeaders *SendHeaders) Encode(w io.Writer, pver uint32) error {
+	return checkVersion(sendHeaders.Command(), pver, protocol.SendHeadersVersion)
+}
+
+// Decode decodes SendHeaders from r.
+// SendHeaders has no payload, it requires protocol version SendHeadersVersion.
+func (sendHeaders *SendHeaders) Decode(r io.Reader, pver uint32) error {
+	return checkVersion(sendHeaders.Command(), pver, protocol.SendHeadersVersion)
+}
@@ -0,0 +1,40 @@
+package msg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestSendHeaders(t *testing.T) {
+	t.Run("Encode", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		sendHeaders := &SendHeaders{}
+		err := sendHeaders.Encode(b, protocol.SendHeadersVersion)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if b.Len() != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("should NOT encode before BIP130", func(t *testing.T) {
+		sendHeaders := &SendHeaders{}
+		err := sendHeaders.Encode(bytes.NewBuffer([]byte{}), protocol.SendHeadersVersion-1)
+		if err == nil {
+			t.Error("SendHeaders should NOT be encoded before BIP130")
+		}
+	})
+
+	t.Run("should NOT decode before BIP130", func(t *testing.T) {
+		sendHeaders := &SendHeaders{}
+		err := sendHeaders.Decode(bytes.NewBuffer([]byte{}), protocol.SendHeadersVersion-1)
+		if err == nil {
+			t.Error("SendHeaders should NOT be decoded before BIP130")
+		}
+	})
+}
@@ -0,0 +1,552 @@
+package msg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// Witness serialization marker and flag, placed between version and inputs.
+// https://github.com/bitcoin/bips/blob/master/bip-0144.mediawiki
+const (
+	witnessMarker uint8 = 0x00
+	witnessFlag   uint8 = 0x01
+)
+
+// WitnessScaleFactor represents the weight of non witness data compared to witness data.
+// https://github.com/bitcoin/bips/blob/master/bip-0141.mediawiki
+const WitnessScaleFactor = 4
+
+// minTxInSize represents the minimum size of encoded TxIn: outpoint, empty script and sequence.
+const minTxInSize = 32 + 4 + 1 + 4
+
+// minTxOutSize represents the minimum size of encoded TxOut: value and empty script.
+const minTxOutSize = 8 + 1
+
+// maxTxInPerMsg represents the maximum number of inputs fitting into a msg.
+const maxTxInPerMsg = MaxPayloadSize / minTxInSize
+
+// maxTxOutPerMsg represents the maximum number of outputs fitting into a msg.
+const maxTxOutPerMsg = MaxPayloadSize / minTxOutSize
+
+// OutPoint represents a reference to a previous transaction output.
+type OutPoint struct {
+	// Hash represents the hash of the referenced transaction.
+	Hash protocol.Hash
+	// Index represents the index of the referenced output.
+	Index uint32
+}
+
+// TxIn represents transaction input.
+type TxIn struct {
+	// PreviousOutPoint represents the output being spent.
+	PreviousOutPoint OutPoint
+	// SignatureScript holds the script satisfying the spent output conditions.
+	SignatureScript []byte
+	// Witness holds the segregated witness stack of the input.
+	Witness [][]byte
+	// Sequence represents the input sequence number.
+	Sequence uint32
+}
+
+// TxOut represents transaction output.
+type TxOut struct {
+	// Value represents the number of satoshis of the output.
+	Value int64
+	// PkScript holds the script defining the conditions to spend the output.
+	PkScript []byte
+}
+
+// https://en.bitcoin.it/wiki/Protocol_documentation#tx
+type Tx struct {
+	// Version represents transaction data format version.
+	Version int32
+	// TxIn holds transaction inputs.
+	TxIn []*TxIn
+	// TxOut holds transaction outputs.
+	TxOut []*TxOut
+	// LockTime represents the block height or time when the transaction becomes final.
+	LockTime uint32
+}
+
+// Command returns tx command name.
+func (tx *Tx) Command() protocol.BitcoinCmdName {
+	return protocol.TxCmd
+}
+
+// HasWitness returns whether any of tx inputs carries witness data.
+func (tx *Tx) HasWitness() bool {
+	for _, txIn := range tx.TxIn {
+		if len(txIn.Witness) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TxHash returns the transaction id, computed as sha256(sha256(tx)) excluding witness data.
+func (tx *Tx) TxHash() protocol.Hash {
+	b := bytes.NewBuffer(make([]byte, 0, tx.SerializeSizeStripped()))
+
+	// Encoding into a buffer never fails
+	_ = tx.encode(b, false)
+
+	return protocol.DoubleHash(b.Bytes())
+}
+
+// WitnessHash returns the witness transaction id, computed as sha256(sha256(tx)) including witness data.
+// WitnessHash equals TxHash when tx has no witness data.
+func (tx *Tx) WitnessHash() protocol.Hash {
+	if !tx.HasWitness() {
+		return tx.TxHash()
+	}
+
+	b := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+
+	// Encoding into a buffer never fails
+	_ = tx.encode(b, true)
+
+	return protocol.DoubleHash(b.Bytes())
+}
+
+// SerializeSize returns the size of encoded tx in number of bytes, including witness data.
+func (tx *Tx) SerializeSize() int {
+	size := tx.SerializeSizeStripped()
+
+	if tx.HasWitness() {
+		// Marker and flag
+		size += 2
+
+		for _, txIn := range tx.TxIn {
+			size += varIntSize(uint(len(txIn.Witness)))
+			for _, item := range txIn.Witness {
+				size += varIntSize(uint(len(item))) + len(item)
+			}
+		}
+	}
+
+	return size
+}
+
+// SerializeSizeStripped returns the size of encoded tx in number of bytes, excluding witness data.
+func (tx *Tx) SerializeSizeStripped() int {
+	// Version and lock time
+	size := 8
+
+	size += varIntSize(uint(len(tx.TxIn)))
+	for _, txIn := range tx.TxIn {
+		size += minTxInSize - 1 + varIntSize(uint(len(txIn.SignatureScript))) + len(txIn.SignatureScript)
+	}
+
+	size += varIntSize(uint(len(tx.TxOut)))
+	for _, txOut := range tx.TxOut {
+		size += minTxOutSize - 1 + varIntSize(uint(len(txOut.PkScript))) + len(txOut.PkScript)
+	}
+
+	return size
+}
+
+// Weight returns tx weight, non witness data weights WitnessScaleFactor times witness data.
+// https://github.com/bitcoin/bips/blob/master/bip-0141.mediawiki#transaction-size-calculations
+func (tx *Tx) Weight() int {
+	return tx.SerializeSizeStripped()*(WitnessScaleFactor-1) + tx.SerializeSize()
+}
+
+// VSize returns tx virtual size, its weight divided by WitnessScaleFactor rounded up.
+func (tx *Tx) VSize() int {
+	return (tx.Weight() + WitnessScaleFactor - 1) / WitnessScaleFactor
+}
+
+// Encode encodes Tx into w.
+// Witness serialization is used when any input carries witness data.
+func (tx *Tx) Encode(w io.Writer, pver uint32) error {
+	return tx.encode(w, tx.HasWitness())
+}
+
+// encode encodes Tx into w, including witness data when witness is true.
+func (tx *Tx) encode(w io.Writer, witness bool) error {
+	version := uint32(tx.Version)
+	err := Encode(w, binary.LittleEndian, &version)
+	if err != nil {
+		return err
+	}
+
+	if witness {
+		marker, flag := witnessMarker, witnessFlag
+		err = EncodeBatch(w, EncodeVal{Order: binary.LittleEndian, Val: &marker}, EncodeVal{Order: binary.LittleEndian, Val: &flag})
+		if err != nil {
+			return err
+		}
+	}
+
+	count := VarInt{
+		Length: uint(len(tx.TxIn)),
+	}
+	err = count.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	for _, txIn := range tx.TxIn {
+		err = txIn.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	count.Length = uint(len(tx.TxOut))
+	err = count.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	for _, txOut := range tx.TxOut {
+		err = txOut.Encode(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	if witness {
+		for _, txIn := range tx.TxIn {
+			err = encodeWitness(w, txIn.Witness)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return Encode(w, binary.LittleEndian, &tx.LockTime)
+}
+
+// Decode decodes Tx from r, both with and without witness serialization.
+func (tx *Tx) Decode(r io.Reader, pver uint32) error {
+	var version uint32
+	err := Decode(r, binary.LittleEndian, &version)
+	if err != nil {
+		return err
+	}
+
+	tx.Version = int32(version)
+
+	count := VarInt{}
+	err = count.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	// Inputs count of zero is the witness marker
+	witness := false
+	if count.Length == uint(witnessMarker) {
+		var flag uint8
+		err = Decode(r, binary.LittleEndian, &flag)
+		if err != nil {
+			return err
+		}
+
+		if flag != witnessFlag {
+			return fmt.Errorf("Wrong witness flag (0x%x)", flag)
+		}
+
+		witness = true
+
+		err = count.Decode(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	if count.Length > maxTxInPerMsg {
+		return fmt.Errorf("Too many inputs (%d), max is (%d)", count.Length, maxTxInPerMsg)
+	}
+
+	err = checkRemaining(r, count.Length, minTxInSize)
+	if err != nil {
+		return err
+	}
+
+	// Slices grow as items are decoded, so a lying count cannot force a large allocation
+	tx.TxIn = []*TxIn{}
+	for i := uint(0); i < count.Length; i++ {
+		txIn := &TxIn{}
+		err = txIn.Decode(r)
+		if err != nil {
+			return err
+		}
+
+		tx.TxIn = append(tx.TxIn, txIn)
+	}
+
+	err = count.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	if count.Length > maxTxOutPerMsg {
+		return fmt.Errorf("Too many outputs (%d), max is (%d)", count.Length, maxTxOutPerMsg)
+	}
+
+	err = checkRemaining(r, count.Length, minTxOutSize)
+	if err != nil {
+		return err
+	}
+
+	tx.TxOut = []*TxOut{}
+	for i := uint(0); i < count.Length; i++ {
+		txOut := &TxOut{}
+		err = txOut.Decode(r)
+		if err != nil {
+			return err
+		}
+
+		tx.TxOut = append(tx.TxOut, txOut)
+	}
+
+	if witness {
+		for _, txIn := range tx.TxIn {
+			txIn.Witness, err = decodeWitness(r)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !tx.HasWitness() {
+			return fmt.Errorf("Witness serialization without witness data")
+		}
+	}
+
+	return Decode(r, binary.LittleEndian, &tx.LockTime)
+}
+
+// Encode encodes TxIn into w, witness data is not included.
+func (txIn *TxIn) Encode(w io.Writer) error {
+	vals := []EncodeVal{
+		{
+			Order: binary.LittleEndian,
+			Val:   &txIn.PreviousOutPoint.Hash,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &txIn.PreviousOutPoint.Index,
+		},
+	}
+
+	err := EncodeBatch(w, vals...)
+	if err != nil {
+		return err
+	}
+
+	err = encodeVarBytes(w, txIn.SignatureScript)
+	if err != nil {
+		return err
+	}
+
+	return Encode(w, binary.LittleEndian, &txIn.Sequence)
+}
+
+// Decode decodes TxIn from r, witness data is not included.
+func (txIn *TxIn) Decode(r io.Reader) error {
+	vals := []DecodeVal{
+		{
+			Order: binary.LittleEndian,
+			Val:   &txIn.PreviousOutPoint.Hash,
+		},
+		{
+			Order: binary.LittleEndian,
+			Val:   &txIn.PreviousOutPoint.Index,
+		},
+	}
+
+	err := DecodeBatch(r, vals...)
+	if err != nil {
+		return err
+	}
+
+	txIn.SignatureScript, err = decodeVarBytes(r)
+	if err != nil {
+		return err
+	}
+
+	return Decode(r, binary.LittleEndian, &txIn.Sequence)
+}
+
+// Encode encodes TxOut into w.
+func (txOut *TxOut) Encode(w io.Writer) error {
+	value := uint64(txOut.Value)
+	err := Encode(w, binary.LittleEndian, &value)
+	if err != nil {
+		return err
+	}
+
+	return encodeVarBytes(w, txOut.PkScript)
+}
+
+// Decode decodes TxOut from r.
+func (txOut *TxOut) Decode(r io.Reader) error {
+	var value uint64
+	err := Decode(r, binary.LittleEndian, &value)
+	if err != nil {
+		return err
+	}
+
+	txOut.Value = int64(value)
+
+	txOut.PkScript, err = decodeVarBytes(r)
+	return err
+}
+
+// encodeWitness encodes VarInt counted witness stack into w.
+func encodeWitness(w io.Writer, witness [][]byte) error {
+	count := VarInt{
+		Length: uint(len(witness)),
+	}
+	err := count.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range witness {
+		err = encodeVarBytes(w, item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeWitness decodes VarInt counted witness stack from r.
+func decodeWitness(r io.Reader) ([][]byte, error) {
+	count := VarInt{}
+	err := count.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if count.Length > MaxPayloadSize {
+		return nil, fmt.Errorf("Too many witness items (%d), max is (%d)", count.Length, MaxPayloadSize)
+	}
+
+	if count.Length == 0 {
+		return nil, nil
+	}
+
+	// Every item takes at least its length
+	err = checkRemaining(r, count.Length, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var witness [][]byte
+	for i := uint(0); i < count.Length; i++ {
+		item, err := decodeVarBytes(r)
+		if err != nil {
+			return nil, err
+		}
+
+		witness = append(witness, item)
+	}
+
+	return witness, nil
+}
+
+// encodeVarBytes encodes VarInt length prefixed b into w.
+func encodeVarBytes(w io.Writer, b []byte) error {
+	size := VarInt{
+		Length: uint(len(b)),
+	}
+	err := size.Encode(w)
+	if err != nil {
+		return err
+	}
+
+	val := append([]byte{}, b...)
+	return Encode(w, binary.LittleEndian, &val)
+}
+
+// decodeVarBytes decodes VarInt length prefixed bytes from r.
+func decodeVarBytes(r io.Reader) ([]byte, error) {
+	size := VarInt{}
+	err := size.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if size.Length > MaxPayloadSize {
+		return nil, fmt.Errorf("Too many bytes (%d), max is (%d)", size.Length, MaxPayloadSize)
+	}
+
+	return readBytes(r, size.Length)
+}
+
+// lenReader represents reader knowing the number of bytes left, like bytes.Reader.
+type lenReader interface {
+	Len() int
+}
+
+// checkRemaining returns an error when count items of at least minSize bytes do not fit into the bytes left in r.
+// Readers not knowing the bytes left are not checked.
+func checkRemaining(r io.Reader, count uint, minSize int) error {
+	lr, ok := r.(lenReader)
+	if !ok {
+		return nil
+	}
+
+	left := lr.Len()
+	if count > uint(left/minSize) {
+		return fmt.Errorf("Count (%d) does not fit into remaining bytes (%d)", count, left)
+	}
+
+	return nil
+}
+
+// readBytes reads size bytes from r.
+// The buffer is only allocated upfront when r holds enough bytes,
+// otherwise it grows as bytes are read, so a lying size cannot force a large allocation.
+func readBytes(r io.Reader, size uint) ([]byte, error) {
+	err := checkRemaining(r, size, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := r.(lenReader); ok {
+		b := make([]byte, size)
+		_, err = io.ReadFull(r, b)
+		if err != nil {
+			return nil, err
+		}
+
+		return b, nil
+	}
+
+	b := bytes.NewBuffer([]byte{})
+	_, err = io.CopyN(b, r, int64(size))
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// varIntSize returns the size of encoded VarInt of length in number of bytes.
+func varIntSize(length uint) int {
+	switch {
+	case length < 0xfd:
+		return 1
+	case length <= 0xffff:
+		return 3
+	case length <= 0xffffffff:
+		return 5
+	default:
+		return 9
+	}
+}
@@ -0,0 +1,257 @@
+package msg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// genesisCoinbaseHex holds the coinbase transaction of mainnet genesis block.
+const genesisCoinbaseHex = "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+// newSegwitTx returns transaction spending a P2WPKH output into another P2WPKH output.
+func newSegwitTx() *Tx {
+	return &Tx{
+		Version: 2,
+		TxIn: []*TxIn{
+			{
+				PreviousOutPoint: OutPoint{
+					Hash:  protocol.Hash{0xaa, 0xbb, 0xcc},
+					Index: 1,
+				},
+				SignatureScript: []byte{},
+				Witness: [][]byte{
+					bytes.Repeat([]byte{0x30}, 71),
+					append([]byte{0x02}, bytes.Repeat([]byte{0x11}, 32)...),
+				},
+				Sequence: 0xfffffffd,
+			},
+		},
+		TxOut: []*TxOut{
+			{
+				Value:    50000,
+				PkScript: append([]byte{0x00, 0x14}, bytes.Repeat([]byte{0x22}, 20)...),
+			},
+		},
+		LockTime: 0,
+	}
+}
+
+func TestTx(t *testing.T) {
+	data, _ := hex.DecodeString(genesisCoinbaseHex)
+
+	t.Run("Decode", func(t *testing.T) {
+		tx := &Tx{}
+		err := tx.Decode(bytes.NewBuffer(data), 70016)
+		if err != nil {
+			t.Fatalf("Unable to decode (%s)", err)
+		}
+
+		if len(tx.TxIn) != 1 || len(tx.TxOut) != 1 {
+			t.Fatalf("Wrong number of inputs (%d) or outputs (%d)", len(tx.TxIn), len(tx.TxOut))
+		}
+
+		if tx.TxOut[0].Value != 5000000000 {
+			t.Errorf("Wrong output value (%d)", tx.TxOut[0].Value)
+		}
+
+		if tx.TxIn[0].PreviousOutPoint.Index != 0xffffffff {
+			t.Errorf("Wrong coinbase outpoint index (0x%x)", tx.TxIn[0].PreviousOutPoint.Index)
+		}
+	})
+
+	t.Run("Encode", func(t *testing.T) {
+		tx := &Tx{}
+		err := tx.Decode(bytes.NewBuffer(data), 70016)
+		if err != nil {
+			t.Fatalf("Unable to decode (%s)", err)
+		}
+
+		b := bytes.NewBuffer([]byte{})
+		err = tx.Encode(b, 70016)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if bytes.Compare(b.Bytes(), data) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("TxHash", func(t *testing.T) {
+		tx := &Tx{}
+		tx.Decode(bytes.NewBuffer(data), 70016)
+
+		hash := tx.TxHash()
+		if hash.String() != "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b" {
+			t.Errorf("Wrong tx hash (%s)", hash)
+		}
+
+		if tx.WitnessHash() != hash {
+			t.Error("Witness hash of tx without witness should equal tx hash")
+		}
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		tx := &Tx{}
+		tx.Decode(bytes.NewBuffer(data), 70016)
+
+		if tx.SerializeSize() != 204 || tx.SerializeSizeStripped() != 204 {
+			t.Errorf("Wrong size (%d, %d)", tx.SerializeSize(), tx.SerializeSizeStripped())
+		}
+
+		if tx.Weight() != 816 {
+			t.Errorf("Wrong weight (%d)", tx.Weight())
+		}
+
+		if tx.VSize() != 204 {
+			t.Errorf("Wrong virtual size (%d)", tx.VSize())
+		}
+	})
+}
+
+// decodeAlloc decodes m from payload, returning the number of allocated bytes and the decoding error.
+func decodeAlloc(payload []byte, m Message) (uint64, error) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	err := m.Decode(bytes.NewReader(payload), 70016)
+
+	runtime.ReadMemStats(&after)
+
+	return after.TotalAlloc - before.TotalAlloc, err
+}
+
+// witnessTxPrefix returns witness tx encoding up to its witness data: single empty input and output.
+func witnessTxPrefix() []byte {
+	b := []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x01, 0x01}
+	b = append(b, make([]byte, 36)...)
+	b = append(b, 0x00, 0xff, 0xff, 0xff, 0xff, 0x01)
+	b = append(b, make([]byte, 8)...)
+
+	return append(b, 0x00)
+}
+
+func TestWitnessTx(t *testing.T) {
+	sample := newSegwitTx()
+
+	b := bytes.NewBuffer([]byte{})
+	err := sample.Encode(b, 70016)
+	if err != nil {
+		t.Fatalf("Unable to encode (%s)", err)
+	}
+
+	data := b.Bytes()
+
+	t.Run("should encode marker and flag", func(t *testing.T) {
+		if data[4] != 0x00 || data[5] != 0x01 {
+			t.Errorf("Wrong marker (0x%x) and flag (0x%x)", data[4], data[5])
+		}
+	})
+
+	t.Run("Decode", func(t *testing.T) {
+		tx := &Tx{}
+		err := tx.Decode(bytes.NewBuffer(data), 70016)
+		if err != nil {
+			t.Fatalf("Unable to decode (%s)", err)
+		}
+
+		if !reflect.DeepEqual(tx, sample) {
+			t.Error("Wrong decoding")
+		}
+	})
+
+	t.Run("TxHash", func(t *testing.T) {
+		stripped := newSegwitTx()
+		stripped.TxIn[0].Witness = nil
+
+		b := bytes.NewBuffer([]byte{})
+		stripped.Encode(b, 70016)
+
+		if sample.TxHash() != protocol.DoubleHash(b.Bytes()) {
+			t.Error("Tx hash should not commit to witness data")
+		}
+	})
+
+	t.Run("WitnessHash", func(t *testing.T) {
+		if sample.WitnessHash() != protocol.DoubleHash(data) {
+			t.Error("Witness hash should commit to witness data")
+		}
+
+		if sample.WitnessHash() == sample.TxHash() {
+			t.Error("Witness hash should differ from tx hash")
+		}
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		if sample.SerializeSize() != len(data) || sample.SerializeSize() != 191 {
+			t.Errorf("Wrong size (%d)", sample.SerializeSize())
+		}
+
+		if sample.SerializeSizeStripped() != 82 {
+			t.Errorf("Wrong stripped size (%d)", sample.SerializeSizeStripped())
+		}
+
+		if sample.Weight() != 437 {
+			t.Errorf("Wrong weight (%d)", sample.Weight())
+		}
+
+		if sample.VSize() != 110 {
+			t.Errorf("Wrong virtual size (%d)", sample.VSize())
+		}
+	})
+
+	t.Run("should NOT decode wrong witness flag", func(t *testing.T) {
+		corrupted := append([]byte{}, data...)
+		corrupted[5] = 0x02
+
+		tx := &Tx{}
+		err := tx.Decode(bytes.NewBuffer(corrupted), 70016)
+		if err == nil {
+			t.Error("Tx with wrong witness flag should NOT be decoded")
+		}
+	})
+
+	t.Run("should NOT allocate huge witness count", func(t *testing.T) {
+		payload := append(witnessTxPrefix(), 0xfe, 0x00, 0x00, 0x00, 0x01)
+
+		alloc, err := decodeAlloc(payload, &Tx{})
+		if err == nil {
+			t.Error("Tx claiming huge witness count should NOT be decoded")
+		}
+
+		if alloc > 1<<20 {
+			t.Errorf("Too many bytes allocated (%d)", alloc)
+		}
+	})
+
+	t.Run("should NOT allocate huge witness item", func(t *testing.T) {
+		payload := append(witnessTxPrefix(), 0x01, 0xfe, 0x00, 0x00, 0x00, 0x01)
+
+		alloc, err := decodeAlloc(payload, &Tx{})
+		if err == nil {
+			t.Error("Tx claiming huge witness item should NOT be decoded")
+		}
+
+		if alloc > 1<<20 {
+			t.Errorf("Too many bytes allocated (%d)", alloc)
+		}
+	})
+
+	t.Run("should NOT allocate huge input count", func(t *testing.T) {
+		payload := []byte{0x01, 0x00, 0x00, 0x00, 0xfe, 0x00, 0x00, 0x08, 0x00}
+
+		alloc, err := decodeAlloc(payload, &Tx{})
+		if err == nil {
+			t.Error("Tx claiming huge input count should NOT be decoded")
+		}
+
+		if alloc > 1<<20 {
+			t.Errorf("Too many bytes allocated (%d)", alloc)
+		}
+	})
+}
@@ -71,44 +71,33 @@ func (vi *VarInt) Decode(r io.Reader) error {
 	return fmt.Errorf("Wrong var int")
 }
 
-// Encode  encodes VarInt into w.
+// Encode encodes VarInt into w.
 func (vi *VarInt) Encode(w io.Writer) error {
 	len := vi.Length
 
 	switch {
-	case len <= math.MaxUint8:
+	case len < 0xfd:
 		{
 			b := uint8(len)
-			err := Encode(w, binary.LittleEndian, &b)
-			if err != nil {
-				return err
-			}
+			return Encode(w, binary.LittleEndian, &b)
 		}
 	case len <= math.MaxUint16:
 		{
+			prefix := uint8(0xfd)
 			b := uint16(len)
-			err := Encode(w, binary.LittleEndian, &b)
-			if err != nil {
-				return err
-			}
+			return EncodeBatch(w, EncodeVal{Order: binary.LittleEndian, Val: &prefix}, EncodeVal{Order: binary.LittleEndian, Val: &b})
 		}
 	case len <= math.MaxUint32:
 		{
+			prefix := uint8(0xfe)
 			b := uint32(len)
-			err := Encode(w, binary.LittleEndian, &b)
-			if err != nil {
-				return err
-			}
+			return EncodeBatch(w, EncodeVal{Order: binary.LittleEndian, Val: &prefix}, EncodeVal{Order: binary.LittleEndian, Val: &b})
 		}
 	default:
 		{
+			prefix := uint8(0xff)
 			b := uint64(len)
-			err := Encode(w, binary.LittleEndian, &b)
-			if err != nil {
-				return err
-			}
+			return EncodeBatch(w, EncodeVal{Order: binary.LittleEndian, Val: &prefix}, EncodeVal{Order: binary.LittleEndian, Val: &b})
 		}
 	}
-
-	return nil
 }
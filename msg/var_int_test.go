@@ -79,7 +79,7 @@ func TestVarInEncode(t *testing.T) {
 			t.Errorf("Unable to encode uint8 (%s)", err.Error())
 		}
 
-		if b.Len() != 1 {
+		if bytes.Compare(b.Bytes(), []byte{0xaa}) != 0 {
 			t.Errorf("Wrong uint8 encoding")
 		}
 	})
@@ -95,7 +95,7 @@ func TestVarInEncode(t *testing.T) {
 			t.Errorf("Unable to encode uint16 (%s)", err.Error())
 		}
 
-		if b.Len() != 2 {
+		if bytes.Compare(b.Bytes(), []byte{0xfd, 0x12, 0x1f}) != 0 {
 			t.Errorf("Wrong uint16 encoding")
 		}
 	})
@@ -111,7 +111,7 @@ func TestVarInEncode(t *testing.T) {
 			t.Errorf("Unable to encode uint32 (%s)", err.Error())
 		}
 
-		if b.Len() != 4 {
+		if bytes.Compare(b.Bytes(), []byte{0xfe, 0x12, 0x1f, 0x23, 0x22}) != 0 {
 			t.Errorf("Wrong uint32 encoding")
 		}
 	})
@@ -127,8 +127,45 @@ func TestVarInEncode(t *testing.T) {
 			t.Errorf("Unable to encode uint64 (%s)", err.Error())
 		}
 
-		if b.Len() != 8 {
+		if bytes.Compare(b.Bytes(), []byte{0xff, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x11}) != 0 {
 			t.Errorf("Wrong uint64 encoding")
 		}
 	})
 }
+
+func TestVarIntBoundaries(t *testing.T) {
+	lengths := map[uint]int{
+		0xfc:        1,
+		0xfd:        3,
+		0xffff:      3,
+		0x10000:     5,
+		0xffffffff:  5,
+		0x100000000: 9,
+	}
+
+	for length, size := range lengths {
+		b := bytes.NewBuffer([]byte{})
+
+		varInt := &VarInt{
+			Length: length,
+		}
+		err := varInt.Encode(b)
+		if err != nil {
+			t.Errorf("Unable to encode (0x%x) (%s)", length, err.Error())
+		}
+
+		if b.Len() != size {
+			t.Errorf("Wrong encoding size of (0x%x): actual (%d), expected (%d)", length, b.Len(), size)
+		}
+
+		decoded := &VarInt{}
+		err = decoded.Decode(b)
+		if err != nil {
+			t.Errorf("Unable to decode (0x%x) (%s)", length, err.Error())
+		}
+
+		if decoded.Length != length {
+			t.Errorf("Wrong decoding: actual (0x%x), expected (0x%x)", decoded.Length, length)
+		}
+	}
+}
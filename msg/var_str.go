@@ -2,6 +2,7 @@ package msg
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
@@ -22,9 +23,11 @@ func (varStr *VarStr) Decode(r io.Reader) error {
 		return err
 	}
 
-	// Allocate space for str using Len
-	val := make([]byte, varStr.VarInt.Length)
-	err = Decode(r, binary.LittleEndian, &val)
+	if varStr.VarInt.Length > MaxPayloadSize {
+		return fmt.Errorf("String too long (%d), max is (%d)", varStr.VarInt.Length, MaxPayloadSize)
+	}
+
+	val, err := readBytes(r, varStr.VarInt.Length)
 	if err != nil {
 		return err
 	}
@@ -46,4 +46,12 @@ func TestStr(t *testing.T) {
 			t.Errorf("Wrong encoding")
 		}
 	})
+
+	t.Run("should NOT decode length beyond remaining bytes", func(t *testing.T) {
+		str := &VarStr{}
+		err := str.Decode(bytes.NewBuffer([]byte{0xfe, 0x00, 0x00, 0x00, 0x01, 0x2f}))
+		if err == nil {
+			t.Error("String longer than remaining bytes should NOT be decoded")
+		}
+	})
 }
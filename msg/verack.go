@@ -1,34 +1,27 @@
 package msg
 
 import (
-	"fmt"
 	"io"
+
+	"github.com/elmarsan/havel/protocol"
 )
 
 // https://en.bitcoin.it/wiki/Protocol_documentation#verack
-type Verack struct {
-	// Header represents msg header.
-	Header *Header
+type Verack struct{}
+
+// Command returns verack command name.
+func (verack *Verack) Command() protocol.BitcoinCmdName {
+	return protocol.VerackCmd
 }
 
 // Encode encodes Verack into w.
-func (verack *Verack) Encode(w io.Writer) error {
-	err := verack.Header.Encode(w)
-	if err != nil {
-		return fmt.Errorf("Could not encode Headers, cause %s", err.Error())
-	}
-
+// Verack has no payload.
+func (verack *Verack) Encode(w io.Writer, pver uint32) error {
 	return nil
 }
 
 // Decode decodes Verack from r.
-func (verack *Verack) Decode(r io.Reader) error {
-	// Decode headers
-	verack.Header = &Header{}
-	err := verack.Header.Decode(r)
-	if err != nil {
-		return fmt.Errorf("Could not decode Headers, cause %s", err.Error())
-	}
-
+// Verack has no payload.
+func (verack *Verack) Decode(r io.Reader, pver uint32) error {
 	return nil
 }
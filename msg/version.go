@@ -2,19 +2,18 @@ package msg
 
 import (
 	"encoding/binary"
-	"fmt"
 	"io"
 	"time"
+
+	"github.com/elmarsan/havel/protocol"
 )
 
 // https://en.bitcoin.it/wiki/Protocol_documentation#version
 type Version struct {
-	// Header represents msg header.
-	Header *Header
 	// Version represents the protocol version used by the node.
 	Version uint32
 	// Services represents bitfield of features to be enabled for this connection.
-	Services uint64
+	Services protocol.ServiceFlag
 	// Timestamp represents standard UNIX timestamp.
 	Timestamp time.Time
 	// RecvAddr represents receiver network address.
@@ -33,15 +32,17 @@ type Version struct {
 	Relay bool
 }
 
-// Encode encodes Version into w.
-func (version *Version) Encode(w io.Writer) error {
-	err := version.Header.Encode(w)
-	if err != nil {
-		return fmt.Errorf("Unable to encode header, (%s)", err.Error())
-	}
+// Command returns version command name.
+func (version *Version) Command() protocol.BitcoinCmdName {
+	return protocol.VersionCmd
+}
 
+// Encode encodes Version into w.
+// Relay is only encoded from protocol version BIP0037Version.
+func (version *Version) Encode(w io.Writer, pver uint32) error {
 	// Encode Version, Services and Timestamp
 	var unix uint64 = uint64(version.Timestamp.Unix())
+	services := uint64(version.Services)
 	vals := []EncodeVal{
 		{
 			Order: binary.LittleEndian,
@@ -49,7 +50,7 @@ func (version *Version) Encode(w io.Writer) error {
 		},
 		{
 			Order: binary.LittleEndian,
-			Val:   &version.Services,
+			Val:   &services,
 		},
 		{
 			Order: binary.LittleEndian,
@@ -57,7 +58,7 @@ func (version *Version) Encode(w io.Writer) error {
 		},
 	}
 
-	err = EncodeBatch(w, vals...)
+	err := EncodeBatch(w, vals...)
 	if err != nil {
 		return err
 	}
@@ -92,7 +93,11 @@ func (version *Version) Encode(w io.Writer) error {
 		return err
 	}
 
-	// Encode relay
+	// Encode relay, only known from BIP37
+	if pver < protocol.BIP0037Version {
+		return nil
+	}
+
 	var relay uint8
 
 	if version.Relay {
@@ -110,15 +115,9 @@ func (version *Version) Encode(w io.Writer) error {
 }
 
 // Decode decodes Version from r.
-func (version *Version) Decode(r io.Reader) error {
-	// Decode headers
-	version.Header = &Header{}
-	err := version.Header.Decode(r)
-	if err != nil {
-		return fmt.Errorf("Unable to decode header, (%s)", err.Error())
-	}
-
-	var unix uint64
+// Relay is optional, being true when missing.
+func (version *Version) Decode(r io.Reader, pver uint32) error {
+	var unix, services uint64
 
 	// Decode version, services and timestamp
 	vals := []DecodeVal{
@@ -128,7 +127,7 @@ func (version *Version) Decode(r io.Reader) error {
 		},
 		{
 			Order: binary.LittleEndian,
-			Val:   &version.Services,
+			Val:   &services,
 		},
 		{
 			Order: binary.LittleEndian,
@@ -136,11 +135,12 @@ func (version *Version) Decode(r io.Reader) error {
 		},
 	}
 
-	err = DecodeBatch(r, vals...)
+	err := DecodeBatch(r, vals...)
 	if err != nil {
 		return err
 	}
 
+	version.Services = protocol.ServiceFlag(services)
 	version.Timestamp = time.Unix(int64(unix), 0)
 
 	// Decode RecvAddr
@@ -182,9 +182,14 @@ func (version *Version) Decode(r io.Reader) error {
 		return err
 	}
 
-	// Decode relay
+	// Decode relay, peers not sending it expect transactions to be relayed
 	var relay uint8
 	err = Decode(r, binary.LittleEndian, &relay)
+	if err == io.EOF {
+		version.Relay = true
+		return nil
+	}
+
 	if err != nil {
 		return err
 	}
@@ -12,18 +12,6 @@ import (
 
 func TestVersion(t *testing.T) {
 	data := []byte{
-		// Header
-		// Magic
-		0xf9, 0xbe, 0xb4, 0xd9,
-		// Command
-		0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x00,
-		0x00, 0x00, 0x00, 0x00,
-		// Length
-		0x64, 0x00, 0x00, 0x00,
-		// Checksum
-		0x35, 0x8d, 0x49, 0x32,
-
-		// body
 		// Version
 		0x62, 0xea, 0x00, 0x00,
 		// Services
@@ -64,15 +52,6 @@ func TestVersion(t *testing.T) {
 	}
 
 	sample := &Version{
-		Header: &Header{
-			Magic: protocol.MainNet,
-			Cmd: protocol.BitcoinCmd{
-				HexData: protocol.VersionCmdData,
-				Name:    protocol.VersionCmd,
-			},
-			Length:   0x64,
-			Checksum: 0x32498d35,
-		},
 		Version:   0xea62,
 		Services:  0x00000001,
 		Timestamp: time.Unix(1355854353, 0),
@@ -107,7 +86,7 @@ func TestVersion(t *testing.T) {
 		b := bytes.NewBuffer(data)
 
 		version := &Version{}
-		err := version.Decode(b)
+		err := version.Decode(b, 0xea62)
 		if err != nil {
 			t.Errorf("Unable to decode (%s)", err.Error())
 		}
@@ -120,7 +99,7 @@ func TestVersion(t *testing.T) {
 	t.Run("Encode", func(t *testing.T) {
 		b := bytes.NewBuffer([]byte{})
 
-		err := sample.Encode(b)
+		err := sample.Encode(b, protocol.BIP0037Version)
 		if err != nil {
 			t.Errorf("Unable to encode (%s)", err.Error())
 		}
@@ -129,4 +108,31 @@ func TestVersion(t *testing.T) {
 			t.Error("Wrong encoding")
 		}
 	})
+
+	t.Run("should NOT encode relay before BIP37", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		err := sample.Encode(b, 0xea62)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err.Error())
+		}
+
+		if bytes.Compare(b.Bytes(), data[:len(data)-1]) != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("should decode missing relay as true", func(t *testing.T) {
+		b := bytes.NewBuffer(data[:len(data)-1])
+
+		version := &Version{}
+		err := version.Decode(b, protocol.ProtocolVersion)
+		if err != nil {
+			t.Errorf("Unable to decode (%s)", err.Error())
+		}
+
+		if !version.Relay || version.StartHeight != sample.StartHeight {
+			t.Error("Wrong decoding")
+		}
+	})
 }
@@ -0,0 +1,28 @@
+package msg
+
+import (
+	"io"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+// WTxIDRelay signals preference for announcing transactions by witness hash, it is sent before verack.
+// https://github.com/bitcoin/bips/blob/master/bip-0339.mediawiki
+type WTxIDRelay struct{}
+
+// Command returns wtxidrelay command name.
+func (wtxidRelay *WTxIDRelay) Command() protocol.BitcoinCmdName {
+	return protocol.WtxidRelayCmd
+}
+
+// Encode encodes WTxIDRelay into w.
+// WTxIDRelay has no payload, it requires protocol version WTxIDRelayVersion.
+func (wtxidRelay *WTxIDRelay) Encode(w io.Writer, pver uint32) error {
+	return checkVersion(wtxidRelay.Command(), pver, protocol.WTxIDRelayVersion)
+}
+
+// Decode decodes WTxIDRelay from r.
+// WTxIDRelay has no payload, it requires protocol version WTxIDRelayVersion.
+func (wtxidRelay *WTxIDRelay) Decode(r io.Reader, pver uint32) error {
+	return checkVersion(wtxidRelay.Command(), pver, protocol.WTxIDRelayVersion)
+}
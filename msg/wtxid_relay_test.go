@@ -0,0 +1,32 @@
+package msg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestWTxIDRelay(t *testing.T) {
+	t.Run("Encode", func(t *testing.T) {
+		b := bytes.NewBuffer([]byte{})
+
+		wtxidRelay := &WTxIDRelay{}
+		err := wtxidRelay.Encode(b, protocol.WTxIDRelayVersion)
+		if err != nil {
+			t.Errorf("Unable to encode (%s)", err)
+		}
+
+		if b.Len() != 0 {
+			t.Error("Wrong encoding")
+		}
+	})
+
+	t.Run("should NOT encode before BIP339", func(t *testing.T) {
+		wtxidRelay := &WTxIDRelay{}
+		err := wtxidRelay.Encode(bytes.NewBuffer([]byte{}), protocol.WTxIDRelayVersion-1)
+		if err == nil {
+			t.Error("WTxIDRelay should NOT be encoded before BIP339")
+		}
+	})
+}
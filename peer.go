@@ -0,0 +1,209 @@
+package main
+
+import (
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// Peer represents Bitcoin network node.
+type Peer struct {
+	// conn holds the connection to the peer.
+	conn net.Conn
+	// inbound indicates whether the connection was initiated by the peer.
+	inbound bool
+	// outbound queues msgs waiting to be written into conn.
+	outbound chan msg.Message
+	// addr represents the network address of the peer.
+	addr *msg.NetAddr
+	// version represents the negotiated protocol version.
+	version uint32
+	// services represents the services announced by the peer.
+	services protocol.ServiceFlag
+	// userAgent represents the user agent announced by the peer.
+	userAgent string
+	// startHeight represents the last block known by the peer at connection time.
+	startHeight uint32
+	// addrV2 indicates whether the peer prefers addrv2 msgs over addr msgs.
+	addrV2 bool
+	// unconnectingHeaders represents the number of consecutive headers msgs not connecting to the header tree,
+	// it is only accessed by the goroutine reading peer msgs.
+	unconnectingHeaders int
+	// quit is closed once peer is disconnected.
+	quit chan struct{}
+
+	// pingMtx protects pingNonce, pingSent, lastPing and minPing.
+	pingMtx sync.Mutex
+	// pingNonce represents the nonce of the ping awaiting pong.
+	pingNonce uint64
+	// pingSent represents when the ping awaiting pong was sent, zero if no pong is awaited.
+	pingSent time.Time
+	// lastPing represents the round-trip time of the last answered ping.
+	lastPing time.Duration
+	// minPing represents the minimum round-trip time of answered pings.
+	minPing time.Duration
+}
+
+// LastPing returns the round-trip time of the last ping answered by peer, zero if none was answered.
+func (peer *Peer) LastPing() time.Duration {
+	peer.pingMtx.Lock()
+	defer peer.pingMtx.Unlock()
+
+	return peer.lastPing
+}
+
+// MinPing returns the minimum round-trip time of pings answered by peer, zero if none was answered.
+func (peer *Peer) MinPing() time.Duration {
+	peer.pingMtx.Lock()
+	defer peer.pingMtx.Unlock()
+
+	return peer.minPing
+}
+
+// startPeer saves peer as connected, running its read, write and ping goroutines.
+func (c *Client) startPeer(peer *Peer) error {
+	return c.peerManager().add(peer,
+		func() { c.readPeer(peer) },
+		func() { c.writePeer(peer) },
+		func() { c.pingPeer(peer) },
+	)
+}
+
+// SendTo queues m for being written into peer connection.
+// Peer is disconnected when its outbound queue is full.
+func (c *Client) SendTo(peer *Peer, m msg.Message) error {
+	select {
+	case <-peer.quit:
+		return ErrPeerDisconnected
+	default:
+	}
+
+	select {
+	case peer.outbound <- m:
+		return nil
+	default:
+		log.Printf("Disconnecting peer %s (outbound queue full)", peer.conn.RemoteAddr())
+		c.RemovePeer(peer)
+		return ErrPeerDisconnected
+	}
+}
+
+// Broadcast queues m for being written into every connected peer connection.
+func (c *Client) Broadcast(m msg.Message) {
+	for _, peer := range c.Peers() {
+		c.SendTo(peer, m)
+	}
+}
+
+// readPeer reads and handles peer msgs until its connection fails,
+// then peer is removed.
+func (c *Client) readPeer(peer *Peer) {
+	defer c.RemovePeer(peer)
+
+	for {
+		m, err := msg.ReadMessage(peer.conn, peer.version, c.net)
+		if err != nil {
+			log.Printf("Disconnecting peer %s (%s)", peer.conn.RemoteAddr(), err)
+			return
+		}
+
+		err = c.handleMessage(peer, m)
+		if err != nil {
+			log.Printf("Disconnecting peer %s (%s)", peer.conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// writePeer writes queued msgs into peer connection until peer is disconnected.
+// Peer is removed when writing fails.
+func (c *Client) writePeer(peer *Peer) {
+	for {
+		select {
+		case <-peer.quit:
+			return
+		case m := <-peer.outbound:
+			err := msg.WriteMessage(peer.conn, m, peer.version, c.net)
+			if err != nil {
+				log.Printf("Disconnecting peer %s (%s)", peer.conn.RemoteAddr(), err)
+				c.RemovePeer(peer)
+				return
+			}
+		}
+	}
+}
+
+// handleMessage handles m received from peer.
+func (c *Client) handleMessage(peer *Peer, m msg.Message) error {
+	switch m := m.(type) {
+	case *msg.GetAddr:
+		return c.sendAddrs(peer, c.knownAddrs())
+	case *msg.Addr:
+		src, err := msg.NewNetAddrV2(peer.addr)
+		if err != nil {
+			return err
+		}
+
+		addrs := make([]*msg.NetAddrV2, 0, len(m.AddrList))
+		for _, addr := range m.AddrList {
+			// Invalid addresses are skipped
+			addrV2, err := msg.NewNetAddrV2(addr)
+			if err == nil {
+				addrs = append(addrs, addrV2)
+			}
+		}
+
+		c.addrManager().Add(addrs, src)
+	case *msg.AddrV2:
+		src, err := msg.NewNetAddrV2(peer.addr)
+		if err != nil {
+			return err
+		}
+
+		c.addrManager().Add(m.AddrList, src)
+	case *msg.Inv:
+		return c.handleInv(peer, m)
+	case *msg.GetData:
+		return c.handleGetData(peer, m)
+	case *msg.NotFound:
+		c.handleNotFound(peer, m)
+	case *msg.Block:
+		return c.handleBlock(peer, m)
+	case *msg.GetHeaders:
+		return c.handleGetHeaders(peer, m)
+	case *msg.Headers:
+		return c.handleHeaders(peer, m)
+	case *msg.Ping:
+		return c.SendTo(peer, &msg.Pong{Nonce: m.Nonce})
+	case *msg.Pong:
+		peer.handlePong(m)
+	case *msg.Reject:
+		c.handleReject(peer, m)
+	}
+
+	return nil
+}
+
+// sendAddrs sends addrs to peer, using addrv2 msg when supported by the peer.
+// Otherwise, addresses which cannot be represented in addr msg are left out.
+func (c *Client) sendAddrs(peer *Peer, addrs []*msg.NetAddrV2) error {
+	if peer.addrV2 {
+		return c.SendTo(peer, &msg.AddrV2{AddrList: addrs})
+	}
+
+	addrList := make([]*msg.NetAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		netAddr, err := addr.ToNetAddr()
+		if err != nil {
+			continue
+		}
+
+		addrList = append(addrList, netAddr)
+	}
+
+	return c.SendTo(peer, &msg.Addr{AddrList: addrList})
+}
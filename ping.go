@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// pingPeer pings peer every ping interval until it is disconnected.
+// Peer is disconnected when a ping is not answered within ping timeout.
+// Peers not supporting pong msg are pinged only for keeping connection alive.
+func (c *Client) pingPeer(peer *Peer) {
+	interval := c.pingInterval
+	if interval == 0 {
+		interval = defaultPingInterval
+	}
+
+	timeout := c.pingTimeout
+	if timeout == 0 {
+		timeout = defaultPingTimeout
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-peer.quit:
+			return
+		case <-ticker.C:
+		}
+
+		if peer.pingExpired(timeout) {
+			log.Printf("Disconnecting peer %s (ping timeout)", peer.conn.RemoteAddr())
+			c.RemovePeer(peer)
+			return
+		}
+
+		if peer.pingAwaited() {
+			continue
+		}
+
+		err := c.ping(peer)
+		if err != nil {
+			log.Printf("Unable to ping peer %s (%s)", peer.conn.RemoteAddr(), err)
+			c.RemovePeer(peer)
+			return
+		}
+	}
+}
+
+// ping sends ping msg to peer, saving its nonce for awaiting pong.
+func (c *Client) ping(peer *Peer) error {
+	if peer.version <= protocol.BIP0031Version {
+		return c.SendTo(peer, &msg.Ping{})
+	}
+
+	nonce, err := randUint64()
+	if err != nil {
+		return err
+	}
+
+	peer.pingMtx.Lock()
+	peer.pingNonce = nonce
+	peer.pingSent = time.Now()
+	peer.pingMtx.Unlock()
+
+	return c.SendTo(peer, &msg.Ping{Nonce: nonce})
+}
+
+// handlePong updates peer ping times when pong answers the awaited ping.
+// Pongs with unexpected nonce are ignored.
+func (peer *Peer) handlePong(pong *msg.Pong) {
+	peer.pingMtx.Lock()
+	defer peer.pingMtx.Unlock()
+
+	if peer.pingSent.IsZero() || pong.Nonce != peer.pingNonce {
+		return
+	}
+
+	peer.lastPing = time.Since(peer.pingSent)
+	if peer.minPing == 0 || peer.lastPing < peer.minPing {
+		peer.minPing = peer.lastPing
+	}
+
+	peer.pingSent = time.Time{}
+}
+
+// pingAwaited returns whether peer has not answered the last ping yet.
+func (peer *Peer) pingAwaited() bool {
+	peer.pingMtx.Lock()
+	defer peer.pingMtx.Unlock()
+
+	return !peer.pingSent.IsZero()
+}
+
+// pingExpired returns whether peer has not answered the last ping within timeout.
+func (peer *Peer) pingExpired(timeout time.Duration) bool {
+	peer.pingMtx.Lock()
+	defer peer.pingMtx.Unlock()
+
+	return !peer.pingSent.IsZero() && time.Since(peer.pingSent) > timeout
+}
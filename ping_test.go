@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestPing(t *testing.T) {
+	t.Run("should answer ping with pong", func(t *testing.T) {
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		replies := make(chan msg.Message, 1)
+
+		connectRemote(t, client, func(remote *Client, peer *Peer) {
+			err := write(remote, peer, &msg.Ping{Nonce: 0x0123456789abcdef})
+			if err != nil {
+				return
+			}
+
+			m, err := msg.ReadMessage(peer.conn, peer.version, remote.net)
+			if err != nil {
+				return
+			}
+
+			replies <- m
+		})
+
+		select {
+		case m := <-replies:
+			pong, ok := m.(*msg.Pong)
+			if !ok {
+				t.Fatal("Ping should be answered with pong")
+			}
+
+			if pong.Nonce != 0x0123456789abcdef {
+				t.Errorf("Wrong nonce (0x%x)", pong.Nonce)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Ping was not answered")
+		}
+	})
+
+	t.Run("should measure ping time", func(t *testing.T) {
+		client := &Client{
+			version:      protocol.AddrV2Version,
+			net:          protocol.MainNet,
+			pingInterval: 10 * time.Millisecond,
+			pingTimeout:  5 * time.Second,
+		}
+
+		connectRemote(t, client, func(remote *Client, peer *Peer) {
+			for {
+				m, err := msg.ReadMessage(peer.conn, peer.version, remote.net)
+				if err != nil {
+					return
+				}
+
+				if ping, ok := m.(*msg.Ping); ok {
+					err = write(remote, peer, &msg.Pong{Nonce: ping.Nonce})
+					if err != nil {
+						return
+					}
+				}
+			}
+		})
+
+		peer := client.Peers()[0]
+
+		deadline := time.Now().Add(5 * time.Second)
+		for peer.LastPing() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("Ping was not measured")
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if peer.MinPing() == 0 || peer.MinPing() > peer.LastPing() {
+			t.Errorf("Wrong min ping (%s), last ping (%s)", peer.MinPing(), peer.LastPing())
+		}
+	})
+
+	t.Run("should disconnect peer missing pong", func(t *testing.T) {
+		client := &Client{
+			version:      protocol.AddrV2Version,
+			net:          protocol.MainNet,
+			pingInterval: 10 * time.Millisecond,
+			pingTimeout:  50 * time.Millisecond,
+		}
+
+		disconnected := make(chan struct{})
+
+		connectRemote(t, client, func(remote *Client, peer *Peer) {
+			defer close(disconnected)
+
+			// Ignore pings until disconnected
+			for {
+				_, err := msg.ReadMessage(peer.conn, peer.version, remote.net)
+				if err != nil {
+					return
+				}
+			}
+		})
+
+		select {
+		case <-disconnected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Peer was not disconnected")
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package protocol
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strconv"
 )
@@ -42,3 +44,20 @@ func (h *Hash) Reverse() *Hash {
 
 	return (*Hash)(reversedHash)
 }
+
+// DoubleHash returns Hash computed as sha256(sha256(b)).
+func DoubleHash(b []byte) Hash {
+	first := sha256.Sum256(b)
+	return Hash(sha256.Sum256(first[:]))
+}
+
+// String returns hex representation of Hash in byte-wise reverse order,
+// the way block and transaction hashes are usually displayed.
+func (h Hash) String() string {
+	b := make([]byte, HashSize)
+	for i := range h {
+		b[HashSize-1-i] = h[i]
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -51,4 +51,24 @@ func TestHash(t *testing.T) {
 			t.Error("Wrong reverse")
 		}
 	})
+	t.Run("DoubleHash", func(t *testing.T) {
+		// sha256(sha256("hello"))
+		expected := "9595c9df90075148eb06860365df33584b75bff782a510c6cd4883a419833d50"
+
+		h := DoubleHash([]byte("hello"))
+
+		expectedHash, _ := NewHashFromString(expected)
+		if *expectedHash != h {
+			t.Errorf("Wrong double hash (%x)", h)
+		}
+	})
+	t.Run("String", func(t *testing.T) {
+		h := Hash{}
+		copy(h[:], sample)
+
+		expected := "6fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000"
+		if h.String() != expected {
+			t.Errorf("Wrong string (%s)", h.String())
+		}
+	})
 }
@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"math/big"
+)
+
+// CompactToBig returns the target represented by compact, the nBits field of block headers.
+// Compact is a floating point number where the highest byte is the exponent in number of bytes,
+// the 24th bit the sign and the lower 23 bits the mantissa.
+// https://en.bitcoin.it/wiki/Difficulty#How_is_difficulty_stored_in_blocks.3F
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	negative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	var n *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		n = big.NewInt(int64(mantissa))
+	} else {
+		n = big.NewInt(int64(mantissa))
+		n.Lsh(n, 8*(exponent-3))
+	}
+
+	if negative {
+		n.Neg(n)
+	}
+
+	return n
+}
+
+// BigToCompact returns the compact representation of n.
+// Precision beyond the 3 most significant bytes is lost.
+func BigToCompact(n *big.Int) uint32 {
+	if n.Sign() == 0 {
+		return 0
+	}
+
+	abs := new(big.Int).Abs(n)
+	exponent := uint(len(abs.Bytes()))
+
+	var mantissa uint32
+	if exponent <= 3 {
+		mantissa = uint32(abs.Uint64()) << (8 * (3 - exponent))
+	} else {
+		mantissa = uint32(abs.Rsh(abs, 8*(exponent-3)).Uint64())
+	}
+
+	// Sign bit is set, move mantissa one byte
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+
+	compact := uint32(exponent<<24) | mantissa
+	if n.Sign() < 0 {
+		compact |= 0x00800000
+	}
+
+	return compact
+}
+
+// HashToBig returns hash as number, hashes are stored in little endian order.
+func HashToBig(hash *Hash) *big.Int {
+	b := make([]byte, HashSize)
+	for i := range hash {
+		b[HashSize-1-i] = hash[i]
+	}
+
+	return new(big.Int).SetBytes(b)
+}
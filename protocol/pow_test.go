@@ -0,0 +1,65 @@
+package protocol
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	bigFromHex := func(s string) *big.Int {
+		n, _ := new(big.Int).SetString(s, 16)
+		return n
+	}
+
+	t.Run("CompactToBig", func(t *testing.T) {
+		samples := map[uint32]*big.Int{
+			0x00000000: big.NewInt(0),
+			0x00123456: big.NewInt(0),
+			0x01003456: big.NewInt(0),
+			0x04000000: big.NewInt(0),
+			0x01123456: big.NewInt(0x12),
+			0x01fedcba: big.NewInt(-0x7e),
+			0x02123456: big.NewInt(0x1234),
+			0x04923456: big.NewInt(-0x12345600),
+			0x05009234: big.NewInt(0x92340000),
+			0x1d00ffff: bigFromHex("00000000ffff0000000000000000000000000000000000000000000000000000"),
+			0x1b0404cb: bigFromHex("00000000000404cb000000000000000000000000000000000000000000000000"),
+		}
+
+		for compact, expected := range samples {
+			n := CompactToBig(compact)
+			if n.Cmp(expected) != 0 {
+				t.Errorf("Wrong conversion of (0x%08x): actual (%x), expected (%x)", compact, n, expected)
+			}
+		}
+	})
+
+	t.Run("BigToCompact", func(t *testing.T) {
+		samples := map[uint32]*big.Int{
+			0x00000000: big.NewInt(0),
+			0x01120000: big.NewInt(0x12),
+			0x01fe0000: big.NewInt(-0x7e),
+			0x02123400: big.NewInt(0x1234),
+			0x04923456: big.NewInt(-0x12345600),
+			0x05009234: big.NewInt(0x92340000),
+			0x1d00ffff: bigFromHex("00000000ffff0000000000000000000000000000000000000000000000000000"),
+			0x1b0404cb: bigFromHex("00000000000404cb000000000000000000000000000000000000000000000000"),
+		}
+
+		for expected, n := range samples {
+			compact := BigToCompact(n)
+			if compact != expected {
+				t.Errorf("Wrong conversion of (%x): actual (0x%08x), expected (0x%08x)", n, compact, expected)
+			}
+		}
+	})
+}
+
+func TestHashToBig(t *testing.T) {
+	hash := Hash{0x01, 0x02}
+
+	n := HashToBig(&hash)
+	if n.Cmp(big.NewInt(0x0201)) != 0 {
+		t.Errorf("Wrong conversion (%x)", n)
+	}
+}
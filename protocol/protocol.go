@@ -1,7 +1,9 @@
 package protocol
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 )
 
 // BitcoinNet represents which bitcoin network a message belongs to.
@@ -10,21 +12,36 @@ type BitcoinNet uint32
 // Constants used to indicate the message bitcoin network.
 const (
 	MainNet  BitcoinNet = 0xd9b4bef9 // MainNet represents the main bitcoin network.
-	TestNet  BitcoinNet = 0xdab5bffa // TestNet represents the regression test network.
+	RegTest  BitcoinNet = 0xdab5bffa // RegTest represents the regression test network.
 	TestNet3 BitcoinNet = 0x0709110b // TestNet3 represents the test network (version 3).
+	TestNet4 BitcoinNet = 0x283f161c // TestNet4 represents the test network (version 4).
+	SigNet   BitcoinNet = 0x40cf030a // SigNet represents the default signet network.
 	SimNet   BitcoinNet = 0x12141c16 // SimNet represents the simulation test network.
 )
 
+// TestNet represents the regression test network.
+//
+// Deprecated: TestNet is not any test network but regtest, use RegTest instead.
+const TestNet = RegTest
+
+// btcNetMtx protects btcNetUint32.
+var btcNetMtx sync.RWMutex
+
 // btcNetUint32 is a map of bitcoin networks back to their uint32 value
 var btcNetUint32 = map[uint32]BitcoinNet{
 	uint32(MainNet):  MainNet,
-	uint32(TestNet):  TestNet,
+	uint32(RegTest):  RegTest,
 	uint32(TestNet3): TestNet3,
+	uint32(TestNet4): TestNet4,
+	uint32(SigNet):   SigNet,
 	uint32(SimNet):   SimNet,
 }
 
 // NewBitcoinNet returns BitcoinNet matching the uint32 value.
 func NewBitcoinNet(net uint32) (*BitcoinNet, error) {
+	btcNetMtx.RLock()
+	defer btcNetMtx.RUnlock()
+
 	if s, ok := btcNetUint32[net]; ok {
 		return &s, nil
 	}
@@ -32,6 +49,23 @@ func NewBitcoinNet(net uint32) (*BitcoinNet, error) {
 	return nil, fmt.Errorf("Unknown BitcoinNet (%d)", net)
 }
 
+// RegisterBitcoinNet registers net, so NewBitcoinNet recognizes it.
+// It allows networks with magic unknown beforehand, like custom signets.
+func RegisterBitcoinNet(net BitcoinNet) {
+	btcNetMtx.Lock()
+	defer btcNetMtx.Unlock()
+
+	btcNetUint32[uint32(net)] = net
+}
+
+// UnregisterBitcoinNet removes net, so NewBitcoinNet no longer recognizes it.
+func UnregisterBitcoinNet(net BitcoinNet) {
+	btcNetMtx.Lock()
+	defer btcNetMtx.Unlock()
+
+	delete(btcNetUint32, uint32(net))
+}
+
 // BitcoinCmdSize represents the size of p2p cmd.
 const BitcoinCmdSize = 12
 
@@ -41,33 +75,121 @@ type BitcoinCmdData [BitcoinCmdSize]byte
 // BitcoinCmdName represents a command used in p2p communication.
 type BitcoinCmdName string
 
+// Commands used in p2p communication.
+// https://en.bitcoin.it/wiki/Protocol_documentation#Message_types
 const (
-	VersionCmd    BitcoinCmdName = "version"
-	VerackCmd     BitcoinCmdName = "verack"
-	AddrCmd       BitcoinCmdName = "addr"
-	InvCmd        BitcoinCmdName = "inv"
-	GetDataCmd    BitcoinCmdName = "getdata"
-	GetBlocksCmd  BitcoinCmdName = "getblocks"
-	GetHeadersCmd BitcoinCmdName = "getheaders"
+	VersionCmd      BitcoinCmdName = "version"
+	VerackCmd       BitcoinCmdName = "verack"
+	AddrCmd         BitcoinCmdName = "addr"
+	AddrV2Cmd       BitcoinCmdName = "addrv2"
+	SendAddrV2Cmd   BitcoinCmdName = "sendaddrv2"
+	InvCmd          BitcoinCmdName = "inv"
+	GetDataCmd      BitcoinCmdName = "getdata"
+	NotFoundCmd     BitcoinCmdName = "notfound"
+	GetBlocksCmd    BitcoinCmdName = "getblocks"
+	GetHeadersCmd   BitcoinCmdName = "getheaders"
+	TxCmd           BitcoinCmdName = "tx"
+	BlockCmd        BitcoinCmdName = "block"
+	HeadersCmd      BitcoinCmdName = "headers"
+	GetAddrCmd      BitcoinCmdName = "getaddr"
+	MempoolCmd      BitcoinCmdName = "mempool"
+	CheckOrderCmd   BitcoinCmdName = "checkorder"
+	SubmitOrderCmd  BitcoinCmdName = "submitorder"
+	ReplyCmd        BitcoinCmdName = "reply"
+	PingCmd         BitcoinCmdName = "ping"
+	PongCmd         BitcoinCmdName = "pong"
+	RejectCmd       BitcoinCmdName = "reject"
+	FilterLoadCmd   BitcoinCmdName = "filterload"
+	FilterAddCmd    BitcoinCmdName = "filteradd"
+	FilterClearCmd  BitcoinCmdName = "filterclear"
+	MerkleBlockCmd  BitcoinCmdName = "merkleblock"
+	AlertCmd        BitcoinCmdName = "alert"
+	SendHeadersCmd  BitcoinCmdName = "sendheaders"
+	FeeFilterCmd    BitcoinCmdName = "feefilter"
+	SendCmpctCmd    BitcoinCmdName = "sendcmpct"
+	CmpctBlockCmd   BitcoinCmdName = "cmpctblock"
+	GetBlockTxnCmd  BitcoinCmdName = "getblocktxn"
+	BlockTxnCmd     BitcoinCmdName = "blocktxn"
+	WtxidRelayCmd   BitcoinCmdName = "wtxidrelay"
+	GetCFiltersCmd  BitcoinCmdName = "getcfilters"
+	CFilterCmd      BitcoinCmdName = "cfilter"
+	GetCFHeadersCmd BitcoinCmdName = "getcfheaders"
+	CFHeadersCmd    BitcoinCmdName = "cfheaders"
+	GetCFCheckptCmd BitcoinCmdName = "getcfcheckpt"
+	CFCheckptCmd    BitcoinCmdName = "cfcheckpt"
 )
 
-var VersionCmdData BitcoinCmdData = BitcoinCmdData{0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x00, 0x00, 0x00, 0x00, 0x00}
-var VerackCmdData BitcoinCmdData = BitcoinCmdData{0xf9, 0xbe, 0xb4, 0xD9, 0x76, 0x65, 0x72, 0x61, 0x63, 0x6B, 0x00, 0x00}
-var AddrCmdData BitcoinCmdData = BitcoinCmdData{0x61, 0x64, 0x64, 0x72, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+// btcCmdNames holds every known BitcoinCmdName.
+var btcCmdNames = []BitcoinCmdName{
+	VersionCmd,
+	VerackCmd,
+	AddrCmd,
+	AddrV2Cmd,
+	SendAddrV2Cmd,
+	InvCmd,
+	GetDataCmd,
+	NotFoundCmd,
+	GetBlocksCmd,
+	GetHeadersCmd,
+	TxCmd,
+	BlockCmd,
+	HeadersCmd,
+	GetAddrCmd,
+	MempoolCmd,
+	CheckOrderCmd,
+	SubmitOrderCmd,
+	ReplyCmd,
+	PingCmd,
+	PongCmd,
+	RejectCmd,
+	FilterLoadCmd,
+	FilterAddCmd,
+	FilterClearCmd,
+	MerkleBlockCmd,
+	AlertCmd,
+	SendHeadersCmd,
+	FeeFilterCmd,
+	SendCmpctCmd,
+	CmpctBlockCmd,
+	GetBlockTxnCmd,
+	BlockTxnCmd,
+	WtxidRelayCmd,
+	GetCFiltersCmd,
+	CFilterCmd,
+	GetCFHeadersCmd,
+	CFHeadersCmd,
+	GetCFCheckptCmd,
+	CFCheckptCmd,
+}
+
+var VersionCmdData BitcoinCmdData = newBitcoinCmdData(VersionCmd)
+var VerackCmdData BitcoinCmdData = newBitcoinCmdData(VerackCmd)
+var AddrCmdData BitcoinCmdData = newBitcoinCmdData(AddrCmd)
 
 // btcCmdDataName is a map of BitcoinCmdData back to their BitcoinCmd.
-var btcCmdDataName = map[BitcoinCmdData]BitcoinCmdName{
-	VersionCmdData: VersionCmd,
-	VerackCmdData:  VerackCmd,
-	AddrCmdData:    AddrCmd,
-}
+var btcCmdDataName = map[BitcoinCmdData]BitcoinCmdName{}
 
 // btcCmdNameData is a map of BitcoinCmd back to their BitcoinCmdData.
-var btcCmdNameData = map[BitcoinCmdName]BitcoinCmdData{
-	VersionCmd: VersionCmdData,
-	VerackCmd:  VerackCmdData,
+var btcCmdNameData = map[BitcoinCmdName]BitcoinCmdData{}
+
+func init() {
+	for _, name := range btcCmdNames {
+		data := newBitcoinCmdData(name)
+		btcCmdDataName[data] = name
+		btcCmdNameData[name] = data
+	}
+}
+
+// newBitcoinCmdData returns BitcoinCmdData of name, the ASCII name padded with NUL bytes.
+func newBitcoinCmdData(name BitcoinCmdName) BitcoinCmdData {
+	var data BitcoinCmdData
+	copy(data[:], name)
+	return data
 }
 
+// ErrUnknownCmd is returned when a command does not match any known BitcoinCmd.
+var ErrUnknownCmd = errors.New("Unknown Bitcoin command")
+
 // BitcoinCmd represents bitcoin command protocol.
 type BitcoinCmd struct {
 	// HexData represents cmd bytes.
@@ -87,7 +209,7 @@ func (cmd *BitcoinCmd) FromHex(data []byte) error {
 
 	name, ok := btcCmdDataName[BitcoinCmdData(cmdData)]
 	if !ok {
-		return fmt.Errorf("Unknown Bitcoin command")
+		return ErrUnknownCmd
 	}
 
 	cmd.Name = name
@@ -99,7 +221,7 @@ func (cmd *BitcoinCmd) FromHex(data []byte) error {
 func (cmd *BitcoinCmd) FromString(name string) error {
 	data, ok := btcCmdNameData[BitcoinCmdName(name)]
 	if !ok {
-		return fmt.Errorf("Unknown Bitcoin command")
+		return ErrUnknownCmd
 	}
 
 	cmd.HexData = data
@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -8,8 +9,10 @@ func TestBitcoinNet(t *testing.T) {
 	t.Run("should recognice bitcoin networks", func(t *testing.T) {
 		nets := []uint32{
 			uint32(MainNet),
-			uint32(TestNet),
+			uint32(RegTest),
 			uint32(TestNet3),
+			uint32(TestNet4),
+			uint32(SigNet),
 			uint32(SimNet),
 		}
 
@@ -29,6 +32,17 @@ func TestBitcoinNet(t *testing.T) {
 			t.Errorf("Net (0x%x) should NOT be valid bitcoin network", net)
 		}
 	})
+
+	t.Run("should recognice registered bitcoin network", func(t *testing.T) {
+		var net uint32 = 0xbbb5bff2
+
+		RegisterBitcoinNet(BitcoinNet(net))
+
+		_, err := NewBitcoinNet(net)
+		if err != nil {
+			t.Errorf("Could not recognice network (0x%x)", net)
+		}
+	})
 }
 
 func TestBitcoinCmdFromHex(t *testing.T) {
@@ -37,7 +51,7 @@ func TestBitcoinCmdFromHex(t *testing.T) {
 
 		data := []byte{0xaa, 0xbb, 0x72, 0x71, 0x11, 0xf1, 0x65, 0x00, 0x00, 0x00, 0x00, 0x12}
 		err := cmd.FromHex(data)
-		if err == nil {
+		if !errors.Is(err, ErrUnknownCmd) {
 			t.Errorf("Command should not be recognized")
 		}
 	})
@@ -67,7 +81,7 @@ func TestBitcoinCmdFromString(t *testing.T) {
 		cmd := &BitcoinCmd{}
 
 		err := cmd.FromString("Help")
-		if err == nil {
+		if !errors.Is(err, ErrUnknownCmd) {
 			t.Errorf("Command should not be recognized")
 		}
 	})
@@ -81,3 +95,33 @@ func TestBitcoinCmdFromString(t *testing.T) {
 		}
 	})
 }
+
+func TestBitcoinCmdTable(t *testing.T) {
+	t.Run("should recognise every command", func(t *testing.T) {
+		for _, name := range btcCmdNames {
+			cmd := &BitcoinCmd{}
+			err := cmd.FromString(string(name))
+			if err != nil {
+				t.Errorf("Command (%s) not recognized from string", name)
+			}
+
+			fromHex := &BitcoinCmd{}
+			err = fromHex.FromHex(cmd.HexData[:])
+			if err != nil {
+				t.Errorf("Command (%s) not recognized from hex", name)
+			}
+
+			if fromHex.Name != name {
+				t.Errorf("Wrong command name: actual (%s), expected (%s)", fromHex.Name, name)
+			}
+		}
+	})
+
+	t.Run("should pad command with NUL bytes", func(t *testing.T) {
+		expected := BitcoinCmdData{0x76, 0x65, 0x72, 0x61, 0x63, 0x6b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+		if VerackCmdData != expected {
+			t.Errorf("Wrong verack command data (%v)", VerackCmdData)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ServiceFlag represents the bitfield of services a node announces in version and addr msgs.
+// https://github.com/bitcoin/bitcoin/blob/master/src/protocol.h
+type ServiceFlag uint64
+
+const (
+	// NODE_NONE represents a node offering no services.
+	NODE_NONE ServiceFlag = 0
+	// NODE_NETWORK represents a node able to serve the full block chain.
+	NODE_NETWORK ServiceFlag = 1 << 0
+	// NODE_BLOOM represents a node supporting bloom filtered connections.
+	// https://github.com/bitcoin/bips/blob/master/bip-0111.mediawiki
+	NODE_BLOOM ServiceFlag = 1 << 2
+	// NODE_WITNESS represents a node able to serve witness data.
+	// https://github.com/bitcoin/bips/blob/master/bip-0144.mediawiki
+	NODE_WITNESS ServiceFlag = 1 << 3
+	// NODE_COMPACT_FILTERS represents a node serving compact block filters.
+	// https://github.com/bitcoin/bips/blob/master/bip-0157.mediawiki
+	NODE_COMPACT_FILTERS ServiceFlag = 1 << 6
+	// NODE_NETWORK_LIMITED represents a node able to serve the last 288 blocks.
+	// https://github.com/bitcoin/bips/blob/master/bip-0159.mediawiki
+	NODE_NETWORK_LIMITED ServiceFlag = 1 << 10
+	// NODE_P2P_V2 represents a node supporting the encrypted transport protocol.
+	// https://github.com/bitcoin/bips/blob/master/bip-0324.mediawiki
+	NODE_P2P_V2 ServiceFlag = 1 << 11
+)
+
+// serviceFlagNames holds the names of known services, in bit order.
+var serviceFlagNames = []struct {
+	flag ServiceFlag
+	name string
+}{
+	{flag: NODE_NETWORK, name: "NODE_NETWORK"},
+	{flag: NODE_BLOOM, name: "NODE_BLOOM"},
+	{flag: NODE_WITNESS, name: "NODE_WITNESS"},
+	{flag: NODE_COMPACT_FILTERS, name: "NODE_COMPACT_FILTERS"},
+	{flag: NODE_NETWORK_LIMITED, name: "NODE_NETWORK_LIMITED"},
+	{flag: NODE_P2P_V2, name: "NODE_P2P_V2"},
+}
+
+// Has returns whether every service of required is offered.
+func (s ServiceFlag) Has(required ServiceFlag) bool {
+	return s&required == required
+}
+
+// String returns the names of offered services joined by "|", e.g. NODE_NETWORK|NODE_WITNESS.
+// Unknown services are written as hexadecimal bitfield.
+func (s ServiceFlag) String() string {
+	if s == NODE_NONE {
+		return "NODE_NONE"
+	}
+
+	names := []string{}
+	for _, service := range serviceFlagNames {
+		if s.Has(service.flag) {
+			names = append(names, service.name)
+			s &^= service.flag
+		}
+	}
+
+	if s != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint64(s)))
+	}
+
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,39 @@
+package protocol
+
+import "testing"
+
+func TestServiceFlag(t *testing.T) {
+	t.Run("String", func(t *testing.T) {
+		samples := map[ServiceFlag]string{
+			NODE_NONE:                    "NODE_NONE",
+			NODE_NETWORK:                 "NODE_NETWORK",
+			NODE_NETWORK | NODE_WITNESS:  "NODE_NETWORK|NODE_WITNESS",
+			NODE_BLOOM | NODE_P2P_V2:     "NODE_BLOOM|NODE_P2P_V2",
+			NODE_COMPACT_FILTERS:         "NODE_COMPACT_FILTERS",
+			NODE_NETWORK_LIMITED | 1<<24: "NODE_NETWORK_LIMITED|0x1000000",
+			1 << 1:                       "0x2",
+		}
+
+		for flag, expected := range samples {
+			if flag.String() != expected {
+				t.Errorf("Wrong string of (0x%x): actual (%s), expected (%s)", uint64(flag), flag, expected)
+			}
+		}
+	})
+
+	t.Run("Has", func(t *testing.T) {
+		services := NODE_NETWORK | NODE_WITNESS | NODE_NETWORK_LIMITED
+
+		if !services.Has(NODE_NETWORK | NODE_WITNESS) {
+			t.Error("Services should include NODE_NETWORK and NODE_WITNESS")
+		}
+
+		if !services.Has(NODE_NONE) {
+			t.Error("Services should include no service")
+		}
+
+		if services.Has(NODE_WITNESS | NODE_BLOOM) {
+			t.Error("Services should NOT include NODE_BLOOM")
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package protocol
+
+// ProtocolVersion represents the latest protocol version supported by Havel.
+const ProtocolVersion uint32 = 70016
+
+// MinPeerVersion represents the default minimum protocol version of accepted peers.
+// https://github.com/bitcoin/bitcoin/blob/master/src/node/protocol_version.h
+const MinPeerVersion uint32 = 31800
+
+// BIP0031Version represents the last protocol version without ping nonce and pong msg.
+// https://github.com/bitcoin/bips/blob/master/bip-0031.mediawiki
+const BIP0031Version uint32 = 60000
+
+// BIP0037Version represents the protocol version from which version msg includes relay field.
+// https://github.com/bitcoin/bips/blob/master/bip-0037.mediawiki
+const BIP0037Version uint32 = 70001
+
+// SendHeadersVersion represents the protocol version from which sendheaders msg is supported.
+// https://github.com/bitcoin/bips/blob/master/bip-0130.mediawiki
+const SendHeadersVersion uint32 = 70012
+
+// FeeFilterVersion represents the protocol version from which feefilter msg is supported.
+// https://github.com/bitcoin/bips/blob/master/bip-0133.mediawiki
+const FeeFilterVersion uint32 = 70013
+
+// ShortIDsBlocksVersion represents the protocol version from which compact blocks are supported.
+// https://github.com/bitcoin/bips/blob/master/bip-0152.mediawiki
+const ShortIDsBlocksVersion uint32 = 70014
+
+// AddrV2Version represents the protocol version from which addrv2 and sendaddrv2 msgs are supported.
+// https://github.com/bitcoin/bips/blob/master/bip-0155.mediawiki
+const AddrV2Version uint32 = 70016
+
+// WTxIDRelayVersion represents the protocol version from which wtxidrelay msg is supported.
+// https://github.com/bitcoin/bips/blob/master/bip-0339.mediawiki
+const WTxIDRelayVersion uint32 = 70016
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+
+	"github.com/elmarsan/havel/msg"
+)
+
+// maxPendingRejects represents the maximum number of reject events waiting to be received,
+// newer events are dropped.
+const maxPendingRejects = 100
+
+// RejectEvent represents reject msg received from connected peer.
+type RejectEvent struct {
+	// Peer represents the peer which sent the reject msg.
+	Peer *Peer
+	// Err represents the rejection.
+	Err *msg.RejectError
+}
+
+// Rejects returns channel receiving reject msgs sent by connected peers.
+func (c *Client) Rejects() <-chan *RejectEvent {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.rejectEvents()
+}
+
+// handleReject emits reject event for reject msg received from peer.
+// Events are dropped when nobody receives them.
+func (c *Client) handleReject(peer *Peer, reject *msg.Reject) {
+	event := &RejectEvent{
+		Peer: peer,
+		Err:  &msg.RejectError{Reject: reject},
+	}
+
+	log.Printf("Peer %s: %s", peer.conn.RemoteAddr(), event.Err)
+
+	c.mtx.Lock()
+	rejects := c.rejectEvents()
+	c.mtx.Unlock()
+
+	select {
+	case rejects <- event:
+	default:
+	}
+}
+
+// rejectEvents returns reject events channel, creating it if needed.
+// It must be called holding c.mtx.
+func (c *Client) rejectEvents() chan *RejectEvent {
+	if c.rejects == nil {
+		c.rejects = make(chan *RejectEvent, maxPendingRejects)
+	}
+
+	return c.rejects
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+func TestReject(t *testing.T) {
+	t.Run("should return reject received during handshake", func(t *testing.T) {
+		remote := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		addr := listen(t, func(conn net.Conn) {
+			defer conn.Close()
+
+			_, err := msg.ReadMessage(conn, remote.version, remote.net)
+			if err != nil {
+				return
+			}
+
+			reject := &msg.Reject{
+				Cmd:    protocol.VersionCmd,
+				Code:   msg.RejectObsolete,
+				Reason: "Version must be 70016 or greater",
+			}
+			msg.WriteMessage(conn, reject, remote.version, remote.net)
+		})
+
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		err := client.AddPeer(addr)
+		if !errors.Is(err, msg.ErrRejectObsolete) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+
+		var rejectErr *msg.RejectError
+		if !errors.As(err, &rejectErr) || rejectErr.Reject.Cmd != protocol.VersionCmd {
+			t.Errorf("Wrong rejected msg (%v)", err)
+		}
+	})
+
+	t.Run("should emit reject received from connected peer", func(t *testing.T) {
+		client := &Client{
+			version: protocol.AddrV2Version,
+			net:     protocol.MainNet,
+		}
+
+		rejects := client.Rejects()
+
+		connectRemote(t, client, func(remote *Client, peer *Peer) {
+			write(remote, peer, &msg.Reject{
+				Cmd:    protocol.TxCmd,
+				Code:   msg.RejectDust,
+				Reason: "dust",
+				Hash:   protocol.Hash{0x01},
+			})
+
+			// Keep connection open until client is done
+			msg.ReadMessage(peer.conn, peer.version, remote.net)
+		})
+
+		select {
+		case event := <-rejects:
+			if !errors.Is(event.Err, msg.ErrRejectDust) {
+				t.Errorf("Wrong error (%s)", event.Err)
+			}
+
+			if event.Err.Reject.Hash != (protocol.Hash{0x01}) {
+				t.Errorf("Wrong rejected hash (%s)", event.Err.Reject.Hash)
+			}
+
+			if event.Peer == nil {
+				t.Error("Missing peer")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Reject was not emitted")
+		}
+	})
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/elmarsan/havel/blockchain"
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/msg"
+)
+
+// maxUnconnectingHeaders represents the maximum number of headers msgs not connecting to the header tree
+// a peer may send before being disconnected.
+const maxUnconnectingHeaders = 10
+
+// defaultHeadersTimeout represents the maximum time the sync peer may take to answer getheaders,
+// unless Client sets other.
+const defaultHeadersTimeout = 2 * time.Minute
+
+// startSync makes peer the sync peer when there is none, as long as peer offers syncServices
+// and its start height is greater than the best header height, then requests headers to it.
+// Sync peer is disconnected when it does not answer getheaders in time.
+func (c *Client) startSync(peer *Peer) error {
+	if !peer.services.Has(syncServices) {
+		return nil
+	}
+
+	tree, err := c.headerTree()
+	if err != nil {
+		return err
+	}
+
+	best := tree.Best()
+
+	c.mtx.Lock()
+	if c.syncPeer != nil || int32(peer.startHeight) <= best.Height {
+		c.mtx.Unlock()
+		return nil
+	}
+	c.syncPeer = peer
+	c.mtx.Unlock()
+
+	log.Printf("Syncing headers from peer %s (height %d)", peer.conn.RemoteAddr(), peer.startHeight)
+
+	err = c.requestHeaders(peer)
+	if err != nil {
+		return err
+	}
+
+	return c.peerManager().run(func() { c.watchSync(peer) })
+}
+
+// watchSync disconnects peer when it does not answer getheaders within headers timeout,
+// until it is no longer the sync peer.
+func (c *Client) watchSync(peer *Peer) {
+	ctx := c.peerManager().ctx
+
+	timeout := c.headersTimeout
+	if timeout == 0 {
+		timeout = defaultHeadersTimeout
+	}
+
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-peer.quit:
+			return
+		case <-ticker.C:
+			c.mtx.Lock()
+			syncing, requested := c.syncPeer == peer, c.syncRequested
+			c.mtx.Unlock()
+
+			if !syncing {
+				return
+			}
+
+			if time.Since(requested) > timeout {
+				log.Printf("Disconnecting peer %s (headers sync stalled)", peer.conn.RemoteAddr())
+				c.RemovePeer(peer)
+				return
+			}
+		}
+	}
+}
+
+// stopSync stops syncing from peer, then syncing restarts from any other connected peer.
+func (c *Client) stopSync(peer *Peer) {
+	c.mtx.Lock()
+	if c.syncPeer != peer {
+		c.mtx.Unlock()
+		return
+	}
+	c.syncPeer = nil
+	c.mtx.Unlock()
+
+	if c.peerManager().ctx.Err() != nil {
+		return
+	}
+
+	for _, p := range c.PeersWithServices(syncServices) {
+		if p != peer && !p.inbound {
+			c.startSync(p)
+		}
+	}
+}
+
+// isSyncPeer returns whether client is syncing from peer.
+func (c *Client) isSyncPeer(peer *Peer) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.syncPeer == peer
+}
+
+// requestHeaders asks peer for the headers following the best header.
+func (c *Client) requestHeaders(peer *Peer) error {
+	tree, err := c.headerTree()
+	if err != nil {
+		return err
+	}
+
+	c.mtx.Lock()
+	if c.syncPeer == peer {
+		c.syncRequested = time.Now()
+	}
+	c.mtx.Unlock()
+
+	return c.SendTo(peer, &msg.GetHeaders{
+		Version:            c.version,
+		BlockLocatorHashes: tree.Locator(),
+	})
+}
+
+// handleHeaders adds headers received from peer to the header tree.
+// Peer is disconnected when any header is invalid, or when it keeps sending headers not connecting to the tree.
+// Sync peer is asked for more headers until its start height is reached and it has no more headers.
+func (c *Client) handleHeaders(peer *Peer, headers *msg.Headers) error {
+	tree, err := c.headerTree()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, header := range headers.Headers {
+		_, err := tree.AddHeader(header, now)
+		if errors.Is(err, blockchain.ErrOrphanHeader) {
+			peer.unconnectingHeaders++
+			if peer.unconnectingHeaders >= maxUnconnectingHeaders {
+				return fmt.Errorf("Too many unconnecting headers (%s)", err)
+			}
+
+			// Missing headers were probably announced while not connected
+			return c.requestHeaders(peer)
+		}
+
+		if err != nil {
+			return fmt.Errorf("Invalid header (%s)", err)
+		}
+	}
+
+	if len(headers.Headers) > 0 {
+		peer.unconnectingHeaders = 0
+
+		// Download the blocks of new headers while syncing the following ones
+		c.scheduleBlocks()
+	}
+
+	if !c.isSyncPeer(peer) {
+		return nil
+	}
+
+	best := tree.Best()
+	if len(headers.Headers) > 0 && (best.Height < int32(peer.startHeight) || len(headers.Headers) == msg.MaxHeadersPerMsg) {
+		return c.requestHeaders(peer)
+	}
+
+	log.Printf("Headers synced up to height %d (%s)", best.Height, best.Hash)
+
+	c.mtx.Lock()
+	c.syncPeer = nil
+	c.mtx.Unlock()
+
+	return nil
+}
+
+// handleGetHeaders sends to peer up to msg.MaxHeadersPerMsg headers of the best chain following
+// the first locator hash found in it, stopping at HashStop.
+func (c *Client) handleGetHeaders(peer *Peer, getHeaders *msg.GetHeaders) error {
+	tree, err := c.headerTree()
+	if err != nil {
+		return err
+	}
+
+	// Without common header, headers follow genesis
+	height := int32(1)
+	for _, hash := range getHeaders.BlockLocatorHashes {
+		node := tree.Node(hash)
+		if node != nil && tree.NodeByHeight(node.Height) == node {
+			height = node.Height + 1
+			break
+		}
+	}
+
+	headers := &msg.Headers{}
+	for node := tree.NodeByHeight(height); node != nil && len(headers.Headers) < msg.MaxHeadersPerMsg; node = tree.NodeByHeight(node.Height + 1) {
+		headers.Headers = append(headers.Headers, node.Header)
+
+		if node.Hash == getHeaders.HashStop {
+			break
+		}
+	}
+
+	return c.SendTo(peer, headers)
+}
+
+// headerTree returns client header tree, creating it if needed.
+// It fails when client network parameters are not registered.
+func (c *Client) headerTree() (*blockchain.HeaderTree, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.headers == nil {
+		params, err := chaincfg.ParamsForNet(c.net)
+		if err != nil {
+			return nil, err
+		}
+
+		c.headers = blockchain.NewHeaderTree(params)
+	}
+
+	return c.headers, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elmarsan/havel/chaincfg"
+	"github.com/elmarsan/havel/msg"
+	"github.com/elmarsan/havel/protocol"
+)
+
+// mineHeaders adds n regtest headers to client header tree, extending its best header.
+func mineHeaders(t *testing.T, client *Client, n int) {
+	tree, err := client.headerTree()
+	if err != nil {
+		t.Fatalf("Unable to get header tree (%s)", err)
+	}
+
+	parent := tree.Best()
+	for i := 0; i < n; i++ {
+		header := &msg.BlockHeader{
+			Version:   4,
+			PrevBlock: parent.Hash,
+			Timestamp: parent.Header.Timestamp.Add(10 * time.Minute),
+			Bits:      parent.Header.Bits,
+		}
+
+		for header.CheckProofOfWork(chaincfg.RegTestParams.PowLimit) != nil {
+			header.Nonce++
+		}
+
+		parent, err = tree.AddHeader(header, header.Timestamp)
+		if err != nil {
+			t.Fatalf("Unable to add header (%s)", err)
+		}
+	}
+}
+
+// listenPeer starts remote listening for peers, which are handshaked and started.
+func listenPeer(t *testing.T, remote *Client) string {
+	return listen(t, func(conn net.Conn) {
+		peer, err := remote.handshake(conn, true)
+		if err != nil {
+			conn.Close()
+			return
+		}
+
+		err = remote.startPeer(peer)
+		if err != nil {
+			conn.Close()
+		}
+	})
+}
+
+func TestHeadersSync(t *testing.T) {
+	remote := &Client{
+		version:  protocol.ProtocolVersion,
+		net:      protocol.RegTest,
+		services: syncServices,
+	}
+	t.Cleanup(func() { remote.peerManager().shutdown(remote.RemovePeer) })
+
+	mineHeaders(t, remote, msg.MaxHeadersPerMsg+500)
+
+	client := &Client{
+		version: protocol.ProtocolVersion,
+		net:     protocol.RegTest,
+	}
+	t.Cleanup(func() { client.peerManager().shutdown(client.RemovePeer) })
+
+	err := client.AddPeer(listenPeer(t, remote))
+	if err != nil {
+		t.Fatalf("Unable to add peer (%s)", err)
+	}
+
+	remoteTree, _ := remote.headerTree()
+	tree, _ := client.headerTree()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for tree.Best().Hash != remoteTree.Best().Hash {
+		if time.Now().After(deadline) {
+			t.Fatalf("Headers were not synced, best height (%d)", tree.Best().Height)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if tree.Best().Height != msg.MaxHeadersPerMsg+500 {
+		t.Errorf("Wrong best height (%d)", tree.Best().Height)
+	}
+
+	for !client.isSyncPeer(nil) {
+		if time.Now().After(deadline) {
+			t.Fatal("Sync peer should be cleared once synced")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSyncTimeout(t *testing.T) {
+	remote := &Client{
+		version:  protocol.ProtocolVersion,
+		net:      protocol.RegTest,
+		services: syncServices,
+	}
+	t.Cleanup(func() { remote.peerManager().shutdown(remote.RemovePeer) })
+
+	mineHeaders(t, remote, 10)
+
+	// Silent peer never answers getheaders
+	disconnected := make(chan struct{})
+	silent := listen(t, func(conn net.Conn) {
+		defer close(disconnected)
+		defer conn.Close()
+
+		peer, err := remote.handshake(conn, true)
+		if err != nil {
+			return
+		}
+
+		for {
+			_, err := msg.ReadMessage(conn, peer.version, remote.net)
+			if err != nil {
+				return
+			}
+		}
+	})
+
+	client := &Client{
+		version:        protocol.ProtocolVersion,
+		net:            protocol.RegTest,
+		headersTimeout: 200 * time.Millisecond,
+	}
+	t.Cleanup(func() { client.peerManager().shutdown(client.RemovePeer) })
+
+	for _, addr := range []string{silent, listenPeer(t, remote)} {
+		err := client.AddPeer(addr)
+		if err != nil {
+			t.Fatalf("Unable to add peer (%s)", err)
+		}
+	}
+
+	t.Run("should disconnect silent sync peer", func(t *testing.T) {
+		select {
+		case <-disconnected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Silent sync peer should be disconnected")
+		}
+	})
+
+	t.Run("should sync from other peer", func(t *testing.T) {
+		remoteTree, _ := remote.headerTree()
+		tree, _ := client.headerTree()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for tree.Best().Hash != remoteTree.Best().Hash {
+			if time.Now().After(deadline) {
+				t.Fatalf("Headers were not synced, best height (%d)", tree.Best().Height)
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestHandleHeaders(t *testing.T) {
+	client := &Client{
+		version: protocol.ProtocolVersion,
+		net:     protocol.RegTest,
+	}
+
+	t.Run("should NOT accept invalid header", func(t *testing.T) {
+		header := chaincfg.RegTestParams.GenesisBlock.Header
+		header.PrevBlock = chaincfg.RegTestParams.GenesisHash
+		header.Timestamp = header.Timestamp.Add(-time.Second)
+
+		err := client.handleHeaders(&Peer{}, &msg.Headers{Headers: []*msg.BlockHeader{&header}})
+		if err == nil {
+			t.Error("Invalid header should NOT be accepted")
+		}
+	})
+
+	t.Run("should disconnect peer sending unconnecting headers", func(t *testing.T) {
+		conn, remoteConn := net.Pipe()
+		defer conn.Close()
+		defer remoteConn.Close()
+
+		peer := &Peer{
+			conn:     conn,
+			outbound: make(chan msg.Message, outboundQueueSize),
+			quit:     make(chan struct{}),
+		}
+
+		header := &msg.BlockHeader{PrevBlock: protocol.Hash{0x01}}
+		headers := &msg.Headers{Headers: []*msg.BlockHeader{header}}
+
+		for i := 1; i < maxUnconnectingHeaders; i++ {
+			err := client.handleHeaders(peer, headers)
+			if err != nil {
+				t.Fatalf("Unconnecting headers should be answered (%s)", err)
+			}
+
+			m := <-peer.outbound
+			if _, ok := m.(*msg.GetHeaders); !ok {
+				t.Fatalf("Unconnecting headers should be answered with getheaders (%s)", m.Command())
+			}
+		}
+
+		err := client.handleHeaders(peer, headers)
+		if err == nil || errors.Is(err, ErrPeerDisconnected) {
+			t.Errorf("Wrong error (%v)", err)
+		}
+	})
+}